@@ -0,0 +1,50 @@
+// Package author parses the identities recorded on comments and notes.
+// Identities may be namespaced as "human:<name>" or "agent:<name>"; plain
+// identities such as "claude" or "web-ui" are accepted but have no kind.
+package author
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of author, the prefix before the colon of a namespaced identity
+const (
+	KindHuman = "human"
+	KindAgent = "agent"
+)
+
+// Parse splits an identity into its kind and name. Identities without a
+// known prefix have an empty kind and are returned whole as the name.
+func Parse(id string) (kind, name string) {
+	prefix, rest, found := strings.Cut(id, ":")
+	if found && (prefix == KindHuman || prefix == KindAgent) {
+		return prefix, rest
+	}
+	return "", id
+}
+
+// Validate rejects empty identities and namespaced ones without a name
+func Validate(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("author cannot be empty")
+	}
+	if kind, name := Parse(id); kind != "" && strings.TrimSpace(name) == "" {
+		return fmt.Errorf("author %q is missing a name after %s:", id, kind)
+	}
+	return nil
+}
+
+// ValidateKind rejects kinds other than KindHuman and KindAgent
+func ValidateKind(kind string) error {
+	if kind != KindHuman && kind != KindAgent {
+		return fmt.Errorf("invalid author kind %q: must be %s or %s", kind, KindHuman, KindAgent)
+	}
+	return nil
+}
+
+// IsKind reports whether id is namespaced with kind
+func IsKind(id, kind string) bool {
+	idKind, _ := Parse(id)
+	return idKind == kind
+}
@@ -0,0 +1,35 @@
+package author
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		id, kind, name string
+	}{
+		{"human:alice", KindHuman, "alice"},
+		{"agent:claude", KindAgent, "claude"},
+		{"claude", "", "claude"},
+		{"web-ui", "", "web-ui"},
+		{"bot:ci", "", "bot:ci"},
+	}
+
+	for _, tt := range tests {
+		kind, name := Parse(tt.id)
+		if kind != tt.kind || name != tt.name {
+			t.Errorf("Parse(%q) = %q, %q; expected %q, %q", tt.id, kind, name, tt.kind, tt.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, id := range []string{"human:alice", "agent:claude", "claude"} {
+		if err := Validate(id); err != nil {
+			t.Errorf("Validate(%q) failed: %v", id, err)
+		}
+	}
+	for _, id := range []string{"", " ", "human:", "agent: "} {
+		if err := Validate(id); err == nil {
+			t.Errorf("Expected Validate(%q) to fail", id)
+		}
+	}
+}
@@ -3,23 +3,121 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/tuist/guck/internal/cli/formatters"
+	"github.com/tuist/guck/internal/cli/helpers"
+	"github.com/tuist/guck/internal/git"
 	"github.com/tuist/guck/internal/mcp"
+	"github.com/tuist/guck/internal/state"
 	"github.com/urfave/cli/v2"
 )
 
+// AddComment handles the "guck comments add" command
+func AddComment(c *cli.Context) error {
+	repoPath := c.String("repo")
+	filePath := c.String("file")
+	text := c.String("text")
+	author := c.String("author")
+	commentType := c.String("type")
+	parentID := c.String("parent")
+	format := c.String("format")
+
+	// Read the suggested replacement from disk if requested
+	var suggestion string
+	if suggestionPath := c.String("suggest-from-file"); suggestionPath != "" {
+		content, err := os.ReadFile(suggestionPath)
+		if err != nil {
+			return fmt.Errorf("failed to read suggestion file: %w", err)
+		}
+		suggestion = string(content)
+	}
+
+	// Get current branch and commit
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return err
+	}
+
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	commit, err := gitRepo.CurrentCommit()
+	if err != nil {
+		return err
+	}
+
+	params := mcp.AddCommentParams{
+		RepoPath:        repoPath,
+		Branch:          branch,
+		Commit:          commit,
+		FilePath:        filePath,
+		Side:            c.String("side"),
+		Text:            text,
+		Suggestion:      suggestion,
+		Author:          author,
+		Type:            commentType,
+		ParentID:        parentID,
+		ResolveExisting: c.Bool("resolve-existing"),
+	}
+
+	// Handle line number
+	if c.IsSet("line") {
+		line := c.Int("line")
+		params.LineNumber = &line
+	}
+	if c.IsSet("end-line") {
+		endLine := c.Int("end-line")
+		params.EndLine = &endLine
+	}
+
+	// Handle metadata
+	if c.IsSet("metadata") {
+		metadata := make(map[string]string)
+		for _, pair := range c.StringSlice("metadata") {
+			parts := helpers.SplitKeyValue(pair)
+			if len(parts) == 2 {
+				metadata[parts[0]] = parts[1]
+			}
+		}
+		if len(metadata) > 0 {
+			params.Metadata = metadata
+		}
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.AddComment(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	return formatters.OutputResult(result, format)
+}
+
 // ListComments handles the "guck comments list" command
 func ListComments(c *cli.Context) error {
 	repoPath := c.String("repo")
 	branch := c.String("branch")
 	commit := c.String("commit")
 	filePath := c.String("file")
+	search := c.String("search")
 	format := c.String("format")
 
 	// Build params
 	params := mcp.ListCommentsParams{
-		RepoPath: repoPath,
+		RepoPath:      repoPath,
+		FollowRenames: c.Bool("follow-renames"),
+		ContextLines:  c.Int("context-lines"),
+		Sort:          c.String("sort"),
+		Limit:         c.Int("limit"),
+		Offset:        c.Int("offset"),
 	}
 
 	if branch != "" {
@@ -31,6 +129,18 @@ func ListComments(c *cli.Context) error {
 	if filePath != "" {
 		params.FilePath = &filePath
 	}
+	if search != "" {
+		params.Search = &search
+	}
+	if authorKind := c.String("author-kind"); authorKind != "" {
+		params.AuthorKind = &authorKind
+	}
+	if since := c.String("since"); since != "" {
+		params.Since = &since
+	}
+	if until := c.String("until"); until != "" {
+		params.Until = &until
+	}
 
 	// Handle resolved filter
 	if c.Bool("resolved") {
@@ -84,3 +194,118 @@ func ResolveComment(c *cli.Context) error {
 
 	return formatters.OutputResult(result, format)
 }
+
+// ReopenComment handles the "guck comments reopen" command
+func ReopenComment(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("requires exactly 1 argument: comment-id")
+	}
+
+	params := mcp.UnresolveCommentParams{
+		RepoPath:  c.String("repo"),
+		CommentID: c.Args().Get(0),
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.UnresolveComment(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	return formatters.OutputResult(result, c.String("format"))
+}
+
+// DeleteComment handles the "guck comments delete" command
+func DeleteComment(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("requires exactly 1 argument: comment-id")
+	}
+
+	commentID := c.Args().Get(0)
+	repoPath := c.String("repo")
+	format := c.String("format")
+
+	params := mcp.DeleteCommentParams{
+		RepoPath:  repoPath,
+		CommentID: commentID,
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.DeleteComment(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	return formatters.OutputResult(result, format)
+}
+
+// StaleComments handles the "guck comments stale" command
+func StaleComments(c *cli.Context) error {
+	repoPath, err := filepath.Abs(c.String("repo"))
+	if err != nil {
+		return fmt.Errorf("invalid repo path: %w", err)
+	}
+	format := c.String("format")
+
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return err
+	}
+
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	commit, err := gitRepo.CurrentCommit()
+	if err != nil {
+		return err
+	}
+
+	stateMgr, err := state.NewManagerForRepo(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if c.Bool("migrate-to-head") {
+		migrated, err := stateMgr.MigrateComments(repoPath, branch, commit)
+		if err != nil {
+			return err
+		}
+
+		if format == "" {
+			fmt.Printf("✓ Moved %d unresolved comment(s) onto HEAD (%s)\n", migrated, commit)
+			return nil
+		}
+		return formatters.OutputResult(map[string]interface{}{
+			"success":  true,
+			"migrated": migrated,
+			"branch":   branch,
+			"commit":   commit,
+		}, format)
+	}
+
+	stale, err := mcp.StaleCommentsWithManager(repoPath, branch, commit, stateMgr)
+	if err != nil {
+		return err
+	}
+
+	if format == "" {
+		formatters.PrintStaleComments(os.Stdout, branch, commit, stale)
+		return nil
+	}
+	return formatters.OutputResult(map[string]interface{}{
+		"comments":    stale,
+		"count":       len(stale),
+		"branch":      branch,
+		"head_commit": commit,
+	}, format)
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tuist/guck/internal/cli/formatters"
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/git"
+	"github.com/urfave/cli/v2"
+)
+
+// Diff handles the "guck diff" command, which prints the diff the web UI
+// would show without starting a daemon
+func Diff(c *cli.Context) error {
+	repoPath, err := filepath.Abs(c.String("repo"))
+	if err != nil {
+		return fmt.Errorf("invalid repo path: %w", err)
+	}
+	format := c.String("format")
+
+	switch format {
+	case "", "json":
+	default:
+		return fmt.Errorf("unknown diff format: %s (expected json)", format)
+	}
+
+	diffMode, err := git.ParseDiffMode(c.String("diff-mode"))
+	if err != nil {
+		return err
+	}
+
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	baseBranch := cfg.BaseBranch
+	if c.IsSet("base") {
+		baseBranch = c.String("base")
+	}
+	if baseBranch, err = gitRepo.ResolveBaseBranch(baseBranch); err != nil {
+		return err
+	}
+
+	opts := git.DiffOptions{IgnoreWhitespace: cfg.IgnoreWhitespace, GeneratedPatterns: cfg.GeneratedPatterns, Mode: diffMode}
+	diff, err := gitRepo.GetDiffFiles(baseBranch, opts)
+	if err != nil {
+		return err
+	}
+
+	// Uncommitted changes follow the branch's, each marked with its
+	// staging status
+	if c.Bool("uncommitted") {
+		uncommitted, err := gitRepo.GetUncommittedChanges(opts)
+		if err != nil {
+			return err
+		}
+		sort.Slice(uncommitted, func(i, j int) bool { return uncommitted[i].Path < uncommitted[j].Path })
+		diff.Files = append(diff.Files, uncommitted...)
+	}
+
+	if format == "json" {
+		return formatters.OutputJSON(diff)
+	}
+
+	if len(diff.Files) == 0 {
+		fmt.Printf("No changes compared to %s\n", baseBranch)
+		return nil
+	}
+	formatters.PrintAnnotatedDiff(os.Stdout, diff.Files, nil)
+	return nil
+}
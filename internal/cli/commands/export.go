@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tuist/guck/internal/export"
+	"github.com/tuist/guck/internal/state"
+	"github.com/urfave/cli/v2"
+)
+
+// Export handles the "guck export" command
+func Export(c *cli.Context) error {
+	repoPath, err := filepath.Abs(c.String("repo"))
+	if err != nil {
+		return fmt.Errorf("invalid repo path: %w", err)
+	}
+	outputPath, err := filepath.Abs(c.String("output"))
+	if err != nil {
+		return fmt.Errorf("invalid output path: %w", err)
+	}
+	format := c.String("format")
+
+	switch format {
+	case "", "json", "markdown", "md", "csv":
+	default:
+		return fmt.Errorf("unknown export format: %s (expected json, markdown or csv)", format)
+	}
+
+	stateMgr, err := state.NewManagerForRepo(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	switch format {
+	case "", "json":
+		err = export.ExportJSON(stateMgr, repoPath, outputPath)
+	case "markdown", "md":
+		err = export.ExportMarkdown(repoPath, stateMgr.GetAllComments(repoPath), stateMgr.GetAllNotes(repoPath), outputPath)
+	case "csv":
+		err = export.ExportCSV(stateMgr.GetAllComments(repoPath), stateMgr.GetAllNotes(repoPath), outputPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Exported review state for %s to %s\n", repoPath, outputPath)
+	return nil
+}
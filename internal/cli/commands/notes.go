@@ -3,6 +3,8 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
 
 	"github.com/tuist/guck/internal/cli/formatters"
 	"github.com/tuist/guck/internal/cli/helpers"
@@ -11,6 +13,30 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// lineFromPattern resolves the line of filePath at commit that matches pattern.
+// Multiple matches are an error unless firstMatch is set.
+func lineFromPattern(gitRepo *git.Repo, commit, filePath, pattern string, firstMatch bool) (int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --line-from-pattern: %w", err)
+	}
+
+	content, err := gitRepo.ReadFileAtCommit(commit, filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := git.MatchingLines(content, re)
+	switch {
+	case len(lines) == 0:
+		return 0, fmt.Errorf("pattern %q matched no lines in %s", pattern, filePath)
+	case len(lines) > 1 && !firstMatch:
+		return 0, fmt.Errorf("pattern %q matched %d lines in %s (lines %v); use --first-match or a more specific pattern", pattern, len(lines), filePath, lines)
+	}
+
+	return lines[0], nil
+}
+
 // AddNote handles the "guck notes add" command
 func AddNote(c *cli.Context) error {
 	repoPath := c.String("repo")
@@ -47,10 +73,20 @@ func AddNote(c *cli.Context) error {
 	}
 
 	// Handle line number
+	if c.IsSet("line") && c.IsSet("line-from-pattern") {
+		return fmt.Errorf("--line and --line-from-pattern cannot be used together")
+	}
 	if c.IsSet("line") {
 		line := c.Int("line")
 		params.LineNumber = &line
 	}
+	if pattern := c.String("line-from-pattern"); pattern != "" {
+		line, err := lineFromPattern(gitRepo, commit, filePath, pattern, c.Bool("first-match"))
+		if err != nil {
+			return err
+		}
+		params.LineNumber = &line
+	}
 
 	// Handle metadata
 	if c.IsSet("metadata") {
@@ -86,10 +122,15 @@ func ListNotes(c *cli.Context) error {
 	commit := c.String("commit")
 	filePath := c.String("file")
 	author := c.String("author")
+	search := c.String("search")
 	format := c.String("format")
 
 	params := mcp.ListNotesParams{
-		RepoPath: repoPath,
+		RepoPath:      repoPath,
+		FollowRenames: c.Bool("follow-renames"),
+		Sort:          c.String("sort"),
+		Limit:         c.Int("limit"),
+		Offset:        c.Int("offset"),
 	}
 
 	if branch != "" {
@@ -104,6 +145,30 @@ func ListNotes(c *cli.Context) error {
 	if author != "" {
 		params.Author = &author
 	}
+	if search != "" {
+		params.Search = &search
+	}
+	if authorKind := c.String("author-kind"); authorKind != "" {
+		params.AuthorKind = &authorKind
+	}
+	if since := c.String("since"); since != "" {
+		params.Since = &since
+	}
+	if until := c.String("until"); until != "" {
+		params.Until = &until
+	}
+
+	// Handle metadata filter
+	if c.IsSet("metadata") {
+		params.Metadata = make(map[string]string)
+		for _, pair := range c.StringSlice("metadata") {
+			parts := helpers.SplitKeyValue(pair)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid --metadata %q: expected key=value", pair)
+			}
+			params.Metadata[parts[0]] = parts[1]
+		}
+	}
 
 	// Handle dismissed filter
 	if c.Bool("dismissed") {
@@ -127,6 +192,40 @@ func ListNotes(c *cli.Context) error {
 	return formatters.OutputResult(result, format)
 }
 
+// NotesStats handles the "guck notes stats" command
+func NotesStats(c *cli.Context) error {
+	format := c.String("format")
+
+	params := mcp.NotesSummaryParams{
+		RepoPath: c.String("repo"),
+	}
+	if branch := c.String("branch"); branch != "" {
+		params.Branch = &branch
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.NotesSummary(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	if format == "" {
+		summary := result.(map[string]interface{})
+		counts := mcp.NoteCounts{
+			Total:     summary["total"].(int),
+			Active:    summary["active"].(int),
+			Dismissed: summary["dismissed"].(int),
+		}
+		formatters.PrintNotesSummary(os.Stdout, counts, summary["by_author"].(map[string]mcp.NoteCounts), summary["by_type"].(map[string]mcp.NoteCounts))
+		return nil
+	}
+	return formatters.OutputResult(result, format)
+}
+
 // DismissNote handles the "guck notes dismiss" command
 func DismissNote(c *cli.Context) error {
 	if c.NArg() != 1 {
@@ -156,3 +255,55 @@ func DismissNote(c *cli.Context) error {
 
 	return formatters.OutputResult(result, format)
 }
+
+// RestoreNote handles the "guck notes restore" command
+func RestoreNote(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("requires exactly 1 argument: note-id")
+	}
+
+	params := mcp.RestoreNoteParams{
+		RepoPath: c.String("repo"),
+		NoteID:   c.Args().Get(0),
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.RestoreNote(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	return formatters.OutputResult(result, c.String("format"))
+}
+
+// DeleteNote handles the "guck notes delete" command
+func DeleteNote(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("requires exactly 1 argument: note-id")
+	}
+
+	noteID := c.Args().Get(0)
+	repoPath := c.String("repo")
+	format := c.String("format")
+
+	params := mcp.DeleteNoteParams{
+		RepoPath: repoPath,
+		NoteID:   noteID,
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	result, err := mcp.DeleteNote(json.RawMessage(paramsJSON))
+	if err != nil {
+		return err
+	}
+
+	return formatters.OutputResult(result, format)
+}
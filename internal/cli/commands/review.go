@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tuist/guck/internal/cli/formatters"
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/git"
+	"github.com/tuist/guck/internal/state"
+	"github.com/urfave/cli/v2"
+)
+
+// DiffComments handles the "guck review diff-comments" command
+func DiffComments(c *cli.Context) error {
+	repoPath, err := filepath.Abs(c.String("repo"))
+	if err != nil {
+		return fmt.Errorf("invalid repo path: %w", err)
+	}
+	filePath := c.String("file")
+
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return err
+	}
+
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	commit, err := gitRepo.CurrentCommit()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	baseBranch := cfg.BaseBranch
+	if c.IsSet("base") {
+		baseBranch = c.String("base")
+	}
+
+	diff, err := gitRepo.GetDiffFiles(baseBranch, git.DiffOptions{IgnoreWhitespace: cfg.IgnoreWhitespace})
+	if err != nil {
+		return err
+	}
+
+	files := diff.Files
+	if filePath != "" {
+		files = []git.FileInfo{}
+		for _, file := range diff.Files {
+			if file.Path == filePath {
+				files = append(files, file)
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("%s has no changes compared to %s", filePath, baseBranch)
+		}
+	}
+
+	stateMgr, err := state.NewManagerForRepo(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var fileFilter *string
+	if filePath != "" {
+		fileFilter = &filePath
+	}
+	comments := stateMgr.GetComments(repoPath, branch, commit, fileFilter)
+
+	formatters.PrintAnnotatedDiff(os.Stdout, files, comments)
+	return nil
+}
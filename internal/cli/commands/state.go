@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/export"
+	"github.com/tuist/guck/internal/git"
+	"github.com/tuist/guck/internal/state"
+	"github.com/urfave/cli/v2"
+)
+
+// ExportAllState handles the "guck state export-all" command
+func ExportAllState(c *cli.Context) error {
+	outputDir := c.String("output")
+	if outputDir == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.ExportPath == "" {
+			return fmt.Errorf("--output is required unless export-path is configured")
+		}
+		outputDir = cfg.ExportPath
+	}
+
+	stateMgr, err := state.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	manifest, err := export.ExportAll(stateMgr, outputDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Exported %d repositories to %s\n", len(manifest.Repos), outputDir)
+	return nil
+}
+
+// ImportAllState handles the "guck state import-all" command
+func ImportAllState(c *cli.Context) error {
+	inputDir := c.String("input")
+
+	stateMgr, err := state.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	manifest, err := export.ImportAll(stateMgr, inputDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Imported %d repositories from %s\n", len(manifest.Repos), inputDir)
+	return nil
+}
+
+// VacuumState handles the "guck state vacuum" command
+func VacuumState(c *cli.Context) error {
+	stateMgr, err := state.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	stats, err := stateMgr.Vacuum()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✓ Vacuumed review state")
+	fmt.Printf("  Empty buckets removed:     %d\n", stats.EmptyBuckets)
+	fmt.Printf("  Duplicate viewed files:    %d\n", stats.DuplicateViewedFiles)
+	fmt.Printf("  Duplicate comments:        %d\n", stats.DuplicateComments)
+	fmt.Printf("  Duplicate notes:           %d\n", stats.DuplicateNotes)
+	fmt.Printf("  Size:                      %d → %d bytes\n", stats.BytesBefore, stats.BytesAfter)
+	return nil
+}
+
+// PruneState handles the "guck state prune" command. It removes the state of
+// commits the repository no longer has (--unreachable), of commits made
+// before --older-than, or, given only --repo, all of that repository's state.
+func PruneState(c *cli.Context) error {
+	unreachable := c.Bool("unreachable")
+	var cutoff time.Time
+	if value := c.String("older-than"); value != "" {
+		age, err := parseAge(value)
+		if err != nil {
+			return err
+		}
+		cutoff = time.Now().Add(-age)
+	}
+
+	repoPath := c.String("repo")
+	if repoPath == "" && !unreachable && cutoff.IsZero() {
+		return fmt.Errorf("nothing to prune: pass --unreachable, --older-than or --repo")
+	}
+
+	var stateMgr *state.Manager
+	var repoPaths []string
+	var err error
+	if repoPath != "" {
+		if repoPath, err = filepath.Abs(repoPath); err != nil {
+			return fmt.Errorf("invalid repo path: %w", err)
+		}
+		stateMgr, err = state.NewManagerForRepo(repoPath)
+		repoPaths = []string{repoPath}
+	} else {
+		stateMgr, err = state.NewManager()
+		if err == nil {
+			repoPaths = stateMgr.RepoPaths()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	total := 0
+	for _, path := range repoPaths {
+		keep := func(commit string) bool { return false }
+		if unreachable || !cutoff.IsZero() {
+			keep = commitFilter(path, unreachable, cutoff)
+		}
+
+		removed, err := stateMgr.PruneCommits(path, keep)
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			fmt.Printf("  %s: %d commit(s)\n", path, removed)
+		}
+		total += removed
+	}
+
+	fmt.Printf("✓ Pruned review state of %d commit(s)\n", total)
+	return nil
+}
+
+// commitFilter returns which commits of a repository to keep. Commits the
+// repository no longer has are dropped when unreachable is set, and commits
+// made before cutoff when it is set. The state of uncommitted changes is
+// always kept, and so is every commit of a repository that can't be opened
+// unless unreachable is set.
+func commitFilter(repoPath string, unreachable bool, cutoff time.Time) func(commit string) bool {
+	gitRepo, openErr := git.Open(repoPath)
+
+	// The same commit is often recorded under several branches
+	kept := make(map[string]bool)
+	return func(commit string) bool {
+		if commit == state.UncommittedCommit {
+			return true
+		}
+		if keep, ok := kept[commit]; ok {
+			return keep
+		}
+
+		keep := true
+		if openErr != nil {
+			keep = !unreachable
+		} else if committed, err := gitRepo.CommitTime(commit); err != nil {
+			keep = !unreachable
+		} else if !cutoff.IsZero() && committed.Before(cutoff) {
+			keep = false
+		}
+
+		kept[commit] = keep
+		return keep
+	}
+}
+
+// parseAge reads a duration such as 24h, or a number of days such as 30d
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid --older-than %q: must be a duration such as 24h or 30d", value)
+}
+
+// MigrateState handles the "guck state migrate" command
+func MigrateState(c *cli.Context) error {
+	var stateMgr *state.Manager
+	var err error
+	if repoPath := c.String("repo"); repoPath != "" {
+		stateMgr, err = state.NewManagerForRepo(repoPath)
+	} else {
+		stateMgr, err = state.NewManager()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	migrated, err := stateMgr.Migrate()
+	for _, file := range migrated {
+		fmt.Printf("  %s: version %d → %d (backup: %s)\n", file.Path, file.FromVersion, state.CurrentVersion, file.Backup)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Migrated %d state file(s) to version %d\n", len(migrated), state.CurrentVersion)
+	return nil
+}
@@ -0,0 +1,47 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tuist/guck/internal/daemon"
+)
+
+// PrintDaemonList writes one line per running daemon with its URL, the base
+// branch it compares against and its PID
+func PrintDaemonList(w io.Writer, daemons []*daemon.Info) {
+	infoColor.Fprintln(w, "Running daemons:")
+	for _, info := range daemons {
+		fmt.Fprintf(w, "  %s - ", info.RepoPath)
+		urlColor.Fprint(w, info.URL())
+		if info.BaseBranch != "" {
+			fmt.Fprintf(w, " (base: %s, PID: %d)\n", info.BaseBranch, info.PID)
+		} else {
+			fmt.Fprintf(w, " (PID: %d)\n", info.PID)
+		}
+	}
+}
+
+// PrintDaemonStatus writes the health, URL, PID, base branch and uptime of the
+// daemon registered for a repository
+func PrintDaemonStatus(w io.Writer, status *daemon.Status) {
+	infoColor.Fprintf(w, "Daemon for %s:\n", status.RepoPath)
+
+	fmt.Fprint(w, "  Health: ")
+	if status.Health == daemon.HealthRunning {
+		successColor.Fprintln(w, status.Health)
+	} else {
+		warningColor.Fprintln(w, status.Health)
+	}
+
+	fmt.Fprint(w, "  URL:    ")
+	urlColor.Fprintln(w, status.URL())
+	fmt.Fprintf(w, "  PID:    %d\n", status.PID)
+	if status.BaseBranch != "" {
+		fmt.Fprintf(w, "  Base:   %s\n", status.BaseBranch)
+	}
+	if status.UptimeSeconds > 0 {
+		fmt.Fprintf(w, "  Uptime: %s\n", time.Duration(status.UptimeSeconds)*time.Second)
+	}
+}
@@ -0,0 +1,25 @@
+package formatters
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tuist/guck/internal/daemon"
+)
+
+func TestPrintDaemonListShowsBase(t *testing.T) {
+	var buf bytes.Buffer
+	PrintDaemonList(&buf, []*daemon.Info{
+		{PID: 101, Port: 3001, RepoPath: "/repos/app", BaseBranch: "develop"},
+		{PID: 102, Port: 3002, RepoPath: "/repos/legacy"},
+	})
+	output := buf.String()
+
+	if !strings.Contains(output, "/repos/app - http://localhost:3001 (base: develop, PID: 101)") {
+		t.Errorf("Expected base branch in daemon list, got:\n%s", output)
+	}
+	if !strings.Contains(output, "/repos/legacy - http://localhost:3002 (PID: 102)") {
+		t.Errorf("Expected daemon without a recorded base, got:\n%s", output)
+	}
+}
@@ -0,0 +1,191 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tuist/guck/internal/mcp"
+)
+
+var commentColumns = []string{
+	"id", "file_path", "line_number", "end_line", "side", "text", "suggestion",
+	"timestamp", "branch", "commit", "resolved", "resolved_by", "resolved_at",
+	"parent_id", "author", "type", "metadata", "mentions",
+}
+
+var noteColumns = []string{
+	"id", "file_path", "line_number", "text", "timestamp", "branch", "commit",
+	"author", "type", "metadata", "dismissed", "dismissed_by", "dismissed_at",
+}
+
+// OutputTSV outputs comment and note lists as tab-separated tables with a
+// header row and every field, and other results as key-value rows
+func OutputTSV(result interface{}) error {
+	return writeDelimited(os.Stdout, result, '\t')
+}
+
+// OutputCSV outputs comment and note lists as comma-separated tables with a
+// header row and every field, and other results as key-value rows
+func OutputCSV(result interface{}) error {
+	return writeDelimited(os.Stdout, result, ',')
+}
+
+// OutputCommentResultsAsTSV outputs typed comments as TSV
+func OutputCommentResultsAsTSV(comments []mcp.CommentResult) error {
+	return writeRecords(os.Stdout, commentRecords(comments), '\t')
+}
+
+// OutputNoteResultsAsTSV outputs typed notes as TSV
+func OutputNoteResultsAsTSV(notes []mcp.NoteResult) error {
+	return writeRecords(os.Stdout, noteRecords(notes), '\t')
+}
+
+// writeDelimited writes result as records separated by comma, quoting
+// fields as encoding/csv does
+func writeDelimited(w io.Writer, result interface{}, comma rune) error {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot convert result to delimited format")
+	}
+
+	if commentsRaw, ok := resultMap["comments"]; ok {
+		var comments []mcp.CommentResult
+		if err := convertList(commentsRaw, &comments); err != nil {
+			return err
+		}
+		return writeRecords(w, commentRecords(comments), comma)
+	}
+
+	if notesRaw, ok := resultMap["notes"]; ok {
+		var notes []mcp.NoteResult
+		if err := convertList(notesRaw, &notes); err != nil {
+			return err
+		}
+		return writeRecords(w, noteRecords(notes), comma)
+	}
+
+	// For simple results, output sorted key-value pairs
+	keys := make([]string, 0, len(resultMap))
+	for k := range resultMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	records := [][]string{{"key", "value"}}
+	for _, k := range keys {
+		records = append(records, []string{k, fmt.Sprintf("%v", resultMap[k])})
+	}
+	return writeRecords(w, records, comma)
+}
+
+// convertList fills target from a typed slice or, through JSON, from a
+// decoded []interface{}
+func convertList(raw interface{}, target interface{}) error {
+	switch list := raw.(type) {
+	case []mcp.CommentResult:
+		if comments, ok := target.(*[]mcp.CommentResult); ok {
+			*comments = list
+			return nil
+		}
+	case []mcp.NoteResult:
+		if notes, ok := target.(*[]mcp.NoteResult); ok {
+			*notes = list
+			return nil
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func writeRecords(w io.Writer, records [][]string, comma rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	return writer.WriteAll(records)
+}
+
+// commentRecords returns a header row followed by a row per comment
+func commentRecords(comments []mcp.CommentResult) [][]string {
+	records := [][]string{commentColumns}
+	for _, comment := range comments {
+		records = append(records, []string{
+			comment.ID,
+			comment.FilePath,
+			optionalInt(comment.LineNumber),
+			optionalInt(comment.EndLine),
+			comment.Side,
+			comment.Text,
+			comment.Suggestion,
+			strconv.FormatInt(comment.Timestamp, 10),
+			comment.Branch,
+			comment.Commit,
+			strconv.FormatBool(comment.Resolved),
+			comment.ResolvedBy,
+			optionalTimestamp(comment.ResolvedAt),
+			comment.ParentID,
+			comment.Author,
+			comment.Type,
+			joinMetadata(comment.Metadata),
+			strings.Join(comment.Mentions, ";"),
+		})
+	}
+	return records
+}
+
+// noteRecords returns a header row followed by a row per note
+func noteRecords(notes []mcp.NoteResult) [][]string {
+	records := [][]string{noteColumns}
+	for _, note := range notes {
+		records = append(records, []string{
+			note.ID,
+			note.FilePath,
+			optionalInt(note.LineNumber),
+			note.Text,
+			strconv.FormatInt(note.Timestamp, 10),
+			note.Branch,
+			note.Commit,
+			note.Author,
+			note.Type,
+			joinMetadata(note.Metadata),
+			strconv.FormatBool(note.Dismissed),
+			note.DismissedBy,
+			optionalTimestamp(note.DismissedAt),
+		})
+	}
+	return records
+}
+
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+// optionalTimestamp leaves unset (zero) timestamps empty
+func optionalTimestamp(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// joinMetadata formats metadata as key=value pairs separated by semicolons,
+// sorted by key
+func joinMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
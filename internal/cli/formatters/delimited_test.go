@@ -0,0 +1,113 @@
+package formatters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tuist/guck/internal/mcp"
+)
+
+func TestWriteDelimitedCommentsAsCSV(t *testing.T) {
+	line, endLine := 42, 44
+	result := map[string]interface{}{
+		"comments": []mcp.CommentResult{
+			{
+				ID:         "1-0",
+				FilePath:   "main.go",
+				LineNumber: &line,
+				EndLine:    &endLine,
+				Text:       "Handle the error, \"please\"\nand log it",
+				Timestamp:  1700000000,
+				Branch:     "main",
+				Commit:     "abc123",
+				Resolved:   true,
+				ResolvedBy: "alice",
+				ResolvedAt: 1700000100,
+				Author:     "human:bob",
+				Metadata:   map[string]string{"severity": "high", "area": "errors"},
+				Mentions:   []string{"alice", "carol"},
+			},
+		},
+		"count": 1,
+	}
+
+	var out strings.Builder
+	if err := writeDelimited(&out, result, ','); err != nil {
+		t.Fatalf("writeDelimited failed: %v", err)
+	}
+
+	expected := "id,file_path,line_number,end_line,side,text,suggestion,timestamp,branch,commit,resolved,resolved_by,resolved_at,parent_id,author,type,metadata,mentions\n" +
+		"1-0,main.go,42,44,,\"Handle the error, \"\"please\"\"\nand log it\",,1700000000,main,abc123,true,alice,1700000100,,human:bob,,area=errors;severity=high,alice;carol\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteDelimitedNotesFromDecodedJSON(t *testing.T) {
+	// Results decoded from JSON hold plain maps rather than typed notes
+	result := map[string]interface{}{
+		"notes": []interface{}{
+			map[string]interface{}{
+				"id":        "2-0",
+				"file_path": "util.go",
+				"text":      "Explains the retry loop",
+				"timestamp": float64(1700000000),
+				"author":    "claude",
+				"type":      "explanation",
+				"dismissed": false,
+			},
+		},
+	}
+
+	var out strings.Builder
+	if err := writeDelimited(&out, result, '\t'); err != nil {
+		t.Fatalf("writeDelimited failed: %v", err)
+	}
+
+	expected := "id\tfile_path\tline_number\ttext\ttimestamp\tbranch\tcommit\tauthor\ttype\tmetadata\tdismissed\tdismissed_by\tdismissed_at\n" +
+		"2-0\tutil.go\t\tExplains the retry loop\t1700000000\t\t\tclaude\texplanation\t\tfalse\t\t\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, out.String())
+	}
+}
+
+func TestWriteDelimitedEmptyListKeepsHeader(t *testing.T) {
+	var out strings.Builder
+	err := writeDelimited(&out, map[string]interface{}{"comments": []mcp.CommentResult{}}, ',')
+	if err != nil {
+		t.Fatalf("writeDelimited failed: %v", err)
+	}
+
+	if out.String() != strings.Join(commentColumns, ",")+"\n" {
+		t.Errorf("Expected only the header row, got %q", out.String())
+	}
+}
+
+func TestWriteDelimitedSimpleResult(t *testing.T) {
+	var out strings.Builder
+	err := writeDelimited(&out, map[string]interface{}{"success": true, "comment_id": "1-0"}, ',')
+	if err != nil {
+		t.Fatalf("writeDelimited failed: %v", err)
+	}
+
+	expected := "key,value\ncomment_id,1-0\nsuccess,true\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWriteDelimitedKeepsLongText(t *testing.T) {
+	text := strings.Repeat("A long AI-generated explanation. ", 20)
+	result := map[string]interface{}{
+		"notes": []mcp.NoteResult{{ID: "2-0", FilePath: "main.go", Text: text}},
+	}
+
+	var out strings.Builder
+	if err := writeDelimited(&out, result, '\t'); err != nil {
+		t.Fatalf("writeDelimited failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "\t"+text+"\t") {
+		t.Errorf("Expected the whole note text, got %q", out.String())
+	}
+}
@@ -0,0 +1,136 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tuist/guck/internal/git"
+	"github.com/tuist/guck/internal/state"
+)
+
+var (
+	addedColor   = color.New(color.FgGreen)
+	removedColor = color.New(color.FgRed)
+	resolvedDim  = color.New(color.Faint)
+)
+
+// hunkHeader captures the starting line of the new side of a hunk
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// PrintAnnotatedDiff writes the unified diff of each file with its comments
+// injected beneath the lines they refer to. File-level comments follow the
+// file header and comments on lines outside the diff are listed after it.
+// Resolved comments are dimmed.
+func PrintAnnotatedDiff(w io.Writer, files []git.FileInfo, comments []*state.Comment) {
+	byFile := make(map[string][]*state.Comment)
+	for _, comment := range comments {
+		byFile[comment.FilePath] = append(byFile[comment.FilePath], comment)
+	}
+
+	for i, file := range files {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		urlColor.Fprint(w, file.Path)
+		status := file.Status
+		if file.StagingStatus != "" {
+			// Uncommitted changes say whether they are staged
+			status += ", " + string(file.StagingStatus)
+		}
+		fmt.Fprintf(w, " (%s, +%d -%d)\n", status, file.Additions, file.Deletions)
+
+		fileComments := byFile[file.Path]
+		sort.SliceStable(fileComments, func(a, b int) bool {
+			return fileComments[a].Timestamp < fileComments[b].Timestamp
+		})
+
+		byLine := make(map[int][]*state.Comment)
+		for _, comment := range fileComments {
+			if comment.LineNumber == nil {
+				printInlineComment(w, comment)
+				continue
+			}
+			byLine[*comment.LineNumber] = append(byLine[*comment.LineNumber], comment)
+		}
+
+		newLine := 0
+		for _, line := range strings.Split(strings.TrimSuffix(file.Patch, "\n"), "\n") {
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				if match := hunkHeader.FindStringSubmatch(line); match != nil {
+					newLine, _ = strconv.Atoi(match[1])
+				}
+				infoColor.Fprintln(w, line)
+				continue
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+				fmt.Fprintln(w, line)
+				continue
+			case strings.HasPrefix(line, "-"):
+				removedColor.Fprintln(w, line)
+				continue
+			case strings.HasPrefix(line, "+"):
+				addedColor.Fprintln(w, line)
+			case strings.HasPrefix(line, " "):
+				fmt.Fprintln(w, line)
+			default:
+				// "\ No newline at end of file" and other metadata
+				fmt.Fprintln(w, line)
+				continue
+			}
+
+			if newLine > 0 {
+				for _, comment := range byLine[newLine] {
+					printInlineComment(w, comment)
+				}
+				delete(byLine, newLine)
+				newLine++
+			}
+		}
+
+		if len(byLine) > 0 {
+			lines := make([]int, 0, len(byLine))
+			for line := range byLine {
+				lines = append(lines, line)
+			}
+			sort.Ints(lines)
+
+			warningColor.Fprintln(w, "  Comments outside the diff:")
+			for _, line := range lines {
+				for _, comment := range byLine[line] {
+					printInlineComment(w, comment)
+				}
+			}
+		}
+	}
+}
+
+func printInlineComment(w io.Writer, comment *state.Comment) {
+	location := "file"
+	if comment.LineNumber != nil {
+		location = fmt.Sprintf("line %d", *comment.LineNumber)
+	}
+
+	author := comment.Author
+	if author == "" {
+		author = "unknown"
+	}
+
+	header := fmt.Sprintf("    💬 [%s] %s on %s", ShortID(comment.ID), author, location)
+	if comment.Resolved {
+		header += " (resolved)"
+	}
+
+	c := warningColor
+	if comment.Resolved {
+		c = resolvedDim
+	}
+	c.Fprintln(w, header)
+	for _, line := range strings.Split(comment.Text, "\n") {
+		c.Fprintf(w, "       %s\n", line)
+	}
+}
@@ -0,0 +1,63 @@
+package formatters
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tuist/guck/internal/git"
+	"github.com/tuist/guck/internal/state"
+)
+
+func TestPrintAnnotatedDiff(t *testing.T) {
+	patch := "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" package main\n" +
+		"-var x = 1\n" +
+		"+var x = 2\n" +
+		" func main() {}\n"
+	files := []git.FileInfo{{Path: "main.go", Status: "modified", Additions: 1, Deletions: 1, Patch: patch}}
+
+	line2, line40 := 2, 40
+	comments := []*state.Comment{
+		{ID: "c1", FilePath: "main.go", LineNumber: &line2, Text: "Why 2?", Author: "alice"},
+		{ID: "c2", FilePath: "main.go", Text: "File-level note", Author: "bob", Resolved: true},
+		{ID: "c3", FilePath: "main.go", LineNumber: &line40, Text: "Far away"},
+		{ID: "c4", FilePath: "other.go", LineNumber: &line2, Text: "Not shown"},
+	}
+
+	var buf bytes.Buffer
+	PrintAnnotatedDiff(&buf, files, comments)
+	output := buf.String()
+
+	// The comment follows the added line 2, before the next context line
+	if !strings.Contains(output, "+var x = 2\n    💬 [c1] alice on line 2\n       Why 2?\n func main() {}") {
+		t.Errorf("Expected comment beneath line 2, got:\n%s", output)
+	}
+	if !strings.Contains(output, "💬 [c2] bob on file (resolved)") {
+		t.Errorf("Expected resolved file-level comment, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Comments outside the diff:\n    💬 [c3] unknown on line 40") {
+		t.Errorf("Expected comment outside the diff to be listed, got:\n%s", output)
+	}
+	if strings.Contains(output, "Not shown") {
+		t.Errorf("Expected comments on other files to be skipped, got:\n%s", output)
+	}
+}
+
+func TestPrintAnnotatedDiffStagingStatus(t *testing.T) {
+	files := []git.FileInfo{
+		{Path: "main.go", Status: "modified", Additions: 1, Patch: "@@ -1 +1,2 @@\n x\n+y\n"},
+		{Path: "main.go", Status: "modified", Additions: 1, Patch: "@@ -1,2 +1,3 @@\n x\n y\n+z\n", StagingStatus: git.StagingStatusUnstaged},
+	}
+
+	var buf bytes.Buffer
+	PrintAnnotatedDiff(&buf, files, nil)
+	output := buf.String()
+
+	if !strings.Contains(output, "main.go (modified, +1 -0)\n") || !strings.Contains(output, "main.go (modified, unstaged, +1 -0)\n") {
+		t.Errorf("Expected the unstaged change to be labeled, got:\n%s", output)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/tuist/guck/internal/mcp"
+	"github.com/tuist/guck/internal/state"
 )
 
 var (
@@ -16,13 +18,33 @@ var (
 	urlColor     = color.New(color.FgBlue, color.Underline)
 )
 
+// IDDisplayLength is how many characters of comment and note IDs the
+// human-readable output shows; 0 or less shows full IDs
+var IDDisplayLength = 8
+
+// ShortID truncates an ID to IDDisplayLength, leaving shorter IDs untouched
+func ShortID(id string) string {
+	if IDDisplayLength <= 0 || len(id) <= IDDisplayLength {
+		return id
+	}
+	return id[:IDDisplayLength]
+}
+
 // OutputResult formats and outputs the result based on the specified format
 func OutputResult(result interface{}, format string) error {
 	switch format {
 	case "json":
 		return OutputJSON(result)
+	case "jsonl":
+		return OutputJSONL(result)
 	case "toon":
 		return OutputToon(result)
+	case "tsv":
+		return OutputTSV(result)
+	case "csv":
+		return OutputCSV(result)
+	case "github":
+		return OutputGitHub(result)
 	default:
 		return OutputHumanReadable(result)
 	}
@@ -35,42 +57,116 @@ func OutputJSON(result interface{}) error {
 	return encoder.Encode(result)
 }
 
-// OutputToon outputs the result in Toon format (tab-separated tables)
-func OutputToon(result interface{}) error {
+// OutputJSONL outputs each comment or note of a list result as its own
+// compact JSON line, for line-oriented tools such as jq -c. Other results
+// are written as a single line.
+func OutputJSONL(result interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+
+	if resultMap, ok := result.(map[string]interface{}); ok {
+		if comments, ok := resultMap["comments"].([]mcp.CommentResult); ok {
+			return encodeLines(encoder, comments)
+		}
+		if notes, ok := resultMap["notes"].([]mcp.NoteResult); ok {
+			return encodeLines(encoder, notes)
+		}
+	}
+
+	return encoder.Encode(result)
+}
+
+// encodeLines writes each item on its own line
+func encodeLines[T any](encoder *json.Encoder, items []T) error {
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GitHubReviewComment is a single entry of the "comments" array accepted by
+// GitHub's "create a review for a pull request" API
+type GitHubReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side"`
+	// StartLine and StartSide begin a multi-line comment ending at Line
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+	Body      string `json:"body"`
+}
+
+// OutputGitHub outputs unresolved comments as a GitHub review comments payload.
+// Resolved comments and comments without a line number are skipped, since
+// GitHub review comments must be anchored to a line.
+func OutputGitHub(result interface{}) error {
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return fmt.Errorf("cannot convert result to toon format")
+		return fmt.Errorf("cannot convert result to github format")
 	}
 
-	// Check if it's a list result with typed comments
-	if commentsRaw, ok := resultMap["comments"]; ok {
-		// Try typed slice first
-		if comments, ok := commentsRaw.([]mcp.CommentResult); ok {
-			return OutputCommentResultsAsToon(comments)
+	comments, ok := resultMap["comments"].([]mcp.CommentResult)
+	if !ok {
+		return fmt.Errorf("github format is only supported for comments")
+	}
+
+	return OutputJSON(GitHubReviewComments(comments))
+}
+
+// GitHubReviewComments maps guck comments to GitHub review comments
+func GitHubReviewComments(comments []mcp.CommentResult) []GitHubReviewComment {
+	reviewComments := []GitHubReviewComment{}
+	for _, comment := range comments {
+		if comment.Resolved || comment.LineNumber == nil {
+			continue
 		}
-		// Try interface slice
-		if comments, ok := commentsRaw.([]interface{}); ok {
-			return outputCommentsAsToon(comments)
+
+		side := "RIGHT"
+		if comment.Side == state.SideOld {
+			side = "LEFT"
 		}
-	}
 
-	// Check if it's a list result with typed notes
-	if notesRaw, ok := resultMap["notes"]; ok {
-		// Try typed slice first
-		if notes, ok := notesRaw.([]mcp.NoteResult); ok {
-			return OutputNoteResultsAsToon(notes)
+		reviewComment := GitHubReviewComment{
+			Path: comment.FilePath,
+			Line: *comment.LineNumber,
+			Side: side,
+			Body: githubCommentBody(comment),
 		}
-		// Try interface slice
-		if notes, ok := notesRaw.([]interface{}); ok {
-			return outputNotesAsToon(notes)
+		if comment.EndLine != nil {
+			reviewComment.StartLine, reviewComment.StartSide = *comment.LineNumber, side
+			reviewComment.Line = *comment.EndLine
+		}
+		reviewComments = append(reviewComments, reviewComment)
+	}
+	return reviewComments
+}
+
+// printCodeContext prints a comment's code context, marking the commented line
+func printCodeContext(context *mcp.CodeContext, lineNumber int) {
+	for i, line := range context.Lines {
+		number := context.StartLine + i
+		marker := " "
+		if number == lineNumber {
+			marker = ">"
 		}
+		infoColor.Printf("  %s %4d | ", marker, number)
+		fmt.Println(line)
 	}
+}
 
-	// For simple results, just output as key-value pairs
-	for k, v := range resultMap {
-		fmt.Printf("%s\t%v\n", k, v)
+// githubCommentBody appends the comment's suggestion, if any, as a GitHub
+// suggested-change block
+func githubCommentBody(comment mcp.CommentResult) string {
+	if comment.Suggestion == "" {
+		return comment.Text
 	}
-	return nil
+
+	block := "```suggestion\n" + strings.TrimSuffix(comment.Suggestion, "\n") + "\n```"
+	if comment.Text == "" {
+		return block
+	}
+	return comment.Text + "\n\n" + block
 }
 
 // OutputHumanReadable outputs the result in a human-friendly format with colors
@@ -82,8 +178,7 @@ func OutputHumanReadable(result interface{}) error {
 
 	// Check if it's a list result with comments
 	if comments, ok := resultMap["comments"].([]mcp.CommentResult); ok {
-		count := resultMap["count"]
-		infoColor.Printf("Found %v comment(s):\n\n", count)
+		printListHeader(resultMap, "comment")
 
 		for _, comment := range comments {
 			if comment.Resolved {
@@ -92,14 +187,28 @@ func OutputHumanReadable(result interface{}) error {
 				warningColor.Print("• ")
 			}
 
-			fmt.Printf("[%s] ", comment.ID[:8])
+			fmt.Printf("[%s] ", ShortID(comment.ID))
 			urlColor.Print(comment.FilePath)
 			if comment.LineNumber != nil {
-				fmt.Printf(":%d", *comment.LineNumber)
+				fmt.Printf(":%s", lineRange(comment.LineNumber, comment.EndLine))
+			}
+			if comment.Author != "" {
+				fmt.Printf(" (%s)", comment.Author)
 			}
 			fmt.Println()
 
-			fmt.Printf("  %s\n", comment.Text)
+			if comment.Text != "" {
+				fmt.Printf("  %s\n", comment.Text)
+			}
+			if comment.Context != nil {
+				printCodeContext(comment.Context, *comment.LineNumber)
+			}
+			if comment.Suggestion != "" {
+				infoColor.Println("  Suggested change:")
+				for _, line := range strings.Split(strings.TrimSuffix(comment.Suggestion, "\n"), "\n") {
+					fmt.Printf("    %s\n", line)
+				}
+			}
 
 			if comment.Resolved {
 				infoColor.Printf("  Resolved by %s\n", comment.ResolvedBy)
@@ -111,8 +220,7 @@ func OutputHumanReadable(result interface{}) error {
 
 	// Check if it's a list result with notes
 	if notes, ok := resultMap["notes"].([]mcp.NoteResult); ok {
-		count := resultMap["count"]
-		infoColor.Printf("Found %v note(s):\n\n", count)
+		printListHeader(resultMap, "note")
 
 		for _, note := range notes {
 			if note.Dismissed {
@@ -121,7 +229,7 @@ func OutputHumanReadable(result interface{}) error {
 				infoColor.Print("📝 ")
 			}
 
-			fmt.Printf("[%s] ", note.ID[:8])
+			fmt.Printf("[%s] ", ShortID(note.ID))
 			urlColor.Print(note.FilePath)
 			if note.LineNumber != nil {
 				fmt.Printf(":%d", *note.LineNumber)
@@ -154,113 +262,21 @@ func OutputHumanReadable(result interface{}) error {
 	return OutputJSON(result)
 }
 
-// OutputCommentResultsAsToon outputs typed comments in Toon format
-func OutputCommentResultsAsToon(comments []mcp.CommentResult) error {
-	if len(comments) == 0 {
-		fmt.Println("# No comments found")
-		return nil
-	}
-
-	fmt.Println("id\tfile\tline\tresolved\ttext")
-	for _, comment := range comments {
-		id := comment.ID
-		file := comment.FilePath
-		line := ""
-		if comment.LineNumber != nil {
-			line = fmt.Sprintf("%d", *comment.LineNumber)
-		}
-		resolved := comment.Resolved
-		text := truncate(comment.Text, 50)
-
-		fmt.Printf("%s\t%s\t%s\t%v\t%s\n", id, file, line, resolved, text)
-	}
-	return nil
-}
-
-func outputCommentsAsToon(comments []interface{}) error {
-	if len(comments) == 0 {
-		fmt.Println("# No comments found")
-		return nil
-	}
-
-	fmt.Println("id\tfile\tline\tresolved\ttext")
-	for _, item := range comments {
-		comment, ok := item.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		id := comment["id"]
-		file := comment["file_path"]
-		line := ""
-		if ln, ok := comment["line_number"]; ok && ln != nil {
-			line = fmt.Sprintf("%v", ln)
-		}
-		resolved := comment["resolved"]
-		text := truncate(fmt.Sprintf("%v", comment["text"]), 50)
-
-		fmt.Printf("%s\t%s\t%s\t%v\t%s\n", id, file, line, resolved, text)
-	}
-	return nil
-}
-
-// OutputNoteResultsAsToon outputs typed notes in Toon format
-func OutputNoteResultsAsToon(notes []mcp.NoteResult) error {
-	if len(notes) == 0 {
-		fmt.Println("# No notes found")
-		return nil
-	}
-
-	fmt.Println("id\tfile\tline\tauthor\ttype\tdismissed\ttext")
-	for _, note := range notes {
-		id := note.ID
-		file := note.FilePath
-		line := ""
-		if note.LineNumber != nil {
-			line = fmt.Sprintf("%d", *note.LineNumber)
-		}
-		author := note.Author
-		noteType := note.Type
-		dismissed := note.Dismissed
-		text := truncate(note.Text, 50)
-
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%v\t%s\n", id, file, line, author, noteType, dismissed, text)
-	}
-	return nil
-}
-
-func outputNotesAsToon(notes []interface{}) error {
-	if len(notes) == 0 {
-		fmt.Println("# No notes found")
-		return nil
-	}
-
-	fmt.Println("id\tfile\tline\tauthor\ttype\tdismissed\ttext")
-	for _, item := range notes {
-		note, ok := item.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		id := note["id"]
-		file := note["file_path"]
-		line := ""
-		if ln, ok := note["line_number"]; ok && ln != nil {
-			line = fmt.Sprintf("%v", ln)
-		}
-		author := note["author"]
-		noteType := note["type"]
-		dismissed := note["dismissed"]
-		text := truncate(fmt.Sprintf("%v", note["text"]), 50)
-
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%v\t%s\n", id, file, line, author, noteType, dismissed, text)
+// printListHeader announces how many items a list result holds, and how many
+// matched in total when it is a page
+func printListHeader(resultMap map[string]interface{}, noun string) {
+	count := resultMap["count"]
+	if total, ok := resultMap["total"]; ok && total != count {
+		infoColor.Printf("Showing %v of %v %s(s):\n\n", count, total, noun)
+		return
 	}
-	return nil
+	infoColor.Printf("Found %v %s(s):\n\n", count, noun)
 }
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// lineRange formats a comment's line, or its span of lines as start-end
+func lineRange(lineNumber, endLine *int) string {
+	if endLine != nil {
+		return fmt.Sprintf("%d-%d", *lineNumber, *endLine)
 	}
-	return s[:maxLen-3] + "..."
+	return fmt.Sprintf("%d", *lineNumber)
 }
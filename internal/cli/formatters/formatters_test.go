@@ -1,7 +1,11 @@
 package formatters
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/tuist/guck/internal/mcp"
@@ -37,7 +41,7 @@ func TestOutputJSON(t *testing.T) {
 	}
 }
 
-func TestOutputCommentResultsAsToon(t *testing.T) {
+func TestOutputCommentResultsAsTSV(t *testing.T) {
 	line := 42
 	comments := []mcp.CommentResult{
 		{
@@ -60,9 +64,9 @@ func TestOutputCommentResultsAsToon(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	err := OutputCommentResultsAsToon(comments)
+	err := OutputCommentResultsAsTSV(comments)
 	if err != nil {
-		t.Fatalf("OutputCommentResultsAsToon failed: %v", err)
+		t.Fatalf("OutputCommentResultsAsTSV failed: %v", err)
 	}
 
 	w.Close()
@@ -79,7 +83,7 @@ func TestOutputCommentResultsAsToon(t *testing.T) {
 	}
 
 	// Should contain header
-	if !contains(output, "id\tfile\tline\tresolved\ttext") {
+	if !contains(output, "id\tfile_path\tline_number\tend_line\tside\ttext") {
 		t.Error("Output missing header")
 	}
 
@@ -92,7 +96,7 @@ func TestOutputCommentResultsAsToon(t *testing.T) {
 	}
 }
 
-func TestOutputNoteResultsAsToon(t *testing.T) {
+func TestOutputNoteResultsAsTSV(t *testing.T) {
 	line := 100
 	notes := []mcp.NoteResult{
 		{
@@ -111,9 +115,9 @@ func TestOutputNoteResultsAsToon(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	err := OutputNoteResultsAsToon(notes)
+	err := OutputNoteResultsAsTSV(notes)
 	if err != nil {
-		t.Fatalf("OutputNoteResultsAsToon failed: %v", err)
+		t.Fatalf("OutputNoteResultsAsTSV failed: %v", err)
 	}
 
 	w.Close()
@@ -136,23 +140,95 @@ func TestOutputNoteResultsAsToon(t *testing.T) {
 	}
 }
 
-func TestTruncate(t *testing.T) {
-	tests := []struct {
-		input    string
-		maxLen   int
-		expected string
-	}{
-		{"short", 10, "short"},
-		{"exactly ten!", 12, "exactly ten!"},
-		{"this is a very long string that needs truncating", 20, "this is a very lo..."},
-		{"", 10, ""},
-	}
-
-	for _, tt := range tests {
-		result := truncate(tt.input, tt.maxLen)
-		if result != tt.expected {
-			t.Errorf("truncate(%q, %d) = %q, want %q", tt.input, tt.maxLen, result, tt.expected)
-		}
+func TestGitHubReviewComments(t *testing.T) {
+	line := 12
+	comments := []mcp.CommentResult{
+		{
+			ID:         "open",
+			FilePath:   "main.go",
+			LineNumber: &line,
+			Text:       "Please handle this error",
+		},
+		{
+			ID:         "resolved",
+			FilePath:   "main.go",
+			LineNumber: &line,
+			Text:       "Already fixed",
+			Resolved:   true,
+		},
+		{
+			ID:       "file-level",
+			FilePath: "README.md",
+			Text:     "No line number",
+		},
+	}
+
+	reviewComments := GitHubReviewComments(comments)
+
+	if len(reviewComments) != 1 {
+		t.Fatalf("Expected 1 review comment, got %d", len(reviewComments))
+	}
+
+	rc := reviewComments[0]
+	if rc.Path != "main.go" || rc.Line != 12 || rc.Side != "RIGHT" || rc.Body != "Please handle this error" {
+		t.Errorf("Unexpected review comment: %+v", rc)
+	}
+}
+
+func TestGitHubReviewCommentsOldSide(t *testing.T) {
+	line, endLine := 8, 10
+	comments := []mcp.CommentResult{
+		{
+			ID:         "deleted",
+			FilePath:   "main.go",
+			LineNumber: &line,
+			EndLine:    &endLine,
+			Side:       "old",
+			Text:       "This check is still needed",
+		},
+	}
+
+	reviewComments := GitHubReviewComments(comments)
+	if len(reviewComments) != 1 {
+		t.Fatalf("Expected 1 review comment, got %d", len(reviewComments))
+	}
+
+	rc := reviewComments[0]
+	if rc.Side != "LEFT" || rc.StartSide != "LEFT" || rc.StartLine != 8 || rc.Line != 10 {
+		t.Errorf("Expected a LEFT review comment on lines 8-10, got %+v", rc)
+	}
+}
+
+func TestGitHubReviewCommentsWithSuggestion(t *testing.T) {
+	line := 3
+	comments := []mcp.CommentResult{
+		{
+			ID:         "suggested",
+			FilePath:   "main.go",
+			LineNumber: &line,
+			Text:       "Use a constant",
+			Suggestion: "const limit = 10\n",
+		},
+	}
+
+	reviewComments := GitHubReviewComments(comments)
+	if len(reviewComments) != 1 {
+		t.Fatalf("Expected 1 review comment, got %d", len(reviewComments))
+	}
+
+	expected := "Use a constant\n\n```suggestion\nconst limit = 10\n```"
+	if reviewComments[0].Body != expected {
+		t.Errorf("Expected body %q, got %q", expected, reviewComments[0].Body)
+	}
+}
+
+func TestOutputGitHubRejectsNotes(t *testing.T) {
+	result := map[string]interface{}{
+		"notes": []mcp.NoteResult{},
+	}
+
+	if err := OutputGitHub(result); err == nil {
+		t.Error("Expected error when formatting notes as github")
 	}
 }
 
@@ -164,3 +240,83 @@ func contains(haystack, needle string) bool {
 	}
 	return false
 }
+
+func TestShortID(t *testing.T) {
+	defer func(length int) { IDDisplayLength = length }(IDDisplayLength)
+
+	IDDisplayLength = 8
+	if got := ShortID("1234567890-0"); got != "12345678" {
+		t.Errorf("Expected 12345678, got %s", got)
+	}
+	if got := ShortID("abc"); got != "abc" {
+		t.Errorf("Expected short ID to be kept, got %s", got)
+	}
+
+	IDDisplayLength = 0
+	if got := ShortID("1234567890-0"); got != "1234567890-0" {
+		t.Errorf("Expected full ID, got %s", got)
+	}
+}
+
+func TestOutputHumanReadableShortIDs(t *testing.T) {
+	result := map[string]interface{}{
+		"comments": []mcp.CommentResult{{ID: "abc", FilePath: "main.go", Text: "Legacy comment"}},
+		"count":    1,
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := OutputHumanReadable(result)
+
+	w.Close()
+	os.Stdout = old
+
+	if err != nil {
+		t.Fatalf("OutputHumanReadable failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(r)
+	if !strings.Contains(string(output), "[abc]") {
+		t.Errorf("Expected output to contain the full short ID, got %q", output)
+	}
+}
+
+func TestOutputJSONL(t *testing.T) {
+	result := map[string]interface{}{
+		"notes": []mcp.NoteResult{
+			{ID: "note-1", FilePath: "main.go", Text: "First", Author: "claude"},
+			{ID: "note-2", FilePath: "util.go", Text: "Second", Author: "claude"},
+		},
+		"count": 2,
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := OutputResult(result, "jsonl")
+
+	w.Close()
+	os.Stdout = old
+
+	if err != nil {
+		t.Fatalf("OutputJSONL failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(r)
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per note, got %q", output)
+	}
+	for i, line := range lines {
+		var note mcp.NoteResult
+		if err := json.Unmarshal([]byte(line), &note); err != nil {
+			t.Fatalf("Line %d is not JSON: %v", i, err)
+		}
+		if note.ID != fmt.Sprintf("note-%d", i+1) {
+			t.Errorf("Expected note-%d on line %d, got %s", i+1, i, note.ID)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tuist/guck/internal/mcp"
+)
+
+// PrintNotesSummary writes the number of notes overall, then per author and
+// per type, each with how many are still active
+func PrintNotesSummary(w io.Writer, counts mcp.NoteCounts, byAuthor, byType map[string]mcp.NoteCounts) {
+	if counts.Total == 0 {
+		infoColor.Fprintln(w, "No notes found")
+		return
+	}
+
+	infoColor.Fprintf(w, "%d note(s): ", counts.Total)
+	fmt.Fprintf(w, "%d active, %d dismissed\n", counts.Active, counts.Dismissed)
+	printNoteCountGroup(w, "By author", byAuthor)
+	printNoteCountGroup(w, "By type", byType)
+}
+
+// printNoteCountGroup writes a heading and one line per key, most notes first
+func printNoteCountGroup(w io.Writer, heading string, groups map[string]mcp.NoteCounts) {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if groups[keys[i]].Total != groups[keys[j]].Total {
+			return groups[keys[i]].Total > groups[keys[j]].Total
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Fprintln(w)
+	infoColor.Fprintf(w, "%s:\n", heading)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %-20s %d (%d active, %d dismissed)\n", key, groups[key].Total, groups[key].Active, groups[key].Dismissed)
+	}
+}
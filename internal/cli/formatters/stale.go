@@ -0,0 +1,45 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tuist/guck/internal/mcp"
+)
+
+// PrintStaleComments lists unresolved comments pinned to commits other than
+// the branch's HEAD, with a hint on how to migrate or resolve them
+func PrintStaleComments(w io.Writer, branch, headCommit string, comments []mcp.CommentResult) {
+	if len(comments) == 0 {
+		successColor.Fprintf(w, "✓ No stale comments on %s (HEAD %s)\n", branch, shortCommit(headCommit))
+		return
+	}
+
+	warningColor.Fprintf(w, "Found %d potentially stale comment(s) on %s (HEAD %s):\n\n", len(comments), branch, shortCommit(headCommit))
+	for _, comment := range comments {
+		fmt.Fprintf(w, "• [%s] ", ShortID(comment.ID))
+		urlColor.Fprint(w, comment.FilePath)
+		if comment.LineNumber != nil {
+			fmt.Fprintf(w, ":%d", *comment.LineNumber)
+		}
+		fmt.Fprintf(w, " @ %s", shortCommit(comment.Commit))
+		if comment.Author != "" {
+			fmt.Fprintf(w, " (%s)", comment.Author)
+		}
+		fmt.Fprintln(w)
+		if comment.Text != "" {
+			fmt.Fprintf(w, "  %s\n", comment.Text)
+		}
+	}
+
+	fmt.Fprintln(w)
+	infoColor.Fprintln(w, "Run 'guck comments stale --migrate-to-head' to move them onto HEAD,")
+	infoColor.Fprintln(w, "or 'guck comments resolve <comment-id> --by <name>' for ones that no longer apply.")
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
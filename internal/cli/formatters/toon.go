@@ -0,0 +1,278 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// toonIndent is the indentation of each nesting level
+const toonIndent = "  "
+
+// OutputToon outputs the result in TOON (Token-Oriented Object Notation,
+// https://github.com/toon-format/toon). Lists of flat comments or notes
+// become tables with one row per item.
+func OutputToon(result interface{}) error {
+	return EncodeToon(os.Stdout, result)
+}
+
+// EncodeToon writes value as TOON. The value is first marshaled to JSON, so
+// JSON struct tags decide the keys, struct fields keep their order and map
+// keys are sorted.
+func EncodeToon(w io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoded, err := decodeOrdered(decoder)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	switch v := decoded.(type) {
+	case toonObject:
+		writeToonFields(&out, v, 0)
+	case []interface{}:
+		writeToonArray(&out, "", v, 0)
+	default:
+		out.WriteString(toonPrimitive(v) + "\n")
+	}
+
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// toonField is a key and value of an object, kept in encounter order
+type toonField struct {
+	key   string
+	value interface{}
+}
+
+type toonObject []toonField
+
+// decodeOrdered decodes the next JSON value, keeping object keys in order.
+// Values are toonObject, []interface{}, string, json.Number, bool or nil.
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := toonObject{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, toonField{key: keyToken.(string), value: value})
+		}
+		_, err := decoder.Token() // closing brace
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token() // closing bracket
+		return array, err
+	default:
+		return token, nil
+	}
+}
+
+// writeToonFields writes each field of an object at depth
+func writeToonFields(out *strings.Builder, object toonObject, depth int) {
+	for _, field := range object {
+		writeToonField(out, strings.Repeat(toonIndent, depth), field, depth)
+	}
+}
+
+// writeToonField writes a field after prefix, which holds its indentation
+// and, for the first field of a list item, the "- " marker. Nested values
+// are written at depth+1.
+func writeToonField(out *strings.Builder, prefix string, field toonField, depth int) {
+	key := toonKey(field.key)
+	switch v := field.value.(type) {
+	case toonObject:
+		out.WriteString(prefix + key + ":\n")
+		writeToonFields(out, v, depth+1)
+	case []interface{}:
+		out.WriteString(prefix)
+		writeToonArray(out, key, v, depth)
+	default:
+		out.WriteString(prefix + key + ": " + toonPrimitive(v) + "\n")
+	}
+}
+
+// writeToonArray writes an array's header, whose indentation the caller has
+// already written, and its items: inline when they are all primitives, as a
+// table when they are objects with the same primitive fields, and as "- "
+// list items otherwise
+func writeToonArray(out *strings.Builder, key string, array []interface{}, depth int) {
+	header := fmt.Sprintf("%s[%d]", key, len(array))
+
+	if allToonPrimitives(array) {
+		values := make([]string, len(array))
+		for i, v := range array {
+			values[i] = toonPrimitive(v)
+		}
+		if len(values) == 0 {
+			out.WriteString(header + ":\n")
+			return
+		}
+		out.WriteString(header + ": " + strings.Join(values, ",") + "\n")
+		return
+	}
+
+	if fields, ok := toonTableFields(array); ok {
+		keys := make([]string, len(fields))
+		for i, field := range fields {
+			keys[i] = toonKey(field)
+		}
+		out.WriteString(header + "{" + strings.Join(keys, ",") + "}:\n")
+
+		rowIndent := strings.Repeat(toonIndent, depth+1)
+		for _, item := range array {
+			values := make([]string, len(item.(toonObject)))
+			for i, field := range item.(toonObject) {
+				values[i] = toonPrimitive(field.value)
+			}
+			out.WriteString(rowIndent + strings.Join(values, ",") + "\n")
+		}
+		return
+	}
+
+	out.WriteString(header + ":\n")
+	itemIndent := strings.Repeat(toonIndent, depth+1)
+	for _, item := range array {
+		switch v := item.(type) {
+		case toonObject:
+			if len(v) == 0 {
+				out.WriteString(itemIndent + "-\n")
+				continue
+			}
+			// The first field shares the hyphen line; the rest line up with it
+			writeToonField(out, itemIndent+"- ", v[0], depth+2)
+			writeToonFields(out, v[1:], depth+2)
+		case []interface{}:
+			out.WriteString(itemIndent + "- ")
+			writeToonArray(out, "", v, depth+1)
+		default:
+			out.WriteString(itemIndent + "- " + toonPrimitive(v) + "\n")
+		}
+	}
+}
+
+// allToonPrimitives reports whether no item is an object or array
+func allToonPrimitives(array []interface{}) bool {
+	for _, item := range array {
+		switch item.(type) {
+		case toonObject, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// toonTableFields returns the keys shared, in the same order, by every
+// object of a non-empty array whose values are all primitives
+func toonTableFields(array []interface{}) ([]string, bool) {
+	if len(array) == 0 {
+		return nil, false
+	}
+
+	var keys []string
+	for i, item := range array {
+		object, ok := item.(toonObject)
+		if !ok || len(object) == 0 || (i > 0 && len(object) != len(keys)) {
+			return nil, false
+		}
+		for j, field := range object {
+			switch field.value.(type) {
+			case toonObject, []interface{}:
+				return nil, false
+			}
+			if i == 0 {
+				keys = append(keys, field.key)
+			} else if keys[j] != field.key {
+				return nil, false
+			}
+		}
+	}
+	return keys, true
+}
+
+// toonUnquotedKey matches keys that need no quotes
+var toonUnquotedKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// toonNumeric matches strings that would read back as numbers
+var toonNumeric = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?$|^0\d+$`)
+
+func toonKey(key string) string {
+	if toonUnquotedKey.MatchString(key) {
+		return key
+	}
+	return toonQuote(key)
+}
+
+// toonPrimitive formats a string, number, boolean or null
+func toonPrimitive(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		if toonNeedsQuotes(v) {
+			return toonQuote(v)
+		}
+		return v
+	default:
+		return toonQuote(fmt.Sprint(v))
+	}
+}
+
+// toonNeedsQuotes reports whether a string would otherwise be ambiguous:
+// empty, padded, a literal or number, a list marker, or containing the
+// delimiter or structural characters
+func toonNeedsQuotes(s string) bool {
+	switch {
+	case s == "", s != strings.TrimSpace(s):
+		return true
+	case s == "true", s == "false", s == "null", toonNumeric.MatchString(s):
+		return true
+	case strings.HasPrefix(s, "-"):
+		return true
+	}
+	return strings.ContainsAny(s, ",:\"\\[]{}\n\r\t")
+}
+
+// toonQuote wraps a string in quotes, escaping backslashes, quotes and
+// control characters
+func toonQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(s) + `"`
+}
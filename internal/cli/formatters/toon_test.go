@@ -0,0 +1,91 @@
+package formatters
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tuist/guck/internal/mcp"
+)
+
+func TestEncodeToonFixtures(t *testing.T) {
+	line, otherLine := 42, 7
+
+	tests := []struct {
+		fixture string
+		result  interface{}
+	}{
+		{
+			// Comments with the same fields become a table
+			fixture: "comments.toon",
+			result: map[string]interface{}{
+				"comments": []mcp.CommentResult{
+					{ID: "1-0", FilePath: "main.go", LineNumber: &line, Text: "Handle the error, please", Timestamp: 1700000000, Branch: "main", Commit: "abc123", Author: "human:alice"},
+					{ID: "1-1", FilePath: "util.go", LineNumber: &otherLine, Text: "Looks good", Timestamp: 1700000001, Branch: "main", Commit: "abc123", Resolved: true, Author: "agent:claude"},
+				},
+				"count":     2,
+				"total":     2,
+				"repo_path": "/repo",
+			},
+		},
+		{
+			// Notes with nested metadata or differing fields become list items
+			fixture: "notes.toon",
+			result: map[string]interface{}{
+				"notes": []mcp.NoteResult{
+					{ID: "2-0", FilePath: "main.go", LineNumber: &line, Text: "Uses a \"binary\" search:\nfast", Timestamp: 1700000000, Branch: "main", Commit: "abc123", Author: "claude", Type: "explanation", Metadata: map[string]string{"model": "claude-sonnet-4", "confidence": "0.9"}},
+					{ID: "2-1", FilePath: "util.go", Timestamp: 1700000001, Branch: "main", Commit: "abc123", Author: "claude", Type: "suggestion", Dismissed: true, DismissedBy: "user", DismissedAt: 1700000002},
+				},
+				"count":     2,
+				"total":     2,
+				"repo_path": "/repo",
+			},
+		},
+		{
+			fixture: "success.toon",
+			result:  map[string]interface{}{"success": true, "comment_id": "1-0", "repo_path": "/repo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			expected, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			var out strings.Builder
+			if err := EncodeToon(&out, tt.result); err != nil {
+				t.Fatalf("EncodeToon failed: %v", err)
+			}
+			if out.String() != string(expected) {
+				t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+			}
+		})
+	}
+}
+
+func TestEncodeToonArrays(t *testing.T) {
+	var out strings.Builder
+	err := EncodeToon(&out, map[string]interface{}{
+		"empty": []string{},
+		"tags":  []string{"a", "b c", "true", "-x", "1.5", ""},
+		"mixed": []interface{}{1, map[string]interface{}{"a": 1, "b": []int{2, 3}}, []int{4}},
+	})
+	if err != nil {
+		t.Fatalf("EncodeToon failed: %v", err)
+	}
+
+	expected := `empty[0]:
+mixed[3]:
+  - 1
+  - a: 1
+    b[2]: 2,3
+  - [1]: 4
+tags[6]: a,b c,"true","-x","1.5",""
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
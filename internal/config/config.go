@@ -8,8 +8,106 @@ import (
 	"github.com/BurntSushi/toml"
 )
 
+// RepoConfigFile is the repository-local config file, committed at the repo root
+const RepoConfigFile = ".guck.toml"
+
 type Config struct {
+	// BaseBranch is the branch or revision diffs compare against;
+	// AutoBaseBranch detects the repository's default branch
 	BaseBranch string `toml:"base_branch"`
+	// RefreshIntervalMs is how often the web UI polls for changes; 0 disables polling
+	RefreshIntervalMs int `toml:"refresh_interval_ms"`
+	// AutoFetch refreshes origin before computing diffs so the base ref is current
+	AutoFetch bool `toml:"auto_fetch"`
+	// IgnoreWhitespace hides whitespace-only changes in diffs by default
+	IgnoreWhitespace bool `toml:"ignore_whitespace"`
+	// ExportPath is the default output directory for state exports
+	ExportPath string `toml:"export_path,omitempty"`
+	// IDDisplayLength is how many characters of comment and note IDs the
+	// human-readable output shows; 0 shows full IDs
+	IDDisplayLength int `toml:"id_display_length"`
+	// StateLocation is where review state is stored: StateLocationGlobal or
+	// StateLocationRepo
+	StateLocation string `toml:"state_location"`
+	// DaemonIdleTimeout is how many minutes a background daemon may go without
+	// requests before it stops itself; 0 keeps it running
+	DaemonIdleTimeout int `toml:"daemon_idle_timeout"`
+	// GeneratedPatterns are gitignore-style patterns of generated or vendored
+	// files, which diffs flag so they can be hidden
+	GeneratedPatterns []string `toml:"generated_patterns"`
+	// Host is the address the server listens on, DefaultHost unless the UI
+	// needs to be reachable from other machines
+	Host string `toml:"host"`
+	// CORSOrigins are the origins, such as an editor extension's webview,
+	// allowed to call the API from a browser; "*" allows any
+	CORSOrigins []string `toml:"cors_origins"`
+	// MaxPatchBytes truncates longer per-file patches in diffs; 0 means no limit
+	MaxPatchBytes int `toml:"max_patch_bytes"`
+	// MaxPatchLines truncates per-file patches in diffs with more lines; 0
+	// means no limit
+	MaxPatchLines int `toml:"max_patch_lines"`
+	// Reviewer is who the web UI records as adding comments and resolving
+	// comments or dismissing notes, e.g. "human:alice"
+	Reviewer string `toml:"reviewer"`
+	// Preferences are the web UI's rendering preferences, keyed by
+	// repository path
+	Preferences map[string]Preferences `toml:"preferences,omitempty"`
+}
+
+// AutoBaseBranch asks guck to detect the repository's default branch instead
+// of comparing against a fixed one
+const AutoBaseBranch = "auto"
+
+// DefaultHost keeps the server reachable only from this machine
+const DefaultHost = "127.0.0.1"
+
+// DefaultReviewer is the identity the web UI acts as when none is configured
+const DefaultReviewer = "web-ui"
+
+// DefaultMaxPatchLines keeps a huge generated file from freezing the browser
+const DefaultMaxPatchLines = 50000
+
+// DefaultIDDisplayLength is the number of ID characters shown by default
+const DefaultIDDisplayLength = 8
+
+// DefaultGeneratedPatterns are the lockfiles, vendored dependencies and
+// generated sources flagged when generated_patterns isn't configured
+var DefaultGeneratedPatterns = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"Gemfile.lock",
+	"Podfile.lock",
+	"Package.resolved",
+	"poetry.lock",
+	"composer.lock",
+	"vendor/",
+	"node_modules/",
+	"*.pb.go",
+	"*.min.js",
+	"*.min.css",
+}
+
+const (
+	// StateLocationGlobal keeps review state for every repo in the XDG state directory
+	StateLocationGlobal = "global"
+	// StateLocationRepo keeps a repo's review state under its .git/guck/ directory
+	StateLocationRepo = "repo"
+)
+
+// defaultConfig returns the config used when no config file is set
+func defaultConfig() *Config {
+	return &Config{
+		BaseBranch:        AutoBaseBranch,
+		IDDisplayLength:   DefaultIDDisplayLength,
+		StateLocation:     StateLocationGlobal,
+		GeneratedPatterns: append([]string(nil), DefaultGeneratedPatterns...),
+		Host:              DefaultHost,
+		MaxPatchLines:     DefaultMaxPatchLines,
+		Reviewer:          DefaultReviewer,
+	}
 }
 
 func Load() (*Config, error) {
@@ -18,20 +116,83 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	cfg := &Config{
-		BaseBranch: "main",
+	cfg, err := decodeConfig(configPath)
+	if err != nil {
+		// If decode fails, use defaults
+		cfg = defaultConfig()
 	}
 
+	return cfg, nil
+}
+
+// LoadForUpdate is Load for callers that save the config back. Instead of
+// falling back to defaults it returns the decode error, so saving can't
+// overwrite a config.toml the user still has to fix.
+func LoadForUpdate() (*Config, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeConfig(configPath)
+}
+
+// decodeConfig decodes the config at configPath over the defaults, which are
+// returned as-is when the file doesn't exist
+func decodeConfig(configPath string) (*Config, error) {
+	cfg := defaultConfig()
 	if _, err := os.Stat(configPath); err == nil {
 		if _, err := toml.DecodeFile(configPath, cfg); err != nil {
-			// If decode fails, use defaults
-			cfg.BaseBranch = "main"
+			return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+	}
+	return cfg, nil
+}
+
+// repoKeys are the keys a repository's .guck.toml may set, with how each is
+// copied over the global config. They only shape how the diff is shown.
+// Keys that expose the server (host, cors_origins), act on the user's behalf
+// (auto_fetch, reviewer) or choose where data is written (state_location,
+// export_path) come from the global config only, since the shell integration
+// starts a daemon in every repository the user cds into, cloned or not.
+var repoKeys = map[string]func(cfg, repo *Config){
+	"base_branch":         func(cfg, repo *Config) { cfg.BaseBranch = repo.BaseBranch },
+	"refresh_interval_ms": func(cfg, repo *Config) { cfg.RefreshIntervalMs = repo.RefreshIntervalMs },
+	"ignore_whitespace":   func(cfg, repo *Config) { cfg.IgnoreWhitespace = repo.IgnoreWhitespace },
+	"id_display_length":   func(cfg, repo *Config) { cfg.IDDisplayLength = repo.IDDisplayLength },
+	"daemon_idle_timeout": func(cfg, repo *Config) { cfg.DaemonIdleTimeout = repo.DaemonIdleTimeout },
+	"generated_patterns":  func(cfg, repo *Config) { cfg.GeneratedPatterns = repo.GeneratedPatterns },
+	"max_patch_bytes":     func(cfg, repo *Config) { cfg.MaxPatchBytes = repo.MaxPatchBytes },
+	"max_patch_lines":     func(cfg, repo *Config) { cfg.MaxPatchLines = repo.MaxPatchLines },
+}
+
+// LoadForRepo loads the global config and merges the repository's
+// .guck.toml over it. Only repoKeys are taken from the repo file; other keys
+// there are ignored, and everything not set falls back to the global value.
+func LoadForRepo(repoPath string) (*Config, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	repoConfigPath := filepath.Join(repoPath, RepoConfigFile)
+	if _, err := os.Stat(repoConfigPath); err == nil {
+		var repo Config
+		meta, err := toml.DecodeFile(repoConfigPath, &repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", repoConfigPath, err)
+		}
+		for key, apply := range repoKeys {
+			if meta.IsDefined(key) {
+				apply(cfg, &repo)
+			}
 		}
 	}
 
 	return cfg, nil
 }
 
+// Save writes the global config. Repository-local values are never saved.
 func (c *Config) Save() error {
 	configPath, err := getConfigPath()
 	if err != nil {
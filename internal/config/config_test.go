@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeGlobalConfig points XDG_CONFIG_HOME at a temporary directory holding
+// content as the global config
+func writeGlobalConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	path := filepath.Join(configHome, "guck", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadForRepoOnlyAppliesRepoKeys(t *testing.T) {
+	writeGlobalConfig(t, "host = \"127.0.0.1\"\nreviewer = \"human:alice\"\n")
+
+	repoPath := t.TempDir()
+	repoConfig := `base_branch = "develop"
+ignore_whitespace = true
+generated_patterns = ["gen/"]
+host = "0.0.0.0"
+cors_origins = ["*"]
+state_location = "repo"
+auto_fetch = true
+reviewer = "human:mallory"
+export_path = "/tmp/exports"
+`
+	if err := os.WriteFile(filepath.Join(repoPath, RepoConfigFile), []byte(repoConfig), 0644); err != nil {
+		t.Fatalf("Failed to write repo config: %v", err)
+	}
+
+	cfg, err := LoadForRepo(repoPath)
+	if err != nil {
+		t.Fatalf("LoadForRepo failed: %v", err)
+	}
+
+	if cfg.BaseBranch != "develop" || !cfg.IgnoreWhitespace || len(cfg.GeneratedPatterns) != 1 || cfg.GeneratedPatterns[0] != "gen/" {
+		t.Errorf("Expected the repo's diff settings to apply, got %+v", cfg)
+	}
+	if cfg.Host != DefaultHost || cfg.CORSOrigins != nil || cfg.StateLocation != StateLocationGlobal ||
+		cfg.AutoFetch || cfg.Reviewer != "human:alice" || cfg.ExportPath != "" {
+		t.Errorf("Expected the repo file not to change global-only keys, got %+v", cfg)
+	}
+}
+
+func TestLoadFallsBackToDefaultsOnDecodeError(t *testing.T) {
+	// state_location decodes before the type error on host is reached
+	writeGlobalConfig(t, "state_location = \"repo\"\nbase_branch = \"develop\"\nhost = 1\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.StateLocation != StateLocationGlobal || cfg.BaseBranch != AutoBaseBranch || cfg.Host != DefaultHost {
+		t.Errorf("Expected the defaults after a decode error, got %+v", cfg)
+	}
+}
+
+func TestSavePreferencesKeepsUnparsableConfig(t *testing.T) {
+	content := "base_branch = \"develop\"\nreviewer = \"human:alice\n"
+	path := writeGlobalConfig(t, content)
+
+	if err := SavePreferences("/repo", Preferences{DiffView: DiffViewSplit}); err == nil {
+		t.Fatal("Expected SavePreferences to fail on an unparsable config")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Expected the config to be left untouched, got %q", data)
+	}
+}
+
+func TestSavePreferences(t *testing.T) {
+	writeGlobalConfig(t, "base_branch = \"develop\"\n")
+
+	prefs := Preferences{DiffView: DiffViewSplit, Theme: ThemeDark}
+	if err := SavePreferences("/repo", prefs); err != nil {
+		t.Fatalf("SavePreferences failed: %v", err)
+	}
+
+	cfg, err := LoadForUpdate()
+	if err != nil {
+		t.Fatalf("LoadForUpdate failed: %v", err)
+	}
+	if cfg.BaseBranch != "develop" || cfg.Preferences["/repo"] != prefs {
+		t.Errorf("Expected the preferences saved alongside the existing config, got %+v", cfg)
+	}
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tuist/guck/internal/author"
+)
+
+// Key describes a configuration value that can be read and written from the CLI
+type Key struct {
+	Name        string
+	Description string
+	Get         func(c *Config) string
+	Set         func(c *Config, value string) error
+}
+
+// Keys lists every supported configuration key in display order
+var Keys = []Key{
+	{
+		Name:        "base-branch",
+		Description: "Branch to compare against, or auto to detect it from origin/HEAD, main, master, develop or trunk (default: auto)",
+		Get:         func(c *Config) string { return c.BaseBranch },
+		Set: func(c *Config, value string) error {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("base-branch cannot be empty")
+			}
+			c.BaseBranch = value
+			return nil
+		},
+	},
+	{
+		Name:        "refresh-interval-ms",
+		Description: "How often the web UI polls for changes, in milliseconds (0 disables polling)",
+		Get:         func(c *Config) string { return strconv.Itoa(c.RefreshIntervalMs) },
+		Set: func(c *Config, value string) error {
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 0 {
+				return fmt.Errorf("refresh-interval-ms must be a non-negative integer (0 disables polling)")
+			}
+			c.RefreshIntervalMs = interval
+			return nil
+		},
+	},
+	{
+		Name:        "auto-fetch",
+		Description: "Fetch origin before computing diffs (true/false)",
+		Get:         func(c *Config) string { return strconv.FormatBool(c.AutoFetch) },
+		Set: func(c *Config, value string) error {
+			autoFetch, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("auto-fetch must be true or false")
+			}
+			c.AutoFetch = autoFetch
+			return nil
+		},
+	},
+	{
+		Name:        "ignore-whitespace",
+		Description: "Hide whitespace-only changes in diffs (true/false)",
+		Get:         func(c *Config) string { return strconv.FormatBool(c.IgnoreWhitespace) },
+		Set: func(c *Config, value string) error {
+			ignoreWhitespace, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("ignore-whitespace must be true or false")
+			}
+			c.IgnoreWhitespace = ignoreWhitespace
+			return nil
+		},
+	},
+	{
+		Name:        "export-path",
+		Description: "Default directory for 'guck state export-all' (absolute or ~/ path)",
+		Get:         func(c *Config) string { return c.ExportPath },
+		Set: func(c *Config, value string) error {
+			path, err := ExpandPath(value)
+			if err != nil {
+				return fmt.Errorf("export-path: %w", err)
+			}
+			c.ExportPath = path
+			return nil
+		},
+	},
+	{
+		Name:        "id-display-length",
+		Description: "Characters of comment and note IDs shown in human-readable output (0 shows full IDs)",
+		Get:         func(c *Config) string { return strconv.Itoa(c.IDDisplayLength) },
+		Set: func(c *Config, value string) error {
+			length, err := strconv.Atoi(value)
+			if err != nil || length < 0 {
+				return fmt.Errorf("id-display-length must be a non-negative integer (0 shows full IDs)")
+			}
+			c.IDDisplayLength = length
+			return nil
+		},
+	},
+	{
+		Name:        "reviewer",
+		Description: "Who the web UI records on comments, resolutions and dismissals, e.g. human:alice (default: web-ui)",
+		Get:         func(c *Config) string { return c.Reviewer },
+		Set: func(c *Config, value string) error {
+			if err := author.Validate(value); err != nil {
+				return fmt.Errorf("reviewer: %w", err)
+			}
+			c.Reviewer = value
+			return nil
+		},
+	},
+	{
+		Name:        "state-location",
+		Description: "Where review state is stored: global (XDG state directory) or repo (.git/guck/)",
+		Get:         func(c *Config) string { return c.StateLocation },
+		Set: func(c *Config, value string) error {
+			if value != StateLocationGlobal && value != StateLocationRepo {
+				return fmt.Errorf("state-location must be %s or %s", StateLocationGlobal, StateLocationRepo)
+			}
+			c.StateLocation = value
+			return nil
+		},
+	},
+	{
+		Name:        "daemon-idle-timeout",
+		Description: "Minutes a background daemon may go without requests before stopping (0 never stops it)",
+		Get:         func(c *Config) string { return strconv.Itoa(c.DaemonIdleTimeout) },
+		Set: func(c *Config, value string) error {
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes < 0 {
+				return fmt.Errorf("daemon-idle-timeout must be a non-negative number of minutes (0 never stops)")
+			}
+			c.DaemonIdleTimeout = minutes
+			return nil
+		},
+	},
+	{
+		Name:        "host",
+		Description: "Address the server listens on (default: 127.0.0.1; 0.0.0.0 listens on every interface)",
+		Get:         func(c *Config) string { return c.Host },
+		Set: func(c *Config, value string) error {
+			// IPv6 addresses contain colons, host names never do
+			if net.ParseIP(value) == nil && (strings.TrimSpace(value) == "" || strings.ContainsAny(value, " /:")) {
+				return fmt.Errorf("host must be an IP address or host name")
+			}
+			c.Host = value
+			return nil
+		},
+	},
+	{
+		Name:        "generated-patterns",
+		Description: "Comma-separated gitignore-style patterns of generated files (empty flags none)",
+		Get:         func(c *Config) string { return strings.Join(c.GeneratedPatterns, ",") },
+		Set: func(c *Config, value string) error {
+			patterns := []string{}
+			for _, pattern := range strings.Split(value, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern != "" {
+					patterns = append(patterns, pattern)
+				}
+			}
+			c.GeneratedPatterns = patterns
+			return nil
+		},
+	},
+	{
+		Name:        "max-patch-bytes",
+		Description: "Truncate a file's patch in diffs beyond this many bytes (0 never truncates)",
+		Get:         func(c *Config) string { return strconv.Itoa(c.MaxPatchBytes) },
+		Set: func(c *Config, value string) error {
+			maxBytes, err := strconv.Atoi(value)
+			if err != nil || maxBytes < 0 {
+				return fmt.Errorf("max-patch-bytes must be a non-negative number of bytes (0 never truncates)")
+			}
+			c.MaxPatchBytes = maxBytes
+			return nil
+		},
+	},
+	{
+		Name:        "max-patch-lines",
+		Description: "Truncate a file's patch in diffs beyond this many lines (default: 50000; 0 never truncates)",
+		Get:         func(c *Config) string { return strconv.Itoa(c.MaxPatchLines) },
+		Set: func(c *Config, value string) error {
+			maxLines, err := strconv.Atoi(value)
+			if err != nil || maxLines < 0 {
+				return fmt.Errorf("max-patch-lines must be a non-negative number of lines (0 never truncates)")
+			}
+			c.MaxPatchLines = maxLines
+			return nil
+		},
+	},
+	{
+		Name:        "cors-origins",
+		Description: "Comma-separated origins allowed to call the API from a browser, e.g. editor extensions (* allows any; empty allows none)",
+		Get:         func(c *Config) string { return strings.Join(c.CORSOrigins, ",") },
+		Set: func(c *Config, value string) error {
+			origins := []string{}
+			for _, origin := range strings.Split(value, ",") {
+				if origin = strings.TrimSpace(origin); origin != "" {
+					origins = append(origins, strings.TrimSuffix(origin, "/"))
+				}
+			}
+			c.CORSOrigins = origins
+			return nil
+		},
+	},
+}
+
+// LookupKey returns the configuration key with the given name
+func LookupKey(name string) (*Key, error) {
+	for i := range Keys {
+		if Keys[i].Name == name {
+			return &Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown configuration key: %s (run 'guck config keys' to list valid keys)", name)
+}
+
+// ExpandPath expands a leading ~ to the home directory and requires the
+// result to be absolute
+func ExpandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine home directory: %w", err)
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path must be absolute or start with ~/: %s", path)
+	}
+
+	return filepath.Clean(path), nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import "fmt"
+
+// Preferences are how the web UI renders a repository's diff. They are kept
+// in the global config, keyed by repository path, so they survive daemon
+// restarts without being committed to the repository.
+type Preferences struct {
+	// DiffView is DiffViewUnified or DiffViewSplit
+	DiffView string `toml:"diff_view" json:"diff_view"`
+	// WrapLines wraps long diff lines instead of scrolling them
+	WrapLines bool `toml:"wrap_lines" json:"wrap_lines"`
+	// Theme is ThemeLight or ThemeDark; empty follows the system setting
+	Theme string `toml:"theme,omitempty" json:"theme"`
+}
+
+const (
+	DiffViewUnified = "unified"
+	DiffViewSplit   = "split"
+
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// DefaultPreferences are used for repositories without saved preferences
+var DefaultPreferences = Preferences{DiffView: DiffViewUnified}
+
+// Validate reports the first preference with an unsupported value
+func (p Preferences) Validate() error {
+	if p.DiffView != DiffViewUnified && p.DiffView != DiffViewSplit {
+		return fmt.Errorf("diff_view must be %s or %s", DiffViewUnified, DiffViewSplit)
+	}
+	if p.Theme != "" && p.Theme != ThemeLight && p.Theme != ThemeDark {
+		return fmt.Errorf("theme must be %s or %s", ThemeLight, ThemeDark)
+	}
+	return nil
+}
+
+// LoadPreferences returns the saved preferences for repoPath, or
+// DefaultPreferences when none are saved
+func LoadPreferences(repoPath string) (Preferences, error) {
+	cfg, err := Load()
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	if prefs, ok := cfg.Preferences[repoPath]; ok {
+		return prefs, nil
+	}
+	return DefaultPreferences, nil
+}
+
+// SavePreferences validates prefs and saves them for repoPath in the global
+// config
+func SavePreferences(repoPath string, prefs Preferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+
+	cfg, err := LoadForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Preferences == nil {
+		cfg.Preferences = map[string]Preferences{}
+	}
+	cfg.Preferences[repoPath] = prefs
+	return cfg.Save()
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 type Info struct {
@@ -16,6 +20,59 @@ type Info struct {
 	Port       int    `json:"port"`
 	RepoPath   string `json:"repo_path"`
 	BaseBranch string `json:"base_branch"`
+	// DiffMode is the daemon's diff mode, as accepted by --diff-mode
+	DiffMode string `json:"diff_mode,omitempty"`
+	// StartedAt is when the daemon registered, in Unix seconds
+	StartedAt int64 `json:"started_at,omitempty"`
+	// Host is the address the server listens on; empty means 127.0.0.1
+	Host string `json:"host,omitempty"`
+	// Fetch is set when the daemon was started with --fetch
+	Fetch bool `json:"fetch,omitempty"`
+}
+
+// URL is the address to open the daemon's web UI at. Daemons listening on
+// 127.0.0.1 or on every interface are reached through localhost.
+func (i *Info) URL() string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(i.reachableHost("localhost"), strconv.Itoa(i.Port)))
+}
+
+// reachableHost is the host to connect to the daemon on, or local when it
+// listens on 127.0.0.1 or on every interface
+func (i *Info) reachableHost(local string) string {
+	if i.Host == "" {
+		return local
+	}
+	if ip := net.ParseIP(i.Host); ip != nil && (ip.Equal(net.IPv4(127, 0, 0, 1)) || ip.IsUnspecified()) {
+		return local
+	}
+	return i.Host
+}
+
+// Daemon health reported by CheckHealth
+const (
+	// HealthRunning means the process is alive and answering /api/health
+	HealthRunning = "running"
+	// HealthStale means the registered process no longer exists
+	HealthStale = "stale"
+	// HealthUnreachable means the process is alive but its server doesn't answer
+	HealthUnreachable = "unreachable"
+)
+
+// Status is a registered daemon together with its health
+type Status struct {
+	*Info
+	Health string `json:"health"`
+	// UptimeSeconds is how long the daemon has been running, when known
+	UptimeSeconds int64 `json:"uptime_seconds,omitempty"`
+}
+
+// StopResult reports the outcome of stopping a daemon
+type StopResult struct {
+	RepoPath string `json:"repo_path"`
+	PID      int    `json:"pid"`
+	Port     int    `json:"port"`
+	Stopped  bool   `json:"stopped"`
+	Error    string `json:"error,omitempty"`
 }
 
 type Registry struct {
@@ -130,6 +187,10 @@ func (m *Manager) RegisterDaemon(info *Info) error {
 		return err
 	}
 
+	if info.StartedAt == 0 {
+		info.StartedAt = time.Now().Unix()
+	}
+
 	registry.Daemons[info.RepoPath] = info
 	return m.saveRegistry(registry)
 }
@@ -169,6 +230,35 @@ func (m *Manager) IsDaemonRunning(pid int) bool {
 	return err == nil
 }
 
+// CheckHealth reports whether a registered daemon's process is alive and its
+// server answers a GET to /api/health within timeout
+func (m *Manager) CheckHealth(info *Info, timeout time.Duration) *Status {
+	status := &Status{Info: info, Health: HealthStale}
+	if !m.IsDaemonRunning(info.PID) {
+		return status
+	}
+
+	if info.StartedAt > 0 {
+		status.UptimeSeconds = time.Now().Unix() - info.StartedAt
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/api/health", net.JoinHostPort(info.reachableHost("127.0.0.1"), strconv.Itoa(info.Port))))
+	if err != nil {
+		status.Health = HealthUnreachable
+		return status
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		status.Health = HealthUnreachable
+		return status
+	}
+
+	status.Health = HealthRunning
+	return status
+}
+
 func (m *Manager) StopDaemon(pid int) error {
 	process, err := os.FindProcess(pid)
 	if err != nil {
@@ -182,6 +272,83 @@ func (m *Manager) StopDaemon(pid int) error {
 	return nil
 }
 
+// Stop signals a daemon to stop and removes it from the registry
+func (m *Manager) Stop(info *Info) StopResult {
+	result := StopResult{
+		RepoPath: info.RepoPath,
+		PID:      info.PID,
+		Port:     info.Port,
+	}
+
+	if err := m.StopDaemon(info.PID); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := m.UnregisterDaemon(info.RepoPath); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Stopped = true
+	return result
+}
+
+// StopAll stops every registered daemon that is still running, sorted by
+// repository path
+func (m *Manager) StopAll() ([]StopResult, error) {
+	daemons, err := m.ListDaemons()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(daemons, func(i, j int) bool { return daemons[i].RepoPath < daemons[j].RepoPath })
+
+	results := []StopResult{}
+	for _, info := range daemons {
+		if m.IsDaemonRunning(info.PID) {
+			results = append(results, m.Stop(info))
+		}
+	}
+
+	return results, nil
+}
+
+// WaitForReady polls until the daemon with the given PID has registered
+// itself for repoPath and its server answers /api/health, returning the
+// registered details. It fails if the process exits or the timeout elapses
+// first; callers that started the process must reap it for exits to be seen.
+func (m *Manager) WaitForReady(repoPath string, pid int, timeout time.Duration) (*Info, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !m.IsDaemonRunning(pid) {
+			return nil, fmt.Errorf("daemon (PID %d) exited during startup", pid)
+		}
+		if info, _ := m.GetDaemonForRepo(repoPath); info != nil && info.PID == pid {
+			if m.CheckHealth(info, time.Second).Health == HealthRunning {
+				return info, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("daemon (PID %d) did not start serving within %s", pid, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// WaitForExit polls until the process exits or the timeout elapses
+func (m *Manager) WaitForExit(pid int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for m.IsDaemonRunning(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("daemon (PID %d) did not exit within %s", pid, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return nil
+}
+
 func (m *Manager) CleanupStaleDaemons() error {
 	registry, err := m.loadRegistry()
 	if err != nil {
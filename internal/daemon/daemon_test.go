@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestStopAllJSON(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create daemon manager: %v", err)
+	}
+
+	// Register two long-running processes standing in for daemons
+	for i, repoPath := range []string{"/repos/b", "/repos/a"} {
+		cmd := exec.Command("sleep", "60")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start process: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		})
+
+		if err := manager.RegisterDaemon(&Info{PID: cmd.Process.Pid, Port: 3000 + i, RepoPath: repoPath}); err != nil {
+			t.Fatalf("Failed to register daemon: %v", err)
+		}
+	}
+
+	results, err := manager.StopAll()
+	if err != nil {
+		t.Fatalf("StopAll failed: %v", err)
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		t.Fatalf("Failed to marshal results: %v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+
+	if len(decoded) != 2 {
+		t.Fatalf("Expected 2 results, got %s", data)
+	}
+	for i, expected := range []struct {
+		repoPath string
+		port     float64
+	}{{"/repos/a", 3001}, {"/repos/b", 3000}} {
+		result := decoded[i]
+		if result["repo_path"] != expected.repoPath || result["port"] != expected.port || result["stopped"] != true {
+			t.Errorf("Unexpected result %d: %v", i, result)
+		}
+		if _, ok := result["pid"].(float64); !ok {
+			t.Errorf("Expected pid in result %d: %v", i, result)
+		}
+		if _, ok := result["error"]; ok {
+			t.Errorf("Expected no error in result %d: %v", i, result)
+		}
+	}
+
+	daemons, err := manager.ListDaemons()
+	if err != nil {
+		t.Fatalf("Failed to list daemons: %v", err)
+	}
+	if len(daemons) != 0 {
+		t.Errorf("Expected registry to be empty, got %d daemons", len(daemons))
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create daemon manager: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	// This test process stands in for a live daemon
+	info := &Info{PID: os.Getpid(), Port: port, RepoPath: "/repos/a"}
+	if err := manager.RegisterDaemon(info); err != nil {
+		t.Fatalf("Failed to register daemon: %v", err)
+	}
+	if info.StartedAt == 0 {
+		t.Error("Expected RegisterDaemon to record the start time")
+	}
+
+	if status := manager.CheckHealth(info, time.Second); status.Health != HealthRunning {
+		t.Errorf("Expected %s, got %s", HealthRunning, status.Health)
+	}
+
+	unreachable := &Info{PID: os.Getpid(), Port: port, RepoPath: "/repos/a"}
+	server.Close()
+	if status := manager.CheckHealth(unreachable, time.Second); status.Health != HealthUnreachable {
+		t.Errorf("Expected %s once the server is down, got %s", HealthUnreachable, status.Health)
+	}
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run process: %v", err)
+	}
+	stale := &Info{PID: cmd.Process.Pid, Port: port, RepoPath: "/repos/b"}
+	if status := manager.CheckHealth(stale, time.Second); status.Health != HealthStale {
+		t.Errorf("Expected %s for an exited process, got %s", HealthStale, status.Health)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create daemon manager: %v", err)
+	}
+
+	// Reserve a port, then start serving on it only after a delay
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// Nothing has registered for the repository yet
+	if _, err := manager.WaitForReady("/repos/a", os.Getpid(), 200*time.Millisecond); err == nil {
+		t.Error("Expected an error before the daemon registers")
+	}
+
+	// This test process stands in for the daemon, registering on a port it
+	// doesn't serve yet
+	if err := manager.RegisterDaemon(&Info{PID: os.Getpid(), Port: port, RepoPath: "/repos/a"}); err != nil {
+		t.Fatalf("Failed to register daemon: %v", err)
+	}
+
+	server := &http.Server{Addr: listener.Addr().String(), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = server.ListenAndServe()
+	}()
+	defer server.Close()
+
+	info, err := manager.WaitForReady("/repos/a", os.Getpid(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected the daemon to become ready, got %v", err)
+	}
+	if info.Port != port {
+		t.Errorf("Expected registered port %d, got %d", port, info.Port)
+	}
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run process: %v", err)
+	}
+	if _, err := manager.WaitForReady("/repos/b", cmd.Process.Pid, 5*time.Second); err == nil {
+		t.Error("Expected an error for an exited process")
+	}
+}
+
+func TestInfoURL(t *testing.T) {
+	for _, tt := range []struct {
+		host     string
+		expected string
+	}{
+		{"", "http://localhost:4000"},
+		{"127.0.0.1", "http://localhost:4000"},
+		{"0.0.0.0", "http://localhost:4000"},
+		{"::", "http://localhost:4000"},
+		{"::1", "http://[::1]:4000"},
+		{"192.168.1.20", "http://192.168.1.20:4000"},
+		{"fd00::1", "http://[fd00::1]:4000"},
+		{"devbox.local", "http://devbox.local:4000"},
+	} {
+		info := &Info{Port: 4000, Host: tt.host}
+		if url := info.URL(); url != tt.expected {
+			t.Errorf("URL() with host %q = %s, expected %s", tt.host, url, tt.expected)
+		}
+	}
+}
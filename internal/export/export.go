@@ -0,0 +1,276 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tuist/guck/internal/state"
+)
+
+// FormatVersion is bumped whenever the export layout changes incompatibly
+const FormatVersion = 1
+
+// ManifestFile is the name of the manifest written by ExportAll
+const ManifestFile = "manifest.json"
+
+// ExportData is the portable review state of a single repository
+type ExportData struct {
+	Version    int                                    `json:"version"`
+	RepoPath   string                                 `json:"repo_path"`
+	ExportedAt int64                                  `json:"exported_at"`
+	Summary    ExportSummary                          `json:"summary"`
+	Branches   map[string]map[string]*state.RepoState `json:"branches"`
+	// ViewedFiles flattens the viewed files of every branch and commit
+	ViewedFiles []ViewedFile `json:"viewed_files"`
+}
+
+// ViewedFile is a file marked as viewed at a branch and commit
+type ViewedFile struct {
+	Branch   string `json:"branch"`
+	Commit   string `json:"commit"`
+	FilePath string `json:"file_path"`
+}
+
+// ExportSummary counts the review items in an export
+type ExportSummary struct {
+	Comments           int `json:"comments"`
+	UnresolvedComments int `json:"unresolved_comments"`
+	ResolvedComments   int `json:"resolved_comments"`
+	Notes              int `json:"notes"`
+	ActiveNotes        int `json:"active_notes"`
+	DismissedNotes     int `json:"dismissed_notes"`
+	ViewedFiles        int `json:"viewed_files"`
+}
+
+// Manifest maps repository paths to the export files written for them
+type Manifest struct {
+	Version    int               `json:"version"`
+	ExportedAt int64             `json:"exported_at"`
+	Repos      map[string]string `json:"repos"` // repo path -> file name
+}
+
+// NewExportData captures the stored state of a repository
+func NewExportData(stateMgr *state.Manager, repoPath string) *ExportData {
+	branches := stateMgr.RepoBranches(repoPath)
+	if branches == nil {
+		branches = map[string]map[string]*state.RepoState{}
+	}
+
+	viewedFiles := []ViewedFile{}
+	for _, branch := range sortedKeys(branches) {
+		for _, commit := range sortedKeys(branches[branch]) {
+			for _, filePath := range stateMgr.GetViewedFiles(repoPath, branch, commit) {
+				viewedFiles = append(viewedFiles, ViewedFile{Branch: branch, Commit: commit, FilePath: filePath})
+			}
+		}
+	}
+
+	summary := Summarize(stateMgr.GetAllComments(repoPath), stateMgr.GetAllNotes(repoPath))
+	summary.ViewedFiles = len(viewedFiles)
+
+	return &ExportData{
+		Version:     FormatVersion,
+		RepoPath:    repoPath,
+		ExportedAt:  time.Now().Unix(),
+		Summary:     summary,
+		Branches:    branches,
+		ViewedFiles: viewedFiles,
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Summarize counts comments and notes by status
+func Summarize(comments []*state.Comment, notes []*state.Note) ExportSummary {
+	summary := ExportSummary{
+		Comments: len(comments),
+		Notes:    len(notes),
+	}
+
+	for _, c := range comments {
+		if c.Resolved {
+			summary.ResolvedComments++
+		} else {
+			summary.UnresolvedComments++
+		}
+	}
+
+	for _, n := range notes {
+		if n.Dismissed {
+			summary.DismissedNotes++
+		} else {
+			summary.ActiveNotes++
+		}
+	}
+
+	return summary
+}
+
+// ExportJSON writes a repository's ExportData to outputPath
+func ExportJSON(stateMgr *state.Manager, repoPath, outputPath string) error {
+	return writeJSON(outputPath, NewExportData(stateMgr, repoPath))
+}
+
+// csvHeader lists the columns written by ExportCSV
+var csvHeader = []string{
+	"kind", "id", "file_path", "line_number", "end_line", "branch", "commit", "author", "type",
+	"text", "suggestion", "status", "status_by", "status_at", "created_at", "parent_id",
+}
+
+// ExportCSV writes comments and notes as a single CSV file with one row per
+// item, distinguished by the kind column. Timestamps are formatted as RFC3339.
+func ExportCSV(comments []*state.Comment, notes []*state.Note, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+
+	for _, c := range comments {
+		status, statusBy, statusAt := "unresolved", "", int64(0)
+		if c.Resolved {
+			status, statusBy, statusAt = "resolved", c.ResolvedBy, c.ResolvedAt
+		}
+		if err := w.Write([]string{
+			"comment", c.ID, c.FilePath, csvLine(c.LineNumber), csvLine(c.EndLine), c.Branch, c.Commit, c.Author, c.Type,
+			c.Text, c.Suggestion, status, statusBy, csvTime(statusAt), csvTime(c.Timestamp), c.ParentID,
+		}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+
+	for _, n := range notes {
+		status, statusBy, statusAt := "active", "", int64(0)
+		if n.Dismissed {
+			status, statusBy, statusAt = "dismissed", n.DismissedBy, n.DismissedAt
+		}
+		if err := w.Write([]string{
+			"note", n.ID, n.FilePath, csvLine(n.LineNumber), "", n.Branch, n.Commit, n.Author, n.Type,
+			n.Text, "", status, statusBy, csvTime(statusAt), csvTime(n.Timestamp), "",
+		}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	return file.Close()
+}
+
+func csvLine(lineNumber *int) string {
+	if lineNumber == nil {
+		return ""
+	}
+	return strconv.Itoa(*lineNumber)
+}
+
+func csvTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// ExportAll writes one ExportData file per tracked repository into outputDir,
+// plus a manifest mapping repository paths to those files
+func ExportAll(stateMgr *state.Manager, outputDir string) (*Manifest, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	manifest := &Manifest{
+		Version:    FormatVersion,
+		ExportedAt: time.Now().Unix(),
+		Repos:      make(map[string]string),
+	}
+
+	for _, repoPath := range stateMgr.RepoPaths() {
+		fileName := state.HashRepoPath(repoPath) + ".json"
+		if err := writeJSON(filepath.Join(outputDir, fileName), NewExportData(stateMgr, repoPath)); err != nil {
+			return nil, err
+		}
+		manifest.Repos[repoPath] = fileName
+	}
+
+	if err := writeJSON(filepath.Join(outputDir, ManifestFile), manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// ImportAll restores every repository listed in the manifest in inputDir.
+// Imported branch/commit entries replace existing ones; others are kept.
+func ImportAll(stateMgr *state.Manager, inputDir string) (*Manifest, error) {
+	var manifest Manifest
+	if err := readJSON(filepath.Join(inputDir, ManifestFile), &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported export version %d (expected %d or lower)", manifest.Version, FormatVersion)
+	}
+
+	for repoPath, fileName := range manifest.Repos {
+		var data ExportData
+		if err := readJSON(filepath.Join(inputDir, fileName), &data); err != nil {
+			return nil, err
+		}
+
+		if data.RepoPath != repoPath {
+			return nil, fmt.Errorf("export file %s is for %s, expected %s", fileName, data.RepoPath, repoPath)
+		}
+
+		if err := stateMgr.ImportRepo(repoPath, data.Branches); err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", repoPath, err)
+		}
+	}
+
+	return &manifest, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package export
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tuist/guck/internal/state"
+)
+
+func newTestManager(t *testing.T) *state.Manager {
+	t.Helper()
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	manager, err := state.NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+	return manager
+}
+
+func TestExportAllAndImportAll(t *testing.T) {
+	source := newTestManager(t)
+
+	lineNumber := 3
+	if err := source.MarkFileViewed("/repos/one", "main", "abc123", "main.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+	if _, err := source.AddComment("/repos/two", "feature", "def456", "app.go", &lineNumber, nil, "", "Check this", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "backup")
+	manifest, err := ExportAll(source, outputDir)
+	if err != nil {
+		t.Fatalf("ExportAll failed: %v", err)
+	}
+
+	data := NewExportData(source, "/repos/one")
+	if data.Summary.ViewedFiles != 1 || len(data.ViewedFiles) != 1 {
+		t.Errorf("Expected 1 viewed file in export, got %d (%v)", data.Summary.ViewedFiles, data.ViewedFiles)
+	} else if vf := data.ViewedFiles[0]; vf.Branch != "main" || vf.Commit != "abc123" || vf.FilePath != "main.go" {
+		t.Errorf("Unexpected viewed file %+v", vf)
+	}
+
+	if len(manifest.Repos) != 2 {
+		t.Fatalf("Expected 2 repos in manifest, got %d", len(manifest.Repos))
+	}
+	for repoPath, fileName := range manifest.Repos {
+		if fileName != state.HashRepoPath(repoPath)+".json" {
+			t.Errorf("Unexpected file name %s for %s", fileName, repoPath)
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, fileName)); err != nil {
+			t.Errorf("Expected export file for %s: %v", repoPath, err)
+		}
+	}
+
+	target := newTestManager(t)
+	if _, err := ImportAll(target, outputDir); err != nil {
+		t.Fatalf("ImportAll failed: %v", err)
+	}
+
+	if !target.IsFileViewed("/repos/one", "main", "abc123", "main.go") {
+		t.Error("Expected viewed file to be restored")
+	}
+	comments := target.GetComments("/repos/two", "feature", "def456", nil)
+	if len(comments) != 1 || comments[0].Text != "Check this" {
+		t.Errorf("Expected comment to be restored, got %v", comments)
+	}
+}
+
+func TestImportAllMissingManifest(t *testing.T) {
+	manager := newTestManager(t)
+
+	if _, err := ImportAll(manager, t.TempDir()); err == nil {
+		t.Error("Expected error when manifest is missing")
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	line := 12
+	comments := []*state.Comment{
+		{FilePath: "main.go", LineNumber: &line, Text: "Handle the error", Author: "claude", Type: "issue"},
+		{FilePath: "README.md", Text: "Typo fixed", Resolved: true, ResolvedBy: "alice"},
+	}
+	notes := []*state.Note{
+		{FilePath: "main.go", LineNumber: &line, Text: "Uses a retry loop", Author: "claude", Type: "explanation"},
+	}
+
+	markdown := RenderMarkdown("/repos/app", comments, notes)
+
+	for _, expected := range []string{
+		"# Review summary: /repos/app",
+		"| Unresolved comments | 1 |",
+		"| Resolved comments | 1 |",
+		"| Active notes | 1 |",
+		"| Dismissed notes | 0 |",
+		"## Unresolved comments\n\n### `main.go:12`\n\n_claude · issue_\n\nHandle the error\n",
+		"## Resolved comments\n\n### `README.md`\n",
+		"Resolved by alice",
+		"## Dismissed notes\n\n_None_\n",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", expected, markdown)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	line := 7
+	comments := []*state.Comment{
+		{ID: "c1", FilePath: "main.go", LineNumber: &line, Text: "Split this, \"please\"\nit is long", Author: "claude", Timestamp: 1700000000, Resolved: true, ResolvedBy: "alice", ResolvedAt: 1700003600},
+	}
+	notes := []*state.Note{
+		{ID: "n1", FilePath: "README.md", Text: "Context", Author: "claude", Type: "explanation", Timestamp: 1700000000},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "review.csv")
+	if err := ExportCSV(comments, notes, outputPath); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d", len(records))
+	}
+
+	column := map[string]int{}
+	for i, name := range records[0] {
+		column[name] = i
+	}
+
+	comment := records[1]
+	if comment[column["kind"]] != "comment" || comment[column["line_number"]] != "7" {
+		t.Errorf("Unexpected comment row: %v", comment)
+	}
+	if comment[column["text"]] != comments[0].Text {
+		t.Errorf("Expected text to round-trip, got %q", comment[column["text"]])
+	}
+	if comment[column["status"]] != "resolved" || comment[column["status_by"]] != "alice" {
+		t.Errorf("Expected resolved by alice, got %v", comment)
+	}
+	if comment[column["created_at"]] != "2023-11-14T22:13:20Z" || comment[column["status_at"]] != "2023-11-14T23:13:20Z" {
+		t.Errorf("Expected RFC3339 timestamps, got %q and %q", comment[column["created_at"]], comment[column["status_at"]])
+	}
+
+	note := records[2]
+	if note[column["kind"]] != "note" || note[column["status"]] != "active" || note[column["line_number"]] != "" {
+		t.Errorf("Unexpected note row: %v", note)
+	}
+}
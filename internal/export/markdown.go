@@ -0,0 +1,151 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tuist/guck/internal/state"
+)
+
+// ExportMarkdown writes a review summary suitable for a PR description, with
+// comments and notes grouped by status under file:line headers
+func ExportMarkdown(repoPath string, comments []*state.Comment, notes []*state.Note, outputPath string) error {
+	if err := os.WriteFile(outputPath, []byte(RenderMarkdown(repoPath, comments, notes)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// RenderMarkdown renders the Markdown review summary
+func RenderMarkdown(repoPath string, comments []*state.Comment, notes []*state.Note) string {
+	summary := Summarize(comments, notes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Review summary: %s\n\n", repoPath)
+	b.WriteString("| Item | Count |\n")
+	b.WriteString("| --- | ---: |\n")
+	fmt.Fprintf(&b, "| Unresolved comments | %d |\n", summary.UnresolvedComments)
+	fmt.Fprintf(&b, "| Resolved comments | %d |\n", summary.ResolvedComments)
+	fmt.Fprintf(&b, "| Active notes | %d |\n", summary.ActiveNotes)
+	fmt.Fprintf(&b, "| Dismissed notes | %d |\n", summary.DismissedNotes)
+
+	var unresolved, resolved []*state.Comment
+	for _, c := range comments {
+		if c.Resolved {
+			resolved = append(resolved, c)
+		} else {
+			unresolved = append(unresolved, c)
+		}
+	}
+
+	var active, dismissed []*state.Note
+	for _, n := range notes {
+		if n.Dismissed {
+			dismissed = append(dismissed, n)
+		} else {
+			active = append(active, n)
+		}
+	}
+
+	writeCommentSection(&b, "Unresolved comments", unresolved)
+	writeCommentSection(&b, "Resolved comments", resolved)
+	writeNoteSection(&b, "Active notes", active)
+	writeNoteSection(&b, "Dismissed notes", dismissed)
+
+	return b.String()
+}
+
+func writeCommentSection(b *strings.Builder, title string, comments []*state.Comment) {
+	fmt.Fprintf(b, "\n## %s\n", title)
+	if len(comments) == 0 {
+		b.WriteString("\n_None_\n")
+		return
+	}
+
+	sorted := append([]*state.Comment(nil), comments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessLocation(sorted[i].FilePath, sorted[i].LineNumber, sorted[i].Timestamp,
+			sorted[j].FilePath, sorted[j].LineNumber, sorted[j].Timestamp)
+	})
+
+	for _, c := range sorted {
+		fmt.Fprintf(b, "\n### `%s`\n\n", location(c.FilePath, c.LineNumber, c.EndLine))
+		if attribution := joinNonEmpty(c.Author, c.Type); attribution != "" {
+			fmt.Fprintf(b, "_%s_\n\n", attribution)
+		}
+		if c.Text != "" {
+			b.WriteString(c.Text + "\n")
+		}
+		if c.Suggestion != "" {
+			fmt.Fprintf(b, "\n```suggestion\n%s\n```\n", strings.TrimSuffix(c.Suggestion, "\n"))
+		}
+		if c.Resolved && c.ResolvedBy != "" {
+			fmt.Fprintf(b, "\nResolved by %s\n", c.ResolvedBy)
+		}
+	}
+}
+
+func writeNoteSection(b *strings.Builder, title string, notes []*state.Note) {
+	fmt.Fprintf(b, "\n## %s\n", title)
+	if len(notes) == 0 {
+		b.WriteString("\n_None_\n")
+		return
+	}
+
+	sorted := append([]*state.Note(nil), notes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessLocation(sorted[i].FilePath, sorted[i].LineNumber, sorted[i].Timestamp,
+			sorted[j].FilePath, sorted[j].LineNumber, sorted[j].Timestamp)
+	})
+
+	for _, n := range sorted {
+		fmt.Fprintf(b, "\n### `%s`\n\n", location(n.FilePath, n.LineNumber, nil))
+		if attribution := joinNonEmpty(n.Author, n.Type); attribution != "" {
+			fmt.Fprintf(b, "_%s_\n\n", attribution)
+		}
+		b.WriteString(n.Text + "\n")
+		if n.Dismissed && n.DismissedBy != "" {
+			fmt.Fprintf(b, "\nDismissed by %s\n", n.DismissedBy)
+		}
+	}
+}
+
+func location(filePath string, lineNumber, endLine *int) string {
+	if lineNumber == nil {
+		return filePath
+	}
+	if endLine != nil {
+		return fmt.Sprintf("%s:%d-%d", filePath, *lineNumber, *endLine)
+	}
+	return fmt.Sprintf("%s:%d", filePath, *lineNumber)
+}
+
+// lessLocation orders items by file, then line (file-level first), then time
+func lessLocation(pathA string, lineA *int, timeA int64, pathB string, lineB *int, timeB int64) bool {
+	if pathA != pathB {
+		return pathA < pathB
+	}
+	a, b := 0, 0
+	if lineA != nil {
+		a = *lineA
+	}
+	if lineB != nil {
+		b = *lineB
+	}
+	if a != b {
+		return a < b
+	}
+	return timeA < timeB
+}
+
+func joinNonEmpty(parts ...string) string {
+	nonEmpty := []string{}
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, " · ")
+}
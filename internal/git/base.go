@@ -0,0 +1,67 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// AutoBaseBranch is the base branch that asks guck to detect the repository's
+// default branch
+const AutoBaseBranch = "auto"
+
+// ErrNoMergeBase reports a base that shares no history with HEAD, such as a
+// branch with an unrelated root commit, so there is no merge base to diff from
+var ErrNoMergeBase = errors.New("no common history")
+
+// baseBranchCandidates are the branches tried, in order, when origin/HEAD
+// doesn't name the default branch
+var baseBranchCandidates = []string{"main", "master", "develop", "trunk"}
+
+// ResolveBaseBranch returns baseBranch, or the detected default branch when
+// it is empty or AutoBaseBranch
+func (r *Repo) ResolveBaseBranch(baseBranch string) (string, error) {
+	if baseBranch != "" && baseBranch != AutoBaseBranch {
+		return baseBranch, nil
+	}
+	return r.DetectBaseBranch()
+}
+
+// DetectBaseBranch returns the branch origin/HEAD points at, or else the
+// first of main, master, develop and trunk that exists locally or on origin
+func (r *Repo) DetectBaseBranch() (string, error) {
+	if ref, err := r.repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false); err == nil && ref.Type() == plumbing.SymbolicReference {
+		if branch, ok := strings.CutPrefix(ref.Target().String(), "refs/remotes/origin/"); ok && r.branchExists(branch) {
+			return branch, nil
+		}
+	}
+
+	for _, branch := range baseBranchCandidates {
+		if r.branchExists(branch) {
+			return branch, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to detect the base branch: origin/HEAD is not set and none of %s exist; set one with `guck config set base-branch <branch>` or --base", strings.Join(baseBranchCandidates, ", "))
+}
+
+// branchExists reports whether branch exists locally or as a remote tracking
+// branch of origin
+func (r *Repo) branchExists(branch string) bool {
+	if _, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), true); err == nil {
+		return true
+	}
+	_, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	return err == nil
+}
+
+// noMergeBaseError wraps ErrNoMergeBase with the base branch, naming the
+// detected branch rather than "auto"
+func (r *Repo) noMergeBaseError(baseBranch string) error {
+	if detected, err := r.ResolveBaseBranch(baseBranch); err == nil {
+		baseBranch = detected
+	}
+	return fmt.Errorf("%w with %s; use --diff-mode direct to compare against its tree", ErrNoMergeBase, baseBranch)
+}
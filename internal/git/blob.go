@@ -0,0 +1,188 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// sniffLength is how much content http.DetectContentType looks at
+const sniffLength = 512
+
+// BinaryInfo describes both sides of a binary file change without its content
+type BinaryInfo struct {
+	OldSize  int64  `json:"old_size"`
+	NewSize  int64  `json:"new_size"`
+	MimeType string `json:"mime_type"`
+}
+
+// IsBinaryPatch reports whether a unified diff describes a binary file
+func IsBinaryPatch(patch string) bool {
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+			return true
+		}
+	}
+	return false
+}
+
+// binarySniffLength is how much content git inspects for a NUL byte when
+// deciding whether a file is binary
+const binarySniffLength = 8000
+
+// isBinaryContent applies git's heuristic: content with a NUL byte in its
+// first 8000 bytes is binary
+func isBinaryContent(content string) bool {
+	if len(content) > binarySniffLength {
+		content = content[:binarySniffLength]
+	}
+	return strings.IndexByte(content, 0) >= 0
+}
+
+// DescribeBinary reports the sizes and MIME type of a binary file change.
+// Committed changes compare baseCommit with headCommit, staged changes
+// compare baseCommit (normally HEAD) with the index and unstaged changes
+// compare the index with the working tree. A missing side (added or deleted
+// files) has size 0.
+func (r *Repo) DescribeBinary(file FileInfo, baseCommit, headCommit string) (*BinaryInfo, error) {
+	oldPath := file.Path
+	if file.FromPath != "" {
+		oldPath = file.FromPath
+	}
+
+	var oldBlob, newBlob *blob
+	var err error
+	switch file.StagingStatus {
+	case StagingStatusStaged:
+		if oldBlob, err = r.blobAtCommit(baseCommit, oldPath); err != nil {
+			return nil, err
+		}
+		newBlob, err = r.blobInIndex(file.Path)
+	case StagingStatusUnstaged:
+		if oldBlob, err = r.blobInIndex(oldPath); err != nil {
+			return nil, err
+		}
+		newBlob, err = r.blobInWorktree(file.Path)
+	default:
+		if oldBlob, err = r.blobAtCommit(baseCommit, oldPath); err != nil {
+			return nil, err
+		}
+		newBlob, err = r.blobAtCommit(headCommit, file.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BinaryInfo{
+		OldSize: oldBlob.size,
+		NewSize: newBlob.size,
+	}
+
+	info.MimeType = mime.TypeByExtension(filepath.Ext(file.Path))
+	if info.MimeType == "" {
+		head := newBlob.head
+		if newBlob.size == 0 {
+			head = oldBlob.head
+		}
+		info.MimeType = http.DetectContentType(head)
+	}
+
+	return info, nil
+}
+
+// blob is the size and leading bytes of one side of a file change. Files
+// missing on that side are reported as an empty blob.
+type blob struct {
+	size int64
+	head []byte
+}
+
+func (r *Repo) blobAtCommit(commit, filePath string) (*blob, error) {
+	if commit == "" {
+		return &blob{}, nil
+	}
+
+	commitObj, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", commit, err)
+	}
+
+	file, err := commitObj.File(filepath.ToSlash(filePath))
+	if err != nil {
+		return &blob{}, nil
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	return readBlob(file.Size, reader)
+}
+
+func (r *Repo) blobInIndex(filePath string) (*blob, error) {
+	index, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	entry, err := index.Entry(filepath.ToSlash(filePath))
+	if err != nil {
+		return &blob{}, nil
+	}
+
+	blobObj, err := r.repo.BlobObject(entry.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob for %s: %w", filePath, err)
+	}
+
+	reader, err := blobObj.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	return readBlob(blobObj.Size, reader)
+}
+
+func (r *Repo) blobInWorktree(filePath string) (*blob, error) {
+	fullPath, err := r.worktreePath(filePath)
+	if os.IsNotExist(err) {
+		return &blob{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return &blob{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+
+	return readBlob(stat.Size(), file)
+}
+
+func readBlob(size int64, reader io.Reader) (*blob, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return &blob{size: size, head: head[:n]}, nil
+}
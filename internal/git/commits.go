@@ -0,0 +1,65 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommitInfo describes a commit under review
+type CommitInfo struct {
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Date        time.Time `json:"date"`
+	Subject     string    `json:"subject"`
+	// Body is the message after the subject line, empty for one-line messages
+	Body string `json:"body,omitempty"`
+}
+
+// commitFormat separates the fields of a commit with the unit separator,
+// which can't appear in them; -z ends each commit with a NUL
+const commitFormat = "--format=%H%x1f%an%x1f%ae%x1f%at%x1f%s%x1f%b"
+
+// GetCommitRange returns the commits reachable from head but not from base,
+// like `git log base..head`, newest first
+func (r *Repo) GetCommitRange(base, head string) ([]CommitInfo, error) {
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "log", "-z", commitFormat, base+".."+head, "--")
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits between %s and %s: %w", base, head, err)
+	}
+
+	commits := []CommitInfo{}
+	for _, record := range strings.Split(string(output), "\x00") {
+		fields := strings.SplitN(record, "\x1f", 6)
+		if len(fields) != 6 {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date for commit %s: %w", fields[0], err)
+		}
+
+		commits = append(commits, CommitInfo{
+			Hash:        fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Date:        time.Unix(timestamp, 0).UTC(),
+			Subject:     fields[4],
+			Body:        strings.TrimSpace(fields[5]),
+		})
+	}
+
+	return commits, nil
+}
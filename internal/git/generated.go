@@ -0,0 +1,82 @@
+package git
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// generatedAttribute is the .gitattributes marker GitHub Linguist uses for
+// generated files
+const generatedAttribute = "linguist-generated"
+
+// markGenerated flags files that match one of the gitignore-style patterns
+// or are marked linguist-generated in .gitattributes. linguist-generated=false
+// overrides a matching pattern.
+func markGenerated(repoPath string, files []FileInfo, patterns []string) {
+	if len(files) == 0 {
+		return
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	isGenerated := generatedMatcher(repoPath, paths, patterns)
+
+	for i := range files {
+		files[i].Generated = isGenerated(files[i].Path)
+	}
+}
+
+// generatedMatcher reports which of paths are generated, by the rules of
+// markGenerated, looking up their attributes once up front
+func generatedMatcher(repoPath string, paths []string, patterns []string) func(path string) bool {
+	var parsed []gitignore.Pattern
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			parsed = append(parsed, gitignore.ParsePattern(pattern, nil))
+		}
+	}
+	matcher := gitignore.NewMatcher(parsed)
+
+	var attributes map[string]bool
+	if len(paths) > 0 {
+		attributes = generatedAttributes(repoPath, paths)
+	}
+
+	return func(path string) bool {
+		if generated, ok := attributes[path]; ok {
+			return generated
+		}
+		return matcher.Match(strings.Split(path, "/"), false)
+	}
+}
+
+// generatedAttributes returns the linguist-generated value of each path that
+// sets or unsets it. Paths without the attribute are left out, as is
+// everything when git can't be run.
+func generatedAttributes(repoPath string, paths []string) map[string]bool {
+	cmd := exec.Command("git", "check-attr", "-z", "--stdin", generatedAttribute)
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	// Output is a sequence of <path> NUL <attribute> NUL <value> NUL
+	attributes := make(map[string]bool)
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		switch fields[i+2] {
+		case "set", "true":
+			attributes[fields[i]] = true
+		case "unset", "false":
+			attributes[fields[i]] = false
+		}
+	}
+	return attributes
+}
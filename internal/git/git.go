@@ -1,11 +1,19 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -16,6 +24,9 @@ type Repo struct {
 	repo *git.Repository
 }
 
+// fetchTimeout bounds how long Fetch waits for the remote
+const fetchTimeout = 30 * time.Second
+
 // StagingStatus indicates whether a file change is staged, unstaged, or committed
 type StagingStatus string
 
@@ -32,6 +43,116 @@ type FileInfo struct {
 	Deletions     int           `json:"deletions"`
 	Patch         string        `json:"patch"`
 	StagingStatus StagingStatus `json:"staging_status,omitempty"`
+	FromPath      string        `json:"from_path,omitempty"` // Source path for copied and renamed files
+	// Similarity is git's similarity index (0-100) for renamed files
+	Similarity int `json:"similarity,omitempty"`
+	// Binary files have no line-based patch, so Additions and Deletions are 0
+	Binary bool `json:"binary,omitempty"`
+	// IsSymlink marks symbolic links, whose patch shows the path they point to
+	IsSymlink bool `json:"symlink,omitempty"`
+	// Generated files match DiffOptions.GeneratedPatterns or are marked
+	// linguist-generated in .gitattributes
+	Generated bool `json:"generated,omitempty"`
+	// Truncated is set when Patch was cut short at DiffOptions.MaxPatchBytes
+	Truncated bool `json:"truncated,omitempty"`
+	// SubmoduleFrom and SubmoduleTo are the commits a submodule pointed at
+	// before and after, for files with StatusSubmodule; Patch is empty. One is
+	// empty when the submodule was added or removed.
+	SubmoduleFrom string `json:"submodule_from,omitempty"`
+	SubmoduleTo   string `json:"submodule_to,omitempty"`
+}
+
+// StagingFilter selects which uncommitted changes to return
+type StagingFilter string
+
+const (
+	StagingFilterAll      StagingFilter = "all"
+	StagingFilterStaged   StagingFilter = "staged"
+	StagingFilterUnstaged StagingFilter = "unstaged"
+)
+
+// ParseStagingFilter validates a staging filter name; empty means all
+func ParseStagingFilter(value string) (StagingFilter, error) {
+	switch StagingFilter(value) {
+	case "", StagingFilterAll:
+		return StagingFilterAll, nil
+	case StagingFilterStaged, StagingFilterUnstaged:
+		return StagingFilter(value), nil
+	default:
+		return "", fmt.Errorf("invalid staging filter %q (expected all, staged or unstaged)", value)
+	}
+}
+
+// DiffMode selects what GetDiffFiles compares HEAD against
+type DiffMode string
+
+const (
+	// DiffModeMergeBase compares against the merge base of the base ref and
+	// HEAD, showing only the branch's own changes (git diff base...HEAD)
+	DiffModeMergeBase DiffMode = "merge-base"
+	// DiffModeDirect compares against the base ref itself, so changes made on
+	// the base since the branch diverged show up reversed (git diff base..HEAD)
+	DiffModeDirect DiffMode = "direct"
+)
+
+// ParseDiffMode validates a diff mode name; empty means merge-base
+func ParseDiffMode(value string) (DiffMode, error) {
+	switch DiffMode(value) {
+	case "", DiffModeMergeBase:
+		return DiffModeMergeBase, nil
+	case DiffModeDirect:
+		return DiffModeDirect, nil
+	default:
+		return "", fmt.Errorf("invalid diff mode %q (expected %s or %s)", value, DiffModeMergeBase, DiffModeDirect)
+	}
+}
+
+// DiffResult is a set of file changes between two points in history
+type DiffResult struct {
+	// BaseCommit is the commit the changes are relative to. For GetDiffFiles
+	// this is the merge base in DiffModeMergeBase and the base ref's own
+	// commit in DiffModeDirect.
+	BaseCommit string `json:"base_commit"`
+	// HeadCommit is the commit containing the changes; empty when the changes
+	// come from the index or working tree
+	HeadCommit string     `json:"head_commit,omitempty"`
+	Files      []FileInfo `json:"files"`
+}
+
+// StatusDiscrepancy describes a file whose status differs between go-git and
+// native git. Codes use the two-letter `git status --porcelain` format, with
+// "  " meaning the source considers the file unchanged.
+type StatusDiscrepancy struct {
+	Path   string `json:"path"`
+	GoGit  string `json:"go_git"`
+	Native string `json:"native"`
+}
+
+// DiffOptions controls how diffs are computed
+type DiffOptions struct {
+	// IgnoreWhitespace hides changes that only affect whitespace (like `git diff -w`)
+	IgnoreWhitespace bool
+	// GeneratedPatterns are gitignore-style patterns of files to flag as
+	// generated, in addition to .gitattributes linguist-generated markers
+	GeneratedPatterns []string
+	// Mode selects what GetDiffFiles compares against; empty means
+	// DiffModeMergeBase
+	Mode DiffMode
+	// Paths limits diffs to these files, skipping the patches of all others;
+	// empty means every file
+	Paths []string
+	// MaxPatchLines and MaxPatchBytes truncate longer patches, setting
+	// FileInfo.Truncated; 0 means no limit
+	MaxPatchLines int
+	MaxPatchBytes int
+	// SkipPatches leaves FileInfo.Patch empty, taking line counts from
+	// `git diff --numstat` instead of building every patch
+	SkipPatches bool
+}
+
+// includes reports whether Paths selects filePath
+func (o DiffOptions) includes(filePath string) bool {
+	return len(o.Paths) == 0 || slices.Contains(o.Paths, filePath)
 }
 
 func Open(path string) (*Repo, error) {
@@ -45,6 +166,19 @@ func Open(path string) (*Repo, error) {
 	return &Repo{repo: repo}, nil
 }
 
+// IsRepo reports whether path is inside a git repository
+func IsRepo(path string) bool {
+	_, err := Open(path)
+	return err == nil
+}
+
+// DetachedBranchPrefix starts the branch name CurrentBranch reports for a
+// detached HEAD
+const DetachedBranchPrefix = "detached@"
+
+// CurrentBranch returns the checked-out branch. A detached HEAD is reported as
+// detached@<short hash>, so review state recorded there stays with its commit
+// instead of being shared by every detached checkout.
 func (r *Repo) CurrentBranch() (string, error) {
 	head, err := r.repo.Head()
 	if err != nil {
@@ -52,7 +186,7 @@ func (r *Repo) CurrentBranch() (string, error) {
 	}
 
 	if !head.Name().IsBranch() {
-		return "HEAD", nil
+		return DetachedBranchPrefix + head.Hash().String()[:7], nil
 	}
 
 	return head.Name().Short(), nil
@@ -81,100 +215,410 @@ func (r *Repo) RepoPath() (string, error) {
 	return absPath, nil
 }
 
-// GetRemoteURL returns the URL of the origin remote, or empty string if not found
-func (r *Repo) GetRemoteURL() (string, error) {
-	remote, err := r.repo.Remote("origin")
+// GitDir returns the absolute path of the repository's git directory, which
+// is .git for normal checkouts and lives elsewhere for linked worktrees
+func (r *Repo) GitDir() (string, error) {
+	repoPath, err := r.RepoPath()
 	if err != nil {
-		// No origin remote, return empty string
-		return "", nil
+		return "", err
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find git directory: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ReadFileAtCommit returns the content of a file as stored in the given commit
+func (r *Repo) ReadFileAtCommit(commit, filePath string) (string, error) {
+	commitObj, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit %s: %w", commit, err)
+	}
+
+	file, err := commitObj.File(filepath.ToSlash(filePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s at %s: %w", filePath, commit, err)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	return content, nil
+}
+
+// CommitTime returns when a commit was made. It fails if the repository no
+// longer has the commit, such as after a rebased branch was garbage collected.
+func (r *Repo) CommitTime(commit string) (time.Time, error) {
+	commitObj, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get commit %s: %w", commit, err)
+	}
+
+	return commitObj.Committer.When, nil
+}
+
+// MatchingLines returns the 1-based numbers of the lines in content that match re
+func MatchingLines(content string, re *regexp.Regexp) []int {
+	lines := []int{}
+	for i, line := range strings.Split(content, "\n") {
+		if re.MatchString(line) {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// GetRemoteURL returns the name and browseable URL of the origin remote,
+// falling back to the first remote by name when there is no origin. Both are
+// empty if the repository has no remotes.
+func (r *Repo) GetRemoteURL() (string, string, error) {
+	remotes, err := r.repo.Remotes()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list remotes: %w", err)
+	}
+	if len(remotes) == 0 {
+		return "", "", nil
+	}
+
+	name := "origin"
+	if _, err := r.repo.Remote(name); err != nil {
+		names := make([]string, 0, len(remotes))
+		for _, remote := range remotes {
+			names = append(names, remote.Config().Name)
+		}
+		sort.Strings(names)
+		name = names[0]
+	}
+
+	remoteURL, err := r.GetRemoteURLFor(name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, remoteURL, nil
+}
+
+// GetRemoteURLFor returns the browseable URL of the named remote, or empty
+// string if it has no URL
+func (r *Repo) GetRemoteURLFor(name string) (string, error) {
+	remote, err := r.repo.Remote(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find remote %s: %w", name, err)
 	}
 
 	if len(remote.Config().URLs) == 0 {
 		return "", nil
 	}
 
-	return remote.Config().URLs[0], nil
+	return NormalizeRemoteURL(remote.Config().URLs[0]), nil
 }
 
-func (r *Repo) GetDiffFiles(baseBranch string) ([]FileInfo, error) {
-	// Try to get the remote tracking branch first (origin/baseBranch)
-	// This ensures we compare against the remote version even if local is outdated
-	remoteBranchRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", baseBranch), true)
+// scpRemoteURL matches scp-like SSH remotes such as git@github.com:org/repo.git
+var scpRemoteURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
 
-	var baseCommit *object.Commit
-	if err == nil {
-		// Remote tracking branch exists, use it
-		baseCommit, err = r.repo.CommitObject(remoteBranchRef.Hash())
-		if err != nil {
-			return nil, fmt.Errorf("failed to get remote base commit: %w", err)
+// NormalizeRemoteURL rewrites SSH remotes (git@host:org/repo.git and
+// ssh://git@host/org/repo.git) and HTTP(S) remotes to https://host/org/repo,
+// dropping credentials and the .git suffix. Local paths are returned as is.
+func NormalizeRemoteURL(remoteURL string) string {
+	var host, path string
+	if parsed, err := url.Parse(remoteURL); err == nil && parsed.Host != "" {
+		switch parsed.Scheme {
+		case "ssh", "git+ssh", "http", "https", "git":
+			host, path = parsed.Hostname(), parsed.Path
+			if parsed.Scheme != "ssh" && parsed.Scheme != "git+ssh" && parsed.Port() != "" {
+				host = parsed.Host
+			}
+		default:
+			return remoteURL
 		}
+	} else if match := scpRemoteURL.FindStringSubmatch(remoteURL); match != nil && !strings.Contains(remoteURL, "://") {
+		host, path = match[1], match[2]
 	} else {
-		// Fall back to local branch if remote tracking branch doesn't exist
-		baseBranchRef, err := r.repo.Reference(plumbing.NewBranchReferenceName(baseBranch), true)
+		return remoteURL
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return "https://" + host + "/" + path
+}
+
+// Fetch updates the remote-tracking refs for the given remote using the git CLI,
+// so configured credential helpers are honored. It never prompts for input.
+func (r *Repo) Fetch(remote string) error {
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--quiet", remote)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w: %s", remote, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// FileHistoryPaths returns every path a file has had in the history of HEAD,
+// following renames. The given path is always the first entry.
+func (r *Repo) FileHistoryPaths(filePath string) ([]string, error) {
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "log", "--follow", "--name-only", "--format=", "-z", "--", filePath)
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history for %s: %w", filePath, err)
+	}
+
+	paths := []string{filePath}
+	seen := map[string]bool{filePath: true}
+	// -z ends each path with a NUL and leaves it unquoted
+	for _, path := range strings.Split(string(output), "\x00") {
+		path = strings.TrimPrefix(path, "\n")
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// ResolveRef returns the commit a base ref names. Branch names prefer the
+// remote tracking branch (origin/<ref>) so the comparison isn't thrown off by
+// an outdated local branch; anything else, such as HEAD~3, a tag or a commit
+// hash, is resolved as a revision. AutoBaseBranch resolves the detected
+// default branch.
+func (r *Repo) ResolveRef(ref string) (*object.Commit, error) {
+	ref, err := r.ResolveBaseBranch(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if remoteRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", ref), true); err == nil {
+		commit, err := r.repo.CommitObject(remoteRef.Hash())
 		if err != nil {
-			return nil, fmt.Errorf("failed to find branch %s: %w", baseBranch, err)
+			return nil, fmt.Errorf("failed to get remote base commit: %w", err)
 		}
+		return commit, nil
+	}
 
-		baseCommit, err = r.repo.CommitObject(baseBranchRef.Hash())
+	if branchRef, err := r.repo.Reference(plumbing.NewBranchReferenceName(ref), true); err == nil {
+		commit, err := r.repo.CommitObject(branchRef.Hash())
 		if err != nil {
 			return nil, fmt.Errorf("failed to get base commit: %w", err)
 		}
+		return commit, nil
+	}
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find branch or revision %s: %w", ref, err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base commit: %w", err)
+	}
+	return commit, nil
+}
+
+// GetDiffFiles returns the changes committed on HEAD since its merge base with
+// baseBranch, which may be any ref ResolveRef accepts. With DiffModeDirect it
+// compares against baseBranch's commit instead.
+func (r *Repo) GetDiffFiles(baseBranch string, opts DiffOptions) (*DiffResult, error) {
+	files := []FileInfo{}
+	diff, err := r.EachDiffFile(baseBranch, opts, func(file FileInfo) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	diff.Files = files
+	return diff, nil
+}
+
+// EachDiffFile computes the same changes as GetDiffFiles but passes each file
+// to fn as soon as its patch is ready instead of collecting them, so only one
+// patch is held at a time. It stops at the first error fn returns. The
+// returned DiffResult has no Files.
+func (r *Repo) EachDiffFile(baseBranch string, opts DiffOptions, fn func(FileInfo) error) (*DiffResult, error) {
+	comparedCommit, headCommit, err := r.comparedCommits(baseBranch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := comparedCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base tree: %w", err)
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+
+	if err := r.eachTreeChange(baseTree, headTree, opts, fn); err != nil {
+		return nil, err
+	}
+
+	return &DiffResult{
+		BaseCommit: comparedCommit.Hash.String(),
+		HeadCommit: headCommit.Hash.String(),
+	}, nil
+}
+
+// DiffBaseCommit returns the commit GetDiffFiles compares HEAD against: the
+// merge base with baseBranch, or baseBranch's own commit in DiffModeDirect
+func (r *Repo) DiffBaseCommit(baseBranch string, opts DiffOptions) (string, error) {
+	comparedCommit, _, err := r.comparedCommits(baseBranch, opts)
+	if err != nil {
+		return "", err
+	}
+	return comparedCommit.Hash.String(), nil
+}
+
+// comparedCommits resolves the commit the branch's changes are relative to
+// and the HEAD commit containing them
+func (r *Repo) comparedCommits(baseBranch string, opts DiffOptions) (compared, head *object.Commit, err error) {
+	baseCommit, err := r.ResolveRef(baseBranch)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Get the current HEAD commit
-	head, err := r.repo.Head()
+	headRef, err := r.repo.Head()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+		return nil, nil, fmt.Errorf("failed to get HEAD: %w", err)
 	}
 
-	headCommit, err := r.repo.CommitObject(head.Hash())
+	headCommit, err := r.repo.CommitObject(headRef.Hash())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+		return nil, nil, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
 
-	// Find the merge base between base branch and HEAD
+	// Direct mode compares against the base itself
+	if opts.Mode == DiffModeDirect {
+		return baseCommit, headCommit, nil
+	}
+
+	// Otherwise use the merge base between base branch and HEAD as the
+	// comparison point
 	mergeBase, err := headCommit.MergeBase(baseCommit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find merge base: %w", err)
+		return nil, nil, fmt.Errorf("failed to find merge base: %w", err)
+	}
+	// Diffing against the base's tree would show every file as changed
+	if len(mergeBase) == 0 {
+		return nil, nil, r.noMergeBaseError(baseBranch)
 	}
 
-	// Use the merge base as the comparison point
-	var baseTree *object.Tree
-	if len(mergeBase) > 0 {
-		baseTree, err = mergeBase[0].Tree()
+	return mergeBase[0], headCommit, nil
+}
+
+// ErrUnknownCommit reports a revision GetCommitDiff can't resolve to a commit
+var ErrUnknownCommit = errors.New("unknown commit")
+
+// GetCommitDiff returns the changes a single commit made, compared against
+// its first parent. sha may be any revision naming a commit. A root commit
+// is compared against an empty tree, so every file is added.
+func (r *Repo) GetCommitDiff(sha string, opts DiffOptions) (*DiffResult, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrUnknownCommit, sha, err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", sha, err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit tree: %w", err)
+	}
+
+	diff := &DiffResult{HeadCommit: commit.Hash.String(), Files: []FileInfo{}}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get merge base tree: %w", err)
+			return nil, fmt.Errorf("failed to get parent of %s: %w", sha, err)
 		}
-	} else {
-		// Fallback to base branch if no merge base found
-		baseTree, err = baseCommit.Tree()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get base tree: %w", err)
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get parent tree: %w", err)
 		}
+		diff.BaseCommit = parent.Hash.String()
 	}
 
-	headTree, err := headCommit.Tree()
+	err = r.eachTreeChange(parentTree, tree, opts, func(file FileInfo) error {
+		diff.Files = append(diff.Files, file)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+		return nil, err
 	}
 
-	// Get the diff
-	changes, err := baseTree.Diff(headTree)
+	return diff, nil
+}
+
+// eachTreeChange passes the changes from baseTree to headTree to fn, one file
+// at a time. A nil baseTree is empty.
+func (r *Repo) eachTreeChange(baseTree, headTree *object.Tree, opts DiffOptions, fn func(FileInfo) error) error {
+	changes, err := object.DiffTree(baseTree, headTree)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create diff: %w", err)
+		return fmt.Errorf("failed to create diff: %w", err)
 	}
 
-	files := []FileInfo{}
+	// Look up generated files by name before computing any patches
+	var paths []string
+	for _, change := range changes {
+		if filePath := changePath(change); opts.includes(filePath) {
+			paths = append(paths, filePath)
+		}
+	}
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return err
+	}
+	isGenerated := generatedMatcher(repoPath, paths, opts.GeneratedPatterns)
+
+	var stats map[string]fileStat
+	if opts.SkipPatches {
+		if stats, err = treeStats(repoPath, baseTree, headTree, paths, opts); err != nil {
+			return err
+		}
+	}
 
 	for _, change := range changes {
-		patch, err := change.Patch()
-		if err != nil {
+		filePath := changePath(change)
+		if !opts.includes(filePath) {
 			continue
 		}
 
-		filePath := change.To.Name
-		if filePath == "" {
-			filePath = change.From.Name
+		if from, to, ok := changeSubmoduleCommits(change); ok {
+			file := FileInfo{Path: filePath}
+			file.markSubmodule(from, to)
+			if err := fn(file); err != nil {
+				return err
+			}
+			continue
 		}
 
 		status := "modified"
@@ -187,37 +631,131 @@ func (r *Repo) GetDiffFiles(baseBranch string) ([]FileInfo, error) {
 			status = "renamed"
 		}
 
-		// Count additions and deletions from the patch string
-		additions := 0
-		deletions := 0
-		patchStr := patch.String()
-
-		lines := strings.Split(patchStr, "\n")
-		for _, line := range lines {
-			if len(line) == 0 {
+		if opts.SkipPatches {
+			stat := stats[filePath]
+			// Modified files whose changes were all whitespace aren't counted
+			if !stat.counted && opts.IgnoreWhitespace && status == "modified" {
 				continue
 			}
-			if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
-				additions++
-			} else if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---") {
-				deletions++
+			file := FileInfo{
+				Path:      filePath,
+				Status:    status,
+				Additions: stat.additions,
+				Deletions: stat.deletions,
+				Binary:    stat.binary,
+				IsSymlink: isSymlinkChange(change),
+				Generated: isGenerated(filePath),
+			}
+			if err := fn(file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		patch, err := change.Patch()
+		if err != nil {
+			continue
+		}
+
+		patchStr := patch.String()
+		binary := IsBinaryPatch(patchStr)
+		if opts.IgnoreWhitespace && !binary {
+			patchStr = filterWhitespaceOnlyHunks(patchStr)
+			// Skip modified files whose changes were all whitespace
+			if status == "modified" && !strings.Contains(patchStr, "\n@@") {
+				continue
 			}
 		}
 
-		files = append(files, FileInfo{
+		additions, deletions := countPatchLines(patchStr)
+
+		file := FileInfo{
 			Path:      filePath,
 			Status:    status,
 			Additions: additions,
 			Deletions: deletions,
 			Patch:     patchStr,
-		})
+			Binary:    binary,
+			IsSymlink: isSymlinkChange(change),
+			Generated: isGenerated(filePath),
+		}
+		file.truncatePatch(opts.MaxPatchLines, opts.MaxPatchBytes)
+		if err := fn(file); err != nil {
+			return err
+		}
 	}
 
-	return files, nil
+	return nil
+}
+
+// treeStats counts the changed lines of paths between two trees. A nil
+// baseTree is empty.
+func treeStats(repoPath string, baseTree, headTree *object.Tree, paths []string, opts DiffOptions) (map[string]fileStat, error) {
+	base := emptyTreeHash
+	if baseTree != nil {
+		base = baseTree.Hash.String()
+	}
+
+	args := []string{"--no-renames"}
+	if opts.IgnoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	args = append(args, base, headTree.Hash.String())
+	if len(opts.Paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	return diffStats(repoPath, args...)
+}
+
+// changePath is the path a tree change is reported under: its destination,
+// or its source when the file was deleted
+func changePath(change *object.Change) string {
+	if change.To.Name != "" {
+		return change.To.Name
+	}
+	return change.From.Name
+}
+
+// truncatePatch cuts the patch down to the first maxLines lines and at most
+// maxBytes, ending at a line boundary where possible, and marks the file
+// Truncated. Additions and Deletions keep counting the whole patch. A limit
+// of 0 means no limit.
+func (f *FileInfo) truncatePatch(maxLines, maxBytes int) {
+	cut := len(f.Patch)
+	if maxLines > 0 {
+		for i, lines := 0, 0; i < cut; i++ {
+			if f.Patch[i] == '\n' {
+				if lines++; lines == maxLines {
+					cut = i + 1
+				}
+			}
+		}
+	}
+	if maxBytes > 0 && cut > maxBytes {
+		cut = maxBytes
+		if newline := strings.LastIndexByte(f.Patch[:maxBytes], '\n'); newline >= 0 {
+			cut = newline + 1
+		}
+	}
+
+	if cut < len(f.Patch) {
+		f.Patch = f.Patch[:cut]
+		f.Truncated = true
+	}
 }
 
 // GetUncommittedChanges returns all uncommitted changes (both staged and unstaged)
-func (r *Repo) GetUncommittedChanges() ([]FileInfo, error) {
+func (r *Repo) GetUncommittedChanges(opts DiffOptions) ([]FileInfo, error) {
+	return r.GetUncommittedChangesFiltered(StagingFilterAll, opts)
+}
+
+// GetUncommittedChangesFiltered returns the uncommitted changes selected by
+// filter. A file with both staged and unstaged changes contributes one entry
+// to each side.
+func (r *Repo) GetUncommittedChangesFiltered(filter StagingFilter, opts DiffOptions) ([]FileInfo, error) {
+	includeStaged := filter != StagingFilterUnstaged
+	includeUnstaged := filter != StagingFilterStaged
+
 	repoPath, err := r.RepoPath()
 	if err != nil {
 		return nil, err
@@ -232,40 +770,78 @@ func (r *Repo) GetUncommittedChanges() ([]FileInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree status: %w", err)
 	}
+	reconcileStatus(repoPath, status)
+
+	// go-git does not detect copies or renames, so ask git which staged
+	// additions are copies or renames
+	sources := loadStagedSources(repoPath)
 
 	files := []FileInfo{}
 
 	for filePath, fileStatus := range status {
+		if !opts.includes(filePath) {
+			continue
+		}
+
 		// Check if file has staged changes (index vs HEAD)
-		if fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked {
-			fileInfo, err := r.getFileInfoWithGitDiff(repoPath, filePath, fileStatus.Staging, StagingStatusStaged)
-			if err == nil {
+		if includeStaged {
+			if fileInfo, ok := r.stagedFileInfo(repoPath, filePath, fileStatus, sources, opts); ok {
 				files = append(files, fileInfo)
 			}
 		}
 
+		if !includeUnstaged {
+			continue
+		}
+
 		// Check if file has unstaged changes (worktree vs index)
 		if fileStatus.Worktree != git.Unmodified && fileStatus.Worktree != git.Untracked {
-			fileInfo, err := r.getFileInfoWithGitDiff(repoPath, filePath, fileStatus.Worktree, StagingStatusUnstaged)
-			if err == nil {
+			fileInfo, err := r.getFileInfoWithGitDiff(repoPath, filePath, "", fileStatus.Worktree, StagingStatusUnstaged, opts)
+			if err == nil && !isHiddenWhitespaceChange(fileInfo, opts) {
 				files = append(files, fileInfo)
 			}
 		}
 
 		// Handle untracked files as unstaged additions
 		if fileStatus.Worktree == git.Untracked {
+			// A symlink's content is its target, as git stores it
+			if target, ok := r.worktreeSymlink(filePath); ok {
+				files = append(files, FileInfo{
+					Path:          filePath,
+					Status:        "added",
+					Additions:     1,
+					Patch:         fmt.Sprintf("diff --git a/%s b/%s\nnew file mode %s\n--- /dev/null\n+++ b/%s\n@@ -0,0 +1 @@\n+%s\n\\ No newline at end of file\n", filePath, filePath, symlinkMode, filePath, target),
+					StagingStatus: StagingStatusUnstaged,
+					IsSymlink:     true,
+				})
+				continue
+			}
+
 			content, err := r.readWorktreeFile(filePath)
 			if err != nil {
 				continue
 			}
+			if isBinaryContent(content) {
+				files = append(files, FileInfo{
+					Path:          filePath,
+					Status:        "added",
+					Patch:         fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\nBinary files /dev/null and b/%s differ\n", filePath, filePath, filePath),
+					StagingStatus: StagingStatusUnstaged,
+					Binary:        true,
+				})
+				continue
+			}
 			additions := strings.Count(content, "\n")
 			if len(content) > 0 && !strings.HasSuffix(content, "\n") {
 				additions++
 			}
-			patch := fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\n--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", filePath, filePath, filePath, additions)
-			for _, line := range strings.Split(content, "\n") {
-				if line != "" || !strings.HasSuffix(content, "\n") {
-					patch += "+" + line + "\n"
+			patch := ""
+			if !opts.SkipPatches {
+				patch = fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\n--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", filePath, filePath, filePath, additions)
+				for _, line := range strings.Split(content, "\n") {
+					if line != "" || !strings.HasSuffix(content, "\n") {
+						patch += "+" + line + "\n"
+					}
 				}
 			}
 			files = append(files, FileInfo{
@@ -279,11 +855,316 @@ func (r *Repo) GetUncommittedChanges() ([]FileInfo, error) {
 		}
 	}
 
+	markGenerated(repoPath, files, opts.GeneratedPatterns)
+	for i := range files {
+		if opts.SkipPatches {
+			files[i].Patch = ""
+		}
+		files[i].truncatePatch(opts.MaxPatchLines, opts.MaxPatchBytes)
+	}
+
 	return files, nil
 }
 
-// getFileInfoWithGitDiff uses git diff command for proper unified diff output
-func (r *Repo) getFileInfoWithGitDiff(repoPath, filePath string, statusCode git.StatusCode, stagingStatus StagingStatus) (FileInfo, error) {
+// GetStagedDiff returns the changes staged in the index relative to HEAD,
+// i.e. what the next commit would contain, independent of any base branch
+func (r *Repo) GetStagedDiff(opts DiffOptions) (*DiffResult, error) {
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.CurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree status: %w", err)
+	}
+	reconcileStatus(repoPath, status)
+
+	sources := loadStagedSources(repoPath)
+
+	files := []FileInfo{}
+	for filePath, fileStatus := range status {
+		if !opts.includes(filePath) {
+			continue
+		}
+		if fileInfo, ok := r.stagedFileInfo(repoPath, filePath, fileStatus, sources, opts); ok {
+			files = append(files, fileInfo)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	markGenerated(repoPath, files, opts.GeneratedPatterns)
+	for i := range files {
+		files[i].truncatePatch(opts.MaxPatchLines, opts.MaxPatchBytes)
+	}
+
+	return &DiffResult{
+		BaseCommit: headCommit,
+		Files:      files,
+	}, nil
+}
+
+// stagedFileInfo returns the index-vs-HEAD diff of a file, reporting false
+// when the file has no staged changes or is the source of a staged rename
+func (r *Repo) stagedFileInfo(repoPath, filePath string, fileStatus *git.FileStatus, sources stagedSources, opts DiffOptions) (FileInfo, bool) {
+	if fileStatus.Staging == git.Unmodified || fileStatus.Staging == git.Untracked {
+		return FileInfo{}, false
+	}
+
+	// The deletion half of a rename is reported with its destination
+	if fileStatus.Staging == git.Deleted && sources.renamedFrom[filePath] {
+		return FileInfo{}, false
+	}
+
+	statusCode := fileStatus.Staging
+	fromPath := ""
+	similarity := 0
+	if statusCode == git.Added {
+		if rename, ok := sources.renames[filePath]; ok {
+			statusCode = git.Renamed
+			fromPath = rename.fromPath
+			similarity = rename.similarity
+		} else if source, ok := sources.copies[filePath]; ok {
+			statusCode = git.Copied
+			fromPath = source
+		}
+	}
+
+	fileInfo, err := r.getFileInfoWithGitDiff(repoPath, filePath, fromPath, statusCode, StagingStatusStaged, opts)
+	if err != nil || isHiddenWhitespaceChange(fileInfo, opts) {
+		return FileInfo{}, false
+	}
+	fileInfo.Similarity = similarity
+
+	return fileInfo, true
+}
+
+// stagedSources records staged files that git detects as copies or renames
+// of other files
+type stagedSources struct {
+	copies      map[string]string       // destination -> source
+	renames     map[string]stagedRename // destination -> rename
+	renamedFrom map[string]bool         // rename sources
+}
+
+type stagedRename struct {
+	fromPath   string
+	similarity int
+}
+
+// loadStagedSources detects staged copies and renames. Detection failures
+// are not fatal: the files are then reported as plain additions and deletions.
+func loadStagedSources(repoPath string) stagedSources {
+	sources := stagedSources{
+		copies:      map[string]string{},
+		renames:     map[string]stagedRename{},
+		renamedFrom: map[string]bool{},
+	}
+
+	if copies, err := stagedCopies(repoPath); err == nil {
+		sources.copies = copies
+	}
+
+	if renames, err := stagedRenames(repoPath); err == nil {
+		sources.renames = renames
+		for _, rename := range renames {
+			sources.renamedFrom[rename.fromPath] = true
+		}
+	}
+
+	return sources
+}
+
+// stagedRenames returns staged renames keyed by destination path, using
+// `git status --porcelain=v2` which reports the similarity index
+func stagedRenames(repoPath string) (map[string]stagedRename, error) {
+	cmd := exec.Command("git", "status", "--porcelain=v2", "-z", "-M", "--untracked-files=no")
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect renames: %w", err)
+	}
+
+	renames := map[string]stagedRename{}
+	for _, entry := range parsePorcelainV2(string(output)) {
+		if entry.staging != git.Renamed || !strings.HasPrefix(entry.score, "R") {
+			continue
+		}
+
+		similarity, err := strconv.Atoi(strings.TrimPrefix(entry.score, "R"))
+		if err != nil {
+			continue
+		}
+
+		renames[entry.path] = stagedRename{fromPath: entry.origPath, similarity: similarity}
+	}
+
+	return renames, nil
+}
+
+// StatusDiscrepancies compares the worktree status reported by go-git with
+// `git status` and returns the files on which they disagree. An empty
+// filePath compares every file.
+func (r *Repo) StatusDiscrepancies(filePath string) ([]StatusDiscrepancy, error) {
+	repoPath, err := r.RepoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree status: %w", err)
+	}
+
+	native, err := nativeStatus(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for path := range status {
+		paths[path] = true
+	}
+	for path := range native {
+		paths[path] = true
+	}
+
+	discrepancies := []StatusDiscrepancy{}
+	for path := range paths {
+		if filePath != "" && path != filePath {
+			continue
+		}
+		goGitCode := statusCodes(status[path])
+		nativeCode := statusCodes(native[path])
+		if goGitCode != nativeCode {
+			discrepancies = append(discrepancies, StatusDiscrepancy{
+				Path:   path,
+				GoGit:  goGitCode,
+				Native: nativeCode,
+			})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].Path < discrepancies[j].Path })
+
+	return discrepancies, nil
+}
+
+// reconcileStatus clears changes that go-git reports but native git does not,
+// e.g. mode changes with core.fileMode=false or line-ending normalization.
+// If git cannot be run the go-git status is left untouched.
+func reconcileStatus(repoPath string, status git.Status) {
+	native, err := nativeStatus(repoPath)
+	if err != nil {
+		return
+	}
+
+	for path, fileStatus := range status {
+		nativeFile, ok := native[path]
+		if !ok {
+			delete(status, path)
+			continue
+		}
+		if nativeFile.Staging == git.Unmodified {
+			fileStatus.Staging = git.Unmodified
+		}
+		if nativeFile.Worktree == git.Unmodified {
+			fileStatus.Worktree = git.Unmodified
+		}
+	}
+}
+
+// nativeStatus runs `git status` and returns the changed files in go-git's
+// representation. Staged renames and copies are reported as an addition of
+// the destination (plus a deletion of a renamed source), matching go-git.
+func nativeStatus(repoPath string) (git.Status, error) {
+	cmd := exec.Command("git", "status", "--porcelain=v2", "-z", "--untracked-files=all")
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git status: %w", err)
+	}
+
+	status := git.Status{}
+	for _, entry := range parsePorcelainV2(string(output)) {
+		staging := entry.staging
+		if staging == git.Renamed {
+			status[entry.origPath] = &git.FileStatus{Staging: git.Deleted, Worktree: git.Unmodified}
+		}
+		if staging == git.Renamed || staging == git.Copied {
+			staging = git.Added
+		}
+
+		status[entry.path] = &git.FileStatus{Staging: staging, Worktree: entry.worktree}
+	}
+
+	return status, nil
+}
+
+// statusCodes formats a file status as a two-letter porcelain code
+func statusCodes(fileStatus *git.FileStatus) string {
+	if fileStatus == nil {
+		return "  "
+	}
+	return string([]byte{byte(fileStatus.Staging), byte(fileStatus.Worktree)})
+}
+
+// stagedCopies returns staged files that git detects as copies, keyed by the
+// destination path with the source path as value
+func stagedCopies(repoPath string) (map[string]string, error) {
+	cmd := exec.Command("git", "diff", "--cached", "-C", "--find-copies-harder", "--name-status", "-z")
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect copies: %w", err)
+	}
+
+	copies := map[string]string{}
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		code := fields[i]
+		if code == "" {
+			continue
+		}
+
+		// Renames and copies are followed by source and destination paths
+		if strings.HasPrefix(code, "R") || strings.HasPrefix(code, "C") {
+			if i+2 >= len(fields) {
+				break
+			}
+			if strings.HasPrefix(code, "C") {
+				copies[fields[i+2]] = fields[i+1]
+			}
+			i += 2
+			continue
+		}
+		i++
+	}
+
+	return copies, nil
+}
+
+// getFileInfoWithGitDiff uses git diff command for proper unified diff output.
+// fromPath is the copy source for copied files and empty otherwise.
+func (r *Repo) getFileInfoWithGitDiff(repoPath, filePath, fromPath string, statusCode git.StatusCode, stagingStatus StagingStatus, opts DiffOptions) (FileInfo, error) {
 	status := "modified"
 	switch statusCode {
 	case git.Added:
@@ -293,18 +1174,34 @@ func (r *Repo) getFileInfoWithGitDiff(repoPath, filePath string, statusCode git.
 	case git.Renamed:
 		status = "renamed"
 	case git.Copied:
-		status = "added"
+		status = "copied"
+	}
+
+	if opts.SkipPatches {
+		return r.getFileInfoWithNumstat(repoPath, filePath, fromPath, status, stagingStatus, opts)
 	}
 
 	// Use git diff command for proper unified diff
-	var cmd *exec.Cmd
+	args := []string{"diff"}
 	if stagingStatus == StagingStatusStaged {
 		// Staged changes: compare index to HEAD
-		cmd = exec.Command("git", "diff", "--cached", "--", filePath)
-	} else {
-		// Unstaged changes: compare worktree to index
-		cmd = exec.Command("git", "diff", "--", filePath)
+		args = append(args, "--cached")
 	}
+	if opts.IgnoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	switch {
+	case fromPath != "" && statusCode == git.Renamed:
+		// Include the source so git renders the rename relationship
+		args = append(args, "-M", "--", fromPath, filePath)
+	case fromPath != "":
+		// Include the source so git renders the copy relationship
+		args = append(args, "-C", "--find-copies-harder", "--", fromPath, filePath)
+	default:
+		args = append(args, "--", filePath)
+	}
+
+	cmd := exec.Command("git", args...)
 	cmd.Dir = repoPath
 
 	output, err := cmd.Output()
@@ -317,16 +1214,94 @@ func (r *Repo) getFileInfoWithGitDiff(repoPath, filePath string, statusCode git.
 			Deletions:     0,
 			Patch:         "",
 			StagingStatus: stagingStatus,
+			FromPath:      fromPath,
 		}, nil
 	}
 
 	patch := string(output)
 
-	// Count additions and deletions
+	additions, deletions := countPatchLines(patch)
+
+	file := FileInfo{
+		Path:          filePath,
+		Status:        status,
+		Additions:     additions,
+		Deletions:     deletions,
+		Patch:         patch,
+		StagingStatus: stagingStatus,
+		FromPath:      fromPath,
+		Binary:        IsBinaryPatch(patch),
+		IsSymlink:     isSymlinkPatch(patch),
+	}
+	if from, to, ok := patchSubmoduleCommits(patch); ok {
+		file.markSubmodule(from, to)
+	}
+	return file, nil
+}
+
+// getFileInfoWithNumstat is getFileInfoWithGitDiff without the patch, for
+// DiffOptions.SkipPatches. It returns errWhitespaceOnly for a modified file
+// with no remaining changes once whitespace is ignored.
+func (r *Repo) getFileInfoWithNumstat(repoPath, filePath, fromPath, status string, stagingStatus StagingStatus, opts DiffOptions) (FileInfo, error) {
+	args := []string{}
+	if stagingStatus == StagingStatusStaged {
+		args = append(args, "--cached")
+	}
+	if opts.IgnoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	switch {
+	case fromPath != "" && status == "renamed":
+		args = append(args, "-M", "--", fromPath, filePath)
+	case fromPath != "":
+		args = append(args, "-C", "--find-copies-harder", "--", fromPath, filePath)
+	default:
+		args = append(args, "--no-renames", "--", filePath)
+	}
+
+	file := FileInfo{
+		Path:          filePath,
+		Status:        status,
+		StagingStatus: stagingStatus,
+		FromPath:      fromPath,
+	}
+
+	stats, err := diffStats(repoPath, args...)
+	if err != nil {
+		// As with git diff, report the file without counts
+		return file, nil
+	}
+
+	stat := stats[filePath]
+	if !stat.counted && opts.IgnoreWhitespace && status == "modified" {
+		return FileInfo{}, errWhitespaceOnly
+	}
+	file.Additions = stat.additions
+	file.Deletions = stat.deletions
+	file.Binary = stat.binary
+	file.IsSymlink = stat.isSymlink()
+	if from, to, ok := stat.submoduleCommits(); ok {
+		file.markSubmodule(from, to)
+	}
+	return file, nil
+}
+
+// errWhitespaceOnly reports a modified file whose changes are all whitespace,
+// which is hidden when whitespace is ignored
+var errWhitespaceOnly = errors.New("only whitespace changed")
+
+// isHiddenWhitespaceChange reports whether a modified file has no remaining
+// changes once whitespace is ignored. Without patches such files are
+// reported as errWhitespaceOnly instead.
+func isHiddenWhitespaceChange(file FileInfo, opts DiffOptions) bool {
+	return opts.IgnoreWhitespace && !opts.SkipPatches && file.Status == "modified" && file.Patch == ""
+}
+
+// countPatchLines counts added and removed lines in a unified diff
+func countPatchLines(patch string) (int, int) {
 	additions := 0
 	deletions := 0
-	lines := strings.Split(patch, "\n")
-	for _, line := range lines {
+	for _, line := range strings.Split(patch, "\n") {
 		if len(line) == 0 {
 			continue
 		}
@@ -336,24 +1311,77 @@ func (r *Repo) getFileInfoWithGitDiff(repoPath, filePath string, statusCode git.
 			deletions++
 		}
 	}
+	return additions, deletions
+}
 
-	return FileInfo{
-		Path:          filePath,
-		Status:        status,
-		Additions:     additions,
-		Deletions:     deletions,
-		Patch:         patch,
-		StagingStatus: stagingStatus,
-	}, nil
+// filterWhitespaceOnlyHunks drops hunks whose removed and added lines are
+// identical once all whitespace is ignored
+func filterWhitespaceOnlyHunks(patch string) string {
+	lines := strings.SplitAfter(patch, "\n")
+
+	var result strings.Builder
+	var hunk []string
+	flush := func() {
+		if len(hunk) > 0 && !isWhitespaceOnlyHunk(hunk) {
+			for _, line := range hunk {
+				result.WriteString(line)
+			}
+		}
+		hunk = nil
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "@@") {
+			flush()
+			hunk = []string{line}
+			continue
+		}
+		if hunk != nil {
+			hunk = append(hunk, line)
+		} else {
+			result.WriteString(line)
+		}
+	}
+	flush()
+
+	return result.String()
+}
+
+func isWhitespaceOnlyHunk(hunk []string) bool {
+	var removed, added []string
+	for _, line := range hunk[1:] {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, stripWhitespace(line[1:]))
+		case strings.HasPrefix(line, "+"):
+			added = append(added, stripWhitespace(line[1:]))
+		}
+	}
+
+	if len(removed) == 0 && len(added) == 0 {
+		return false
+	}
+	if len(removed) != len(added) {
+		return false
+	}
+	for i := range removed {
+		if removed[i] != added[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func stripWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), "")
 }
 
 func (r *Repo) readWorktreeFile(filePath string) (string, error) {
-	wt, err := r.repo.Worktree()
+	fullPath, err := r.worktreePath(filePath)
 	if err != nil {
 		return "", err
 	}
 
-	fullPath := filepath.Join(wt.Filesystem.Root(), filePath)
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return "", err
@@ -0,0 +1,81 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languagesByName maps file names that have no telling extension to
+// highlight.js language identifiers
+var languagesByName = map[string]string{
+	"makefile":    "makefile",
+	"gnumakefile": "makefile",
+	"gemfile":     "ruby",
+	"rakefile":    "ruby",
+	"podfile":     "ruby",
+	"fastfile":    "ruby",
+}
+
+// languagesByExtension maps lowercased file extensions to highlight.js
+// language identifiers
+var languagesByExtension = map[string]string{
+	".go":       "go",
+	".js":       "javascript",
+	".jsx":      "javascript",
+	".mjs":      "javascript",
+	".cjs":      "javascript",
+	".ts":       "typescript",
+	".tsx":      "typescript",
+	".rs":       "rust",
+	".py":       "python",
+	".rb":       "ruby",
+	".java":     "java",
+	".kt":       "kotlin",
+	".kts":      "kotlin",
+	".swift":    "swift",
+	".m":        "objectivec",
+	".mm":       "objectivec",
+	".c":        "c",
+	".h":        "c",
+	".cc":       "cpp",
+	".cpp":      "cpp",
+	".cxx":      "cpp",
+	".hpp":      "cpp",
+	".cs":       "csharp",
+	".php":      "php",
+	".pl":       "perl",
+	".lua":      "lua",
+	".r":        "r",
+	".sql":      "sql",
+	".sh":       "bash",
+	".bash":     "bash",
+	".zsh":      "bash",
+	".json":     "json",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".toml":     "ini",
+	".ini":      "ini",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".html":     "xml",
+	".htm":      "xml",
+	".xml":      "xml",
+	".svg":      "xml",
+	".plist":    "xml",
+	".css":      "css",
+	".scss":     "scss",
+	".less":     "less",
+	".diff":     "diff",
+	".patch":    "diff",
+	".mk":       "makefile",
+}
+
+// LanguageForPath returns the highlight.js language identifier for a file,
+// based on its name or extension, or "" when it isn't recognized
+func LanguageForPath(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	if language, ok := languagesByName[name]; ok {
+		return language
+	}
+	return languagesByExtension[filepath.Ext(name)]
+}
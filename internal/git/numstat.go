@@ -0,0 +1,120 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// emptyTreeHash is the tree with no entries, which root commits are
+// compared against
+const emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// submoduleMode is the file mode git records for a submodule entry
+const submoduleMode = "160000"
+
+// fileStat is what `git diff --raw --numstat` reports for a file, without
+// its patch
+type fileStat struct {
+	additions int
+	deletions int
+	binary    bool
+	// counted is false for files the numstat output leaves out, such as
+	// modified files whose changes are all whitespace with -w
+	counted bool
+	oldMode string
+	newMode string
+	oldHash string
+	newHash string
+}
+
+// isSymlink reports whether either side of the change is a symlink
+func (s fileStat) isSymlink() bool {
+	return s.oldMode == symlinkMode || s.newMode == symlinkMode
+}
+
+// submoduleCommits returns the commits a submodule pointed at before and
+// after, as changeSubmoduleCommits does for tree changes
+func (s fileStat) submoduleCommits() (from, to string, ok bool) {
+	if s.oldMode == submoduleMode {
+		from, ok = s.oldHash, true
+	}
+	if s.newMode == submoduleMode {
+		to, ok = s.newHash, true
+	}
+	return from, to, ok
+}
+
+// diffStats runs `git diff --raw --numstat -z` with args and returns the
+// files it reports, keyed by their destination path
+func diffStats(repoPath string, args ...string) (map[string]fileStat, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--raw", "--numstat", "-z", "--no-abbrev"}, args...)...)
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count changes: %w", err)
+	}
+	return parseDiffStats(string(output)), nil
+}
+
+// parseDiffStats parses `git diff --raw --numstat -z` output: the raw
+// entries come first, then the numstat ones. Renames and copies are followed
+// by both paths, in both sections.
+func parseDiffStats(output string) map[string]fileStat {
+	stats := map[string]fileStat{}
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+
+		if strings.HasPrefix(record, ":") {
+			// ":oldMode newMode oldHash newHash status", then the path
+			fields := strings.Fields(record[1:])
+			if len(fields) != 5 || i+1 >= len(records) {
+				continue
+			}
+			if fields[4][0] == 'R' || fields[4][0] == 'C' {
+				i++
+			}
+			i++
+			if i >= len(records) {
+				break
+			}
+			stat := stats[records[i]]
+			stat.oldMode, stat.newMode = fields[0], fields[1]
+			stat.oldHash, stat.newHash = fields[2], fields[3]
+			stats[records[i]] = stat
+			continue
+		}
+
+		// "additions\tdeletions\tpath", with an empty path before the two
+		// paths of a rename or copy; binary files count "-"
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		path := fields[2]
+		if path == "" {
+			if i+2 >= len(records) {
+				break
+			}
+			path = records[i+2]
+			i += 2
+		}
+
+		stat := stats[path]
+		stat.counted = true
+		if fields[0] == "-" && fields[1] == "-" {
+			stat.binary = true
+		} else {
+			stat.additions, _ = strconv.Atoi(fields[0])
+			stat.deletions, _ = strconv.Atoi(fields[1])
+		}
+		stats[path] = stat
+	}
+	return stats
+}
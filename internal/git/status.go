@@ -0,0 +1,76 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// statusEntry is a changed file from `git status --porcelain=v2 -z`
+type statusEntry struct {
+	staging  git.StatusCode
+	worktree git.StatusCode
+	path     string
+	// origPath is the source of a rename or copy
+	origPath string
+	// score is the rename or copy score, such as R87
+	score string
+}
+
+// parsePorcelainV2 parses `git status --porcelain=v2 -z` output. Entries are
+// NUL-terminated and paths are never quoted, so any filename is read as is,
+// including ones with spaces, quotes or " -> ". Ignored files are skipped.
+func parsePorcelainV2(output string) []statusEntry {
+	entries := []statusEntry{}
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 2 {
+			continue
+		}
+
+		switch record[0] {
+		case '1':
+			// Changed: "1 XY sub mH mI mW hH hI path"
+			fields := strings.SplitN(record, " ", 9)
+			if len(fields) != 9 || len(fields[1]) != 2 {
+				continue
+			}
+			entries = append(entries, newStatusEntry(fields[1], fields[8]))
+		case '2':
+			// Renamed or copied: "2 XY sub mH mI mW hH hI Xscore path", then the original path
+			fields := strings.SplitN(record, " ", 10)
+			if len(fields) != 10 || len(fields[1]) != 2 || i+1 >= len(records) {
+				continue
+			}
+			entry := newStatusEntry(fields[1], fields[9])
+			entry.score = fields[8]
+			entry.origPath = records[i+1]
+			i++
+			entries = append(entries, entry)
+		case 'u':
+			// Unmerged: "u XY sub m1 m2 m3 mW h1 h2 h3 path"
+			fields := strings.SplitN(record, " ", 11)
+			if len(fields) != 11 || len(fields[1]) != 2 {
+				continue
+			}
+			entries = append(entries, newStatusEntry(fields[1], fields[10]))
+		case '?':
+			entries = append(entries, statusEntry{staging: git.Untracked, worktree: git.Untracked, path: record[2:]})
+		}
+	}
+	return entries
+}
+
+// newStatusEntry converts a porcelain v2 XY code, which marks unchanged
+// sides with ".", to go-git status codes
+func newStatusEntry(xy, path string) statusEntry {
+	return statusEntry{staging: porcelainCode(xy[0]), worktree: porcelainCode(xy[1]), path: path}
+}
+
+func porcelainCode(code byte) git.StatusCode {
+	if code == '.' {
+		return git.Unmodified
+	}
+	return git.StatusCode(code)
+}
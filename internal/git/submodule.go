@@ -0,0 +1,53 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// StatusSubmodule is the status of a submodule whose recorded commit changed
+const StatusSubmodule = "submodule"
+
+// subprojectPrefix starts the lines git diff writes for a submodule's commit
+const subprojectPrefix = "Subproject commit "
+
+// changeSubmoduleCommits returns the commits a submodule entry of a tree
+// change points at before and after; either is empty when the submodule was
+// added or removed. ok is false unless either side is a submodule.
+func changeSubmoduleCommits(change *object.Change) (from, to string, ok bool) {
+	if change.From.Name != "" && change.From.TreeEntry.Mode == filemode.Submodule {
+		from, ok = change.From.TreeEntry.Hash.String(), true
+	}
+	if change.To.Name != "" && change.To.TreeEntry.Mode == filemode.Submodule {
+		to, ok = change.To.TreeEntry.Hash.String(), true
+	}
+	return from, to, ok
+}
+
+// patchSubmoduleCommits reads the commits a submodule points at before and
+// after from git diff output, which shows them as "Subproject commit" lines.
+// A submodule with uncommitted changes has a -dirty suffix on its new commit.
+func patchSubmoduleCommits(patch string) (from, to string, ok bool) {
+	for _, line := range strings.Split(patch, "\n") {
+		if commit, found := strings.CutPrefix(line, "-"+subprojectPrefix); found {
+			from, ok = commit, true
+		} else if commit, found := strings.CutPrefix(line, "+"+subprojectPrefix); found {
+			to, ok = commit, true
+		}
+	}
+	return from, to, ok
+}
+
+// markSubmodule replaces the one-line "Subproject commit" patch of a
+// submodule with the commits it moved between
+func (f *FileInfo) markSubmodule(from, to string) {
+	f.Status = StatusSubmodule
+	f.SubmoduleFrom = from
+	f.SubmoduleTo = to
+	f.Patch = ""
+	f.Additions = 0
+	f.Deletions = 0
+	f.Binary = false
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// symlinkMode is the file mode git records for a symbolic link, whose
+// content is the path it points to
+const symlinkMode = "120000"
+
+// isSymlinkChange reports whether either side of a tree change is a symlink
+func isSymlinkChange(change *object.Change) bool {
+	return (change.From.Name != "" && change.From.TreeEntry.Mode == filemode.Symlink) ||
+		(change.To.Name != "" && change.To.TreeEntry.Mode == filemode.Symlink)
+}
+
+// isSymlinkPatch reports whether git diff output describes a symlink, from
+// the mode on its index, new file, deleted file or mode change lines
+func isSymlinkPatch(patch string) bool {
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		if strings.HasSuffix(line, " "+symlinkMode) && (strings.HasPrefix(line, "index ") || strings.Contains(line, "mode ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// worktreeSymlink returns the target of a symlink in the worktree without
+// following it; ok is false if the path isn't a symlink
+func (r *Repo) worktreeSymlink(filePath string) (target string, ok bool) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return "", false
+	}
+
+	fullPath := filepath.Join(wt.Filesystem.Root(), filePath)
+	info, err := os.Lstat(fullPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return "", false
+	}
+
+	target, err = os.Readlink(fullPath)
+	return target, err == nil
+}
+
+// worktreePath returns the path to read a worktree file from. It refuses
+// paths that leave the repository, whether literally or by following a
+// symlink, so a link to a file such as /etc/passwd is never read through.
+func (r *Repo) worktreePath(filePath string) (string, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	root := wt.Filesystem.Root()
+	fullPath := filepath.Join(root, filePath)
+	if !withinDir(root, fullPath) {
+		return "", fmt.Errorf("%s is outside the repository", filePath)
+	}
+
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the repository path: %w", err)
+	}
+	if !withinDir(resolvedRoot, resolved) {
+		return "", fmt.Errorf("%s links outside the repository", filePath)
+	}
+
+	return resolved, nil
+}
+
+// withinDir reports whether path is dir or inside it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,46 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WebURL links to filePath at ref on the web host of remoteURL, anchored at
+// line when it is positive. GitHub, GitLab and Bitbucket are recognized by
+// their host name, which covers self-hosted instances such as
+// github.example.com or gitlab.example.com. Other hosts yield empty string.
+func WebURL(remoteURL, ref, filePath string, line int) string {
+	parsed, err := url.Parse(NormalizeRemoteURL(remoteURL))
+	if err != nil || parsed.Scheme != "https" || ref == "" {
+		return ""
+	}
+
+	var blob, anchor string
+	host := strings.ToLower(parsed.Hostname())
+	switch {
+	case strings.Contains(host, "gitlab"):
+		blob, anchor = "-/blob", "#L"
+	case strings.Contains(host, "bitbucket"):
+		blob, anchor = "src", "#lines-"
+	case strings.Contains(host, "github"):
+		blob, anchor = "blob", "#L"
+	default:
+		return ""
+	}
+
+	link := fmt.Sprintf("https://%s%s/%s/%s/%s", parsed.Host, strings.TrimSuffix(parsed.Path, "/"), blob, escapePath(ref), escapePath(filePath))
+	if line > 0 {
+		link += fmt.Sprintf("%s%d", anchor, line)
+	}
+	return link
+}
+
+// escapePath escapes each segment of a slash-separated path for use in a URL
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
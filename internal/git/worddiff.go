@@ -0,0 +1,194 @@
+package git
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxWordDiffTokens skips word diffs of lines with more tokens than this,
+// which keeps the token comparison cheap on minified or generated lines
+const maxWordDiffTokens = 500
+
+// hunkRanges captures the starting line of both sides of a hunk
+var hunkRanges = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// WordRange is a byte range [Start, End) of a line's content, excluding the
+// leading +/- of the patch line
+type WordRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// WordDiff pairs a removed line with the added line that replaced it and
+// marks the words that differ between them
+type WordDiff struct {
+	OldLine int         `json:"old_line"`
+	NewLine int         `json:"new_line"`
+	Removed []WordRange `json:"removed,omitempty"`
+	Added   []WordRange `json:"added,omitempty"`
+}
+
+type patchLine struct {
+	number int
+	text   string
+}
+
+// WordDiffs computes word-level changes for the modified lines of a unified
+// diff. Within each run of removed lines followed by added lines, the nth
+// removed line is paired with the nth added line; unpaired lines are left
+// out, as they changed entirely.
+func WordDiffs(patch string) []WordDiff {
+	var diffs []WordDiff
+	var removed, added []patchLine
+	flush := func() {
+		for i := 0; i < len(removed) && i < len(added); i++ {
+			if diff, ok := wordDiff(removed[i], added[i]); ok {
+				diffs = append(diffs, diff)
+			}
+		}
+		removed, added = nil, nil
+	}
+
+	oldLine, newLine := 0, 0
+	inHunk := false
+	for _, line := range strings.Split(patch, "\n") {
+		if match := hunkRanges.FindStringSubmatch(line); match != nil {
+			flush()
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[2])
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "-"):
+			if len(added) > 0 {
+				flush()
+			}
+			removed = append(removed, patchLine{number: oldLine, text: line[1:]})
+			oldLine++
+		case strings.HasPrefix(line, "+"):
+			added = append(added, patchLine{number: newLine, text: line[1:]})
+			newLine++
+		case strings.HasPrefix(line, " "):
+			flush()
+			oldLine++
+			newLine++
+		case strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file" belongs to the previous line
+		default:
+			flush()
+			inHunk = false
+		}
+	}
+	flush()
+
+	return diffs
+}
+
+// wordDiff compares two lines token by token, reporting false when they are
+// identical or too long to compare
+func wordDiff(oldLine, newLine patchLine) (WordDiff, bool) {
+	oldTokens := tokenizeWords(oldLine.text)
+	newTokens := tokenizeWords(newLine.text)
+	if len(oldTokens) > maxWordDiffTokens || len(newTokens) > maxWordDiffTokens {
+		return WordDiff{}, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldTokens[i:] and newTokens[j:]
+	lcs := make([][]int, len(oldTokens)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newTokens)+1)
+	}
+	for i := len(oldTokens) - 1; i >= 0; i-- {
+		for j := len(newTokens) - 1; j >= 0; j-- {
+			if oldTokens[i].text == newTokens[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := WordDiff{OldLine: oldLine.number, NewLine: newLine.number}
+	i, j := 0, 0
+	for i < len(oldTokens) || j < len(newTokens) {
+		switch {
+		case i < len(oldTokens) && j < len(newTokens) && oldTokens[i].text == newTokens[j].text:
+			i++
+			j++
+		case j < len(newTokens) && (i == len(oldTokens) || lcs[i][j+1] >= lcs[i+1][j]):
+			diff.Added = appendWordRange(diff.Added, newTokens[j])
+			j++
+		default:
+			diff.Removed = appendWordRange(diff.Removed, oldTokens[i])
+			i++
+		}
+	}
+
+	if len(diff.Removed) == 0 && len(diff.Added) == 0 {
+		return WordDiff{}, false
+	}
+	return diff, true
+}
+
+// appendWordRange adds a token to ranges, extending the last range when the
+// token directly follows it
+func appendWordRange(ranges []WordRange, token wordToken) []WordRange {
+	if n := len(ranges); n > 0 && ranges[n-1].End == token.start {
+		ranges[n-1].End = token.start + len(token.text)
+		return ranges
+	}
+	return append(ranges, WordRange{Start: token.start, End: token.start + len(token.text)})
+}
+
+type wordToken struct {
+	text  string
+	start int
+}
+
+// tokenizeWords splits a line into runs of letters, digits and underscores,
+// runs of whitespace, and single other characters
+func tokenizeWords(line string) []wordToken {
+	var tokens []wordToken
+	for start := 0; start < len(line); {
+		r, size := utf8.DecodeRuneInString(line[start:])
+		end := start + size
+		if class := runeClass(r); class != classOther {
+			for end < len(line) {
+				next, nextSize := utf8.DecodeRuneInString(line[end:])
+				if runeClass(next) != class {
+					break
+				}
+				end += nextSize
+			}
+		}
+		tokens = append(tokens, wordToken{text: line[start:end], start: start})
+		start = end
+	}
+	return tokens
+}
+
+const (
+	classOther = iota
+	classWord
+	classSpace
+)
+
+func runeClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	default:
+		return classOther
+	}
+}
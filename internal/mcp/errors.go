@@ -0,0 +1,42 @@
+package mcp
+
+import "errors"
+
+// Errors returned by the tool functions. Failures wrap one of these so
+// callers can tell them apart with errors.Is; anything else is internal.
+var (
+	ErrInvalidParams      = errors.New("invalid params")
+	ErrNotRepository      = errors.New("not a git repository")
+	ErrCommentNotFound    = errors.New("comment not found")
+	ErrNoteNotFound       = errors.New("note not found")
+	ErrCommentNotResolved = errors.New("comment is not resolved")
+	ErrNoteNotDismissed   = errors.New("note is not dismissed")
+)
+
+// Machine-readable error codes reported in failed tool results
+const (
+	ErrorCodeInvalidParams = "invalid_params"
+	ErrorCodeNotFound      = "not_found"
+	ErrorCodeConflict      = "conflict"
+	ErrorCodeInternal      = "internal"
+)
+
+// ToolError is the structured content of a failed tool call
+type ToolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorCode classifies an error returned by a tool function
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidParams), errors.Is(err, ErrNotRepository):
+		return ErrorCodeInvalidParams
+	case errors.Is(err, ErrCommentNotFound), errors.Is(err, ErrNoteNotFound):
+		return ErrorCodeNotFound
+	case errors.Is(err, ErrCommentNotResolved), errors.Is(err, ErrNoteNotDismissed):
+		return ErrorCodeConflict
+	default:
+		return ErrorCodeInternal
+	}
+}
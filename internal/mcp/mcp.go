@@ -2,9 +2,18 @@ package mcp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
-
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuist/guck/internal/author"
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/git"
 	"github.com/tuist/guck/internal/state"
 )
 
@@ -14,14 +23,71 @@ type ListCommentsParams struct {
 	Commit   *string `json:"commit,omitempty"`
 	FilePath *string `json:"file_path,omitempty"`
 	Resolved *bool   `json:"resolved,omitempty"`
+	// AuthorKind keeps comments whose author is namespaced with this kind,
+	// "human" or "agent"
+	AuthorKind *string `json:"author_kind,omitempty"`
+	// Search keeps comments whose text contains this string, ignoring case
+	Search *string `json:"search,omitempty"`
+	// Since and Until keep comments made in this time range, each an RFC3339
+	// time or a duration before now such as 24h or 7d
+	Since *string `json:"since,omitempty"`
+	Until *string `json:"until,omitempty"`
+	// FollowRenames includes comments recorded under the file's previous paths
+	FollowRenames bool `json:"follow_renames,omitempty"`
+	// ContextLines embeds this many lines of code around each comment's line
+	ContextLines int `json:"context_lines,omitempty"`
+	// Sort orders the results: newest (default), oldest or file
+	Sort string `json:"sort,omitempty"`
+	// Limit caps the number of comments returned after Offset; 0 means no limit
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
 }
 
+// Orders accepted by the sort param of list_comments and list_notes
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortFile   = "file"
+)
+
+// MaxContextLines caps the code context embedded on each side of a comment
+const MaxContextLines = 20
+
 type ResolveCommentParams struct {
 	RepoPath   string `json:"repo_path"`
 	CommentID  string `json:"comment_id"`
 	ResolvedBy string `json:"resolved_by"`
 }
 
+type UnresolveCommentParams struct {
+	RepoPath  string `json:"repo_path"`
+	CommentID string `json:"comment_id"`
+}
+
+type AddCommentParams struct {
+	RepoPath   string            `json:"repo_path"`
+	Branch     string            `json:"branch"`
+	Commit     string            `json:"commit"`
+	FilePath   string            `json:"file_path"`
+	LineNumber *int              `json:"line_number,omitempty"`
+	EndLine    *int              `json:"end_line,omitempty"`
+	Side       string            `json:"side,omitempty"`
+	Text       string            `json:"text"`
+	Suggestion string            `json:"suggestion,omitempty"`
+	Author     string            `json:"author"`
+	Type       string            `json:"type,omitempty"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	// ResolveExisting resolves the author's unresolved comments on the same
+	// file and line, superseding them with the new comment
+	ResolveExisting bool `json:"resolve_existing,omitempty"`
+}
+
+type DeleteCommentParams struct {
+	RepoPath  string `json:"repo_path"`
+	CommentID string `json:"comment_id"`
+}
+
 type AddNoteParams struct {
 	RepoPath   string            `json:"repo_path"`
 	Branch     string            `json:"branch"`
@@ -41,6 +107,89 @@ type ListNotesParams struct {
 	FilePath  *string `json:"file_path,omitempty"`
 	Dismissed *bool   `json:"dismissed,omitempty"`
 	Author    *string `json:"author,omitempty"`
+	// AuthorKind keeps notes whose author is namespaced with this kind,
+	// "human" or "agent"
+	AuthorKind *string `json:"author_kind,omitempty"`
+	// Search keeps notes whose text or metadata values contain this string,
+	// ignoring case
+	Search *string `json:"search,omitempty"`
+	// Metadata keeps notes whose metadata has every one of these pairs
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Since and Until keep notes made in this time range, each an RFC3339
+	// time or a duration before now such as 24h or 7d
+	Since *string `json:"since,omitempty"`
+	Until *string `json:"until,omitempty"`
+	// FollowRenames includes notes recorded under the file's previous paths
+	FollowRenames bool `json:"follow_renames,omitempty"`
+	// Sort orders the results: newest (default), oldest or file
+	Sort string `json:"sort,omitempty"`
+	// Limit caps the number of notes returned after Offset; 0 means no limit
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// NotesSummaryParams selects the notes NotesSummary counts
+type NotesSummaryParams struct {
+	RepoPath string  `json:"repo_path"`
+	Branch   *string `json:"branch,omitempty"`
+}
+
+// NoteCounts counts notes, split by whether they have been dismissed
+type NoteCounts struct {
+	Total     int `json:"total"`
+	Active    int `json:"active"`
+	Dismissed int `json:"dismissed"`
+}
+
+// add counts note
+func (c *NoteCounts) add(n *state.Note) {
+	c.Total++
+	if n.Dismissed {
+		c.Dismissed++
+	} else {
+		c.Active++
+	}
+}
+
+type ListViewedParams struct {
+	RepoPath string  `json:"repo_path"`
+	Branch   *string `json:"branch,omitempty"`
+	Commit   *string `json:"commit,omitempty"`
+}
+
+// MarkViewedParams identifies a file to mark or unmark as viewed. Branch and
+// commit default to the repository's current HEAD.
+type MarkViewedParams struct {
+	RepoPath string  `json:"repo_path"`
+	Branch   *string `json:"branch,omitempty"`
+	Commit   *string `json:"commit,omitempty"`
+	FilePath string  `json:"file_path"`
+}
+
+type GetDiffParams struct {
+	RepoPath string `json:"repo_path"`
+	// Base is the branch to compare against; defaults to the configured base branch
+	Base *string `json:"base,omitempty"`
+	// Uncommitted returns staged and unstaged changes instead of committed ones
+	Uncommitted      bool  `json:"uncommitted,omitempty"`
+	IgnoreWhitespace *bool `json:"ignore_whitespace,omitempty"`
+}
+
+// DiffFileResult is a changed file returned by get_diff. Binary files carry a
+// size and MIME type descriptor instead of a patch.
+type DiffFileResult struct {
+	Path          string            `json:"path"`
+	Status        string            `json:"status"`
+	Additions     int               `json:"additions"`
+	Deletions     int               `json:"deletions"`
+	Patch         string            `json:"patch,omitempty"`
+	StagingStatus git.StagingStatus `json:"staging_status,omitempty"`
+	FromPath      string            `json:"from_path,omitempty"`
+	Similarity    int               `json:"similarity,omitempty"`
+	IsBinary      bool              `json:"is_binary,omitempty"`
+	OldSize       *int64            `json:"old_size,omitempty"`
+	NewSize       *int64            `json:"new_size,omitempty"`
+	MimeType      string            `json:"mime_type,omitempty"`
 }
 
 type DismissNoteParams struct {
@@ -49,17 +198,43 @@ type DismissNoteParams struct {
 	DismissedBy string `json:"dismissed_by"`
 }
 
+type RestoreNoteParams struct {
+	RepoPath string `json:"repo_path"`
+	NoteID   string `json:"note_id"`
+}
+
+type DeleteNoteParams struct {
+	RepoPath string `json:"repo_path"`
+	NoteID   string `json:"note_id"`
+}
+
 type CommentResult struct {
-	ID         string `json:"id"`
-	FilePath   string `json:"file_path"`
-	LineNumber *int   `json:"line_number,omitempty"`
-	Text       string `json:"text"`
-	Timestamp  int64  `json:"timestamp"`
-	Branch     string `json:"branch"`
-	Commit     string `json:"commit"`
-	Resolved   bool   `json:"resolved"`
-	ResolvedBy string `json:"resolved_by,omitempty"`
-	ResolvedAt int64  `json:"resolved_at,omitempty"`
+	ID         string            `json:"id"`
+	FilePath   string            `json:"file_path"`
+	LineNumber *int              `json:"line_number,omitempty"`
+	EndLine    *int              `json:"end_line,omitempty"`
+	Side       string            `json:"side,omitempty"`
+	Text       string            `json:"text"`
+	Suggestion string            `json:"suggestion,omitempty"`
+	Timestamp  int64             `json:"timestamp"`
+	Branch     string            `json:"branch"`
+	Commit     string            `json:"commit"`
+	Resolved   bool              `json:"resolved"`
+	ResolvedBy string            `json:"resolved_by,omitempty"`
+	ResolvedAt int64             `json:"resolved_at,omitempty"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Author     string            `json:"author,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Mentions   []string          `json:"mentions,omitempty"`
+	Context    *CodeContext      `json:"context,omitempty"`
+}
+
+// CodeContext is a snippet of the file around a comment's line, read from the
+// comment's commit
+type CodeContext struct {
+	StartLine int      `json:"start_line"`
+	Lines     []string `json:"lines"`
 }
 
 type NoteResult struct {
@@ -78,6 +253,15 @@ type NoteResult struct {
 	DismissedAt int64             `json:"dismissed_at,omitempty"`
 }
 
+// ViewedFileResult is a file a reviewer has marked as viewed. Uncommitted
+// changes are recorded under the commit "__uncommitted__" with the staging
+// status appended to the path (e.g. "main.go:staged").
+type ViewedFileResult struct {
+	FilePath string `json:"file_path"`
+	Branch   string `json:"branch"`
+	Commit   string `json:"commit"`
+}
+
 func ListTools() map[string]interface{} {
 	tools := []map[string]interface{}{
 		{
@@ -102,10 +286,48 @@ func ListTools() map[string]interface{} {
 						"type":        "string",
 						"description": "Optional: Filter by file path",
 					},
+					"search": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return comments whose text contains this string (case-insensitive)",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return comments made at or after this time: RFC3339 (2024-05-01T09:00:00Z) or a duration before now (24h, 7d)",
+					},
+					"until": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return comments made at or before this time: RFC3339 or a duration before now",
+					},
 					"resolved": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Optional: Filter by resolution status (true=resolved, false=unresolved)",
 					},
+					"author_kind": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{author.KindHuman, author.KindAgent},
+						"description": "Optional: Only return comments whose author is namespaced as human:<name> or agent:<name>",
+					},
+					"follow_renames": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: With file_path, also include comments recorded under the file's previous paths",
+					},
+					"context_lines": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Include this many lines of code around each comment's line, read from the comment's commit (max 20)",
+					},
+					"sort": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{SortNewest, SortOldest, SortFile},
+						"description": "Optional: Order of the results: newest first (default), oldest first, or by file path and line",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of comments to return (0 or omitted returns all)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of matching comments to skip; the result's total counts every match",
+					},
 				},
 				"required": []string{"repo_path"},
 			},
@@ -132,6 +354,109 @@ func ListTools() map[string]interface{} {
 				"required": []string{"repo_path", "comment_id", "resolved_by"},
 			},
 		},
+		{
+			"name":        "unresolve_comment",
+			"description": "Reopen a resolved code review comment, clearing who resolved it and when. Fails if the comment is not resolved.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"comment_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The ID of the comment to reopen",
+					},
+				},
+				"required": []string{"repo_path", "comment_id"},
+			},
+		},
+		{
+			"name":        "add_comment",
+			"description": "Add a code review comment to a file or line, attributed to its author. Set parent_id to reply to an existing comment.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Branch name where the comment applies",
+					},
+					"commit": map[string]interface{}{
+						"type":        "string",
+						"description": "Commit hash where the comment applies",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "File path relative to repository root",
+					},
+					"line_number": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Line number for inline comments",
+					},
+					"end_line": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Last line of a multi-line comment starting at line_number",
+					},
+					"side": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{state.SideOld, state.SideNew},
+						"description": "Optional: Side of the diff the line numbers refer to: 'old' for the file before the change (e.g., a deleted line), 'new' for the file after it. Defaults to 'new'",
+					},
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "The comment content (markdown supported). Optional when suggestion is provided",
+					},
+					"suggestion": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Replacement content for the commented line(s), rendered as a suggested change",
+					},
+					"author": map[string]interface{}{
+						"type":        "string",
+						"description": "Author identifier (e.g., 'claude', 'human:username')",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Comment type (e.g., 'issue', 'question', 'suggestion')",
+					},
+					"parent_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: ID of the comment this is a reply to",
+					},
+					"metadata": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: Additional metadata as key-value pairs",
+					},
+					"resolve_existing": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Resolve this author's unresolved comments on the same file and line, superseding them with the new comment",
+					},
+				},
+				"required": []string{"repo_path", "branch", "commit", "file_path", "author"},
+			},
+		},
+		{
+			"name":        "delete_comment",
+			"description": "Permanently delete a code review comment and its replies. Unlike resolving, this removes them from the stored state.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"comment_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The ID of the comment to delete",
+					},
+				},
+				"required": []string{"repo_path", "comment_id"},
+			},
+		},
 		{
 			"name":        "add_note",
 			"description": "Add an AI agent note to explain code decisions, rationale, or suggestions. Notes are distinct from review comments and represent AI-generated explanations.",
@@ -204,10 +529,66 @@ func ListTools() map[string]interface{} {
 						"type":        "boolean",
 						"description": "Optional: Filter by dismissal status (true=dismissed, false=active)",
 					},
+					"search": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return notes whose text or metadata values contain this string (case-insensitive)",
+					},
+					"metadata": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: Only return notes whose metadata contains all of these key-value pairs",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return notes made at or after this time: RFC3339 (2024-05-01T09:00:00Z) or a duration before now (24h, 7d)",
+					},
+					"until": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only return notes made at or before this time: RFC3339 or a duration before now",
+					},
 					"author": map[string]interface{}{
 						"type":        "string",
 						"description": "Optional: Filter by author",
 					},
+					"author_kind": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{author.KindHuman, author.KindAgent},
+						"description": "Optional: Only return notes whose author is namespaced as human:<name> or agent:<name>",
+					},
+					"follow_renames": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: With file_path, also include notes recorded under the file's previous paths",
+					},
+					"sort": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{SortNewest, SortOldest, SortFile},
+						"description": "Optional: Order of the results: newest first (default), oldest first, or by file path and line",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of notes to return (0 or omitted returns all)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of matching notes to skip; the result's total counts every match",
+					},
+				},
+				"required": []string{"repo_path"},
+			},
+		},
+		{
+			"name":        "notes_summary",
+			"description": "Count AI agent notes by author and by type, each split into active and dismissed, without listing them.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only count notes on this branch",
+					},
 				},
 				"required": []string{"repo_path"},
 			},
@@ -234,381 +615,1614 @@ func ListTools() map[string]interface{} {
 				"required": []string{"repo_path", "note_id", "dismissed_by"},
 			},
 		},
-	}
-
-	return map[string]interface{}{
-		"tools": tools,
-	}
-}
-
-func ListComments(paramsRaw json.RawMessage) (interface{}, error) {
-	stateMgr, err := state.NewManager()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load state: %w", err)
-	}
-	return ListCommentsWithManager(paramsRaw, stateMgr)
-}
-
-func ListCommentsWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
-	var params ListCommentsParams
-	if err := json.Unmarshal(paramsRaw, &params); err != nil {
-		return nil, fmt.Errorf("invalid params: %w", err)
-	}
-
-	if params.RepoPath == "" {
-		return nil, fmt.Errorf("repo_path is required")
-	}
-
-	repoPath := params.RepoPath
-
-	// Make path absolute
-	absPath, err := filepath.Abs(repoPath)
-	if err != nil {
-		return nil, fmt.Errorf("invalid repo_path: %w", err)
-	}
-
-	var comments []*state.Comment
-
-	// If branch and commit are specified, get comments for that specific state
-	if params.Branch != nil && params.Commit != nil {
-		comments = stateMgr.GetComments(absPath, *params.Branch, *params.Commit, params.FilePath)
-	} else {
-		// Otherwise get all comments for the repo
-		comments = stateMgr.GetAllComments(absPath)
-	}
-
-	// Filter by resolution status if specified
-	if params.Resolved != nil {
-		filtered := []*state.Comment{}
-		for _, c := range comments {
-			if c.Resolved == *params.Resolved {
-				filtered = append(filtered, c)
-			}
-		}
-		comments = filtered
-	}
-
-	// Filter by file path if specified (and not already filtered by GetComments)
-	if params.FilePath != nil && (params.Branch == nil || params.Commit == nil) {
-		filtered := []*state.Comment{}
-		for _, c := range comments {
-			if c.FilePath == *params.FilePath {
-				filtered = append(filtered, c)
-			}
-		}
-		comments = filtered
-	}
-
-	// Convert to result format
-	results := make([]CommentResult, len(comments))
-	for i, c := range comments {
-		results[i] = CommentResult{
-			ID:         c.ID,
-			FilePath:   c.FilePath,
-			LineNumber: c.LineNumber,
-			Text:       c.Text,
-			Timestamp:  c.Timestamp,
-			Branch:     c.Branch,
-			Commit:     c.Commit,
-			Resolved:   c.Resolved,
-			ResolvedBy: c.ResolvedBy,
-			ResolvedAt: c.ResolvedAt,
+		{
+			"name":        "restore_note",
+			"description": "Restore a dismissed AI agent note to the active list, clearing who dismissed it and when. Fails if the note is not dismissed.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"note_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The ID of the note to restore",
+					},
+				},
+				"required": []string{"repo_path", "note_id"},
+			},
+		},
+		{
+			"name":        "delete_note",
+			"description": "Permanently delete an AI agent note. Unlike dismissing, this removes the note from the stored state.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"note_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The ID of the note to delete",
+					},
+				},
+				"required": []string{"repo_path", "note_id"},
+			},
+		},
+		{
+			"name":        "list_viewed",
+			"description": "List the files a reviewer has marked as viewed, so unreviewed files can be prioritized. Optionally filter by branch and commit.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter by branch name",
+					},
+					"commit": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter by commit hash",
+					},
+				},
+				"required": []string{"repo_path"},
+			},
+		},
+		{
+			"name":        "get_diff",
+			"description": "Get the changes under review: files with their status, unified diff patch and line counts, plus the base and head commits. Defaults to committed changes since the merge base with the base branch.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"base": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Branch or revision such as HEAD~3 to compare against (defaults to the configured base branch)",
+					},
+					"uncommitted": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Return staged and unstaged changes instead of committed ones",
+					},
+					"ignore_whitespace": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Hide whitespace-only changes (defaults to the ignore_whitespace config)",
+					},
+				},
+				"required": []string{"repo_path"},
+			},
+		},
+		{
+			"name":        "mark_viewed",
+			"description": "Mark a file as viewed once it has been reviewed, the same as ticking it in the web UI.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the file relative to the repository root",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Branch name (defaults to the current branch)",
+					},
+					"commit": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Commit hash (defaults to the current HEAD commit)",
+					},
+				},
+				"required": []string{"repo_path", "file_path"},
+			},
+		},
+		{
+			"name":        "unmark_viewed",
+			"description": "Mark a previously viewed file as not viewed.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"repo_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path to the git repository",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the file relative to the repository root",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Branch name (defaults to the current branch)",
+					},
+					"commit": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Commit hash (defaults to the current HEAD commit)",
+					},
+				},
+				"required": []string{"repo_path", "file_path"},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"tools": tools,
+	}
+}
+
+// newStateManager loads the review state for the params' repo_path, which
+// may be stored inside the repo. Invalid params are left for the tool to
+// report.
+func newStateManager(paramsRaw json.RawMessage) (*state.Manager, error) {
+	var params struct {
+		RepoPath string `json:"repo_path"`
+	}
+	if err := json.Unmarshal(paramsRaw, &params); err != nil || params.RepoPath == "" {
+		return state.NewManager()
+	}
+	return state.NewManagerForRepo(params.RepoPath)
+}
+
+// repoAbsPath makes repo_path absolute and checks that it is inside a git
+// repository, so a mistyped path fails instead of matching no state
+func repoAbsPath(repoPath string) (string, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid repo_path: %v", ErrInvalidParams, err)
+	}
+	if !git.IsRepo(absPath) {
+		return "", fmt.Errorf("%w: %s", ErrNotRepository, absPath)
+	}
+	return absPath, nil
+}
+
+func ListComments(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return ListCommentsWithManager(paramsRaw, stateMgr)
+}
+
+func ListCommentsWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params ListCommentsParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.Limit < 0 || params.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must be non-negative", ErrInvalidParams)
+	}
+
+	if err := validateSort(params.Sort); err != nil {
+		return nil, err
+	}
+
+	createdIn, err := parseTimeRange(params.Since, params.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.AuthorKind != nil {
+		if err := author.ValidateKind(*params.AuthorKind); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+
+	repoPath := params.RepoPath
+
+	absPath, err := repoAbsPath(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths, err := resolveFilePaths(absPath, params.FilePath, params.FollowRenames)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []*state.Comment
+
+	// If branch and commit are specified, get comments for that specific state
+	if params.Branch != nil && params.Commit != nil {
+		// Renamed files are matched against every historical path below
+		filePath := params.FilePath
+		if params.FollowRenames {
+			filePath = nil
+		}
+		comments = stateMgr.GetComments(absPath, *params.Branch, *params.Commit, filePath)
+	} else {
+		// Otherwise get all comments for the repo
+		comments = stateMgr.GetAllComments(absPath)
+	}
+
+	// Filter by resolution status if specified
+	if params.Resolved != nil {
+		filtered := []*state.Comment{}
+		for _, c := range comments {
+			if c.Resolved == *params.Resolved {
+				filtered = append(filtered, c)
+			}
+		}
+		comments = filtered
+	}
+
+	// Filter by author kind if specified
+	if params.AuthorKind != nil {
+		filtered := []*state.Comment{}
+		for _, c := range comments {
+			if author.IsKind(c.Author, *params.AuthorKind) {
+				filtered = append(filtered, c)
+			}
+		}
+		comments = filtered
+	}
+
+	// Filter by file path if specified (and not already filtered by GetComments)
+	if params.FilePath != nil && (params.Branch == nil || params.Commit == nil || params.FollowRenames) {
+		filtered := []*state.Comment{}
+		for _, c := range comments {
+			if filePaths[c.FilePath] {
+				filtered = append(filtered, c)
+			}
+		}
+		comments = filtered
+	}
+
+	// Filter by text if specified
+	if params.Search != nil && *params.Search != "" {
+		filtered := []*state.Comment{}
+		for _, c := range comments {
+			if containsFold(c.Text, *params.Search) {
+				filtered = append(filtered, c)
+			}
+		}
+		comments = filtered
+	}
+
+	// Filter by when the comment was made if specified
+	if createdIn.bounded {
+		filtered := []*state.Comment{}
+		for _, c := range comments {
+			if createdIn.contains(c.Timestamp) {
+				filtered = append(filtered, c)
+			}
+		}
+		comments = filtered
+	}
+
+	comments = append([]*state.Comment(nil), comments...)
+	sortItems(comments, params.Sort, func(c *state.Comment) sortKey {
+		return sortKey{c.Timestamp, c.ID, c.FilePath, c.LineNumber}
+	})
+	total := len(comments)
+	comments = paginate(comments, params.Offset, params.Limit)
+
+	// Convert to result format
+	results := make([]CommentResult, len(comments))
+	for i, c := range comments {
+		results[i] = CommentResult{
+			ID:         c.ID,
+			FilePath:   c.FilePath,
+			LineNumber: c.LineNumber,
+			EndLine:    c.EndLine,
+			Side:       c.Side,
+			Text:       c.Text,
+			Suggestion: c.Suggestion,
+			Timestamp:  c.Timestamp,
+			Branch:     c.Branch,
+			Commit:     c.Commit,
+			Resolved:   c.Resolved,
+			ResolvedBy: c.ResolvedBy,
+			ResolvedAt: c.ResolvedAt,
+			ParentID:   c.ParentID,
+			Author:     c.Author,
+			Type:       c.Type,
+			Metadata:   c.Metadata,
+			Mentions:   c.Mentions,
+		}
+	}
+
+	if params.ContextLines > 0 {
+		addCommentContext(absPath, results, min(params.ContextLines, MaxContextLines))
+	}
+
+	return map[string]interface{}{
+		"comments":  results,
+		"count":     len(results),
+		"total":     total,
+		"repo_path": absPath,
+	}, nil
+}
+
+// StaleCommentsWithManager lists the unresolved comments on branch that were
+// made at commits other than headCommit, the ones `guck comments stale`
+// reports and MigrateComments would move onto HEAD
+func StaleCommentsWithManager(repoPath, branch, headCommit string, stateMgr *state.Manager) ([]CommentResult, error) {
+	resolved := false
+	paramsJSON, err := json.Marshal(ListCommentsParams{
+		RepoPath: repoPath,
+		Branch:   &branch,
+		Resolved: &resolved,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ListCommentsWithManager(paramsJSON, stateMgr)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []CommentResult{}
+	for _, comment := range result.(map[string]interface{})["comments"].([]CommentResult) {
+		if comment.Branch == branch && comment.Commit != headCommit {
+			stale = append(stale, comment)
+		}
+	}
+	return stale, nil
+}
+
+// validateSort rejects sort orders other than the Sort* constants; empty
+// selects the default
+func validateSort(order string) error {
+	switch order {
+	case "", SortNewest, SortOldest, SortFile:
+		return nil
+	}
+	return fmt.Errorf("%w: invalid sort %q: must be %s, %s or %s", ErrInvalidParams, order, SortNewest, SortOldest, SortFile)
+}
+
+// sortKey holds the fields comments and notes are ordered by
+type sortKey struct {
+	timestamp  int64
+	id         string
+	filePath   string
+	lineNumber *int
+}
+
+// sortItems orders items in place so listings, and the pages cut from them,
+// are stable across calls. SortFile orders by path, then line with file-level
+// items first, then oldest first.
+func sortItems[T any](items []T, order string, key func(T) sortKey) {
+	chronological := func(a, b sortKey) bool {
+		if a.timestamp != b.timestamp {
+			return a.timestamp < b.timestamp
+		}
+		return a.id < b.id
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := key(items[i]), key(items[j])
+		switch order {
+		case SortOldest:
+			return chronological(a, b)
+		case SortFile:
+			if a.filePath != b.filePath {
+				return a.filePath < b.filePath
+			}
+			if (a.lineNumber == nil) != (b.lineNumber == nil) {
+				return a.lineNumber == nil
+			}
+			if a.lineNumber != nil && *a.lineNumber != *b.lineNumber {
+				return *a.lineNumber < *b.lineNumber
+			}
+			return chronological(a, b)
+		default:
+			return chronological(b, a)
+		}
+	})
+}
+
+// paginate returns up to limit items starting at offset; a limit of 0 returns
+// everything after offset
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// containsFold reports whether s contains substr, ignoring case
+// hasMetadata reports whether metadata contains every pair of want
+func hasMetadata(metadata, want map[string]string) bool {
+	for key, value := range want {
+		if got, ok := metadata[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTimeBound reads a since or until param as an RFC3339 time or as a
+// duration before now, such as 24h or 7d, returning a Unix timestamp
+func parseTimeBound(name, value string, now time.Time) (int64, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n).Unix(), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d).Unix(), nil
+	}
+
+	return 0, fmt.Errorf("%w: invalid %s %q: must be an RFC3339 time or a duration such as 24h or 7d", ErrInvalidParams, name, value)
+}
+
+// timeRange is the inclusive range of Unix timestamps a list keeps
+type timeRange struct {
+	since, until int64
+	bounded      bool
+}
+
+// parseTimeRange reads the since and until params; either may be nil
+func parseTimeRange(since, until *string) (timeRange, error) {
+	r := timeRange{until: math.MaxInt64}
+	now := time.Now()
+	var err error
+
+	if since != nil && *since != "" {
+		if r.since, err = parseTimeBound("since", *since, now); err != nil {
+			return r, err
+		}
+		r.bounded = true
+	}
+	if until != nil && *until != "" {
+		if r.until, err = parseTimeBound("until", *until, now); err != nil {
+			return r, err
+		}
+		r.bounded = true
+	}
+
+	return r, nil
+}
+
+// contains reports whether timestamp is within the range
+func (r timeRange) contains(timestamp int64) bool {
+	return timestamp >= r.since && timestamp <= r.until
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// noteMatches reports whether a note's text or any metadata value contains
+// query, ignoring case
+func noteMatches(n *state.Note, query string) bool {
+	if containsFold(n.Text, query) {
+		return true
+	}
+	for _, value := range n.Metadata {
+		if containsFold(value, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// addCommentContext attaches surrounding code to comments with a line number.
+// Comments whose file cannot be read at their commit (e.g. uncommitted or
+// garbage-collected commits) are left without context, as are comments on the
+// old side of the diff, whose lines don't number the file at their commit.
+func addCommentContext(repoPath string, results []CommentResult, contextLines int) {
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return
+	}
+
+	contents := map[string][]string{}
+	for i := range results {
+		c := &results[i]
+		if c.LineNumber == nil || c.Side == state.SideOld {
+			continue
+		}
+
+		key := c.Commit + ":" + c.FilePath
+		lines, ok := contents[key]
+		if !ok {
+			content, err := gitRepo.ReadFileAtCommit(c.Commit, c.FilePath)
+			if err == nil {
+				lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+			}
+			contents[key] = lines
+		}
+
+		line := *c.LineNumber
+		if lines == nil || line < 1 || line > len(lines) {
+			continue
+		}
+
+		start := max(line-contextLines, 1)
+		end := min(line+contextLines, len(lines))
+		c.Context = &CodeContext{
+			StartLine: start,
+			Lines:     lines[start-1 : end],
+		}
+	}
+}
+
+// resolveFilePaths returns the set of paths matched by a file filter. When
+// following renames, the file's previous paths from git history are included.
+func resolveFilePaths(repoPath string, filePath *string, followRenames bool) (map[string]bool, error) {
+	if filePath == nil {
+		return nil, nil
+	}
+
+	if !followRenames {
+		return map[string]bool{*filePath: true}, nil
+	}
+
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := gitRepo.FileHistoryPaths(*filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool, len(history))
+	for _, p := range history {
+		paths[p] = true
+	}
+	return paths, nil
+}
+
+func ResolveComment(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return ResolveCommentWithManager(paramsRaw, stateMgr)
+}
+
+func ResolveCommentWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params ResolveCommentParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.CommentID == "" {
+		return nil, fmt.Errorf("%w: comment_id is required", ErrInvalidParams)
+	}
+
+	if params.ResolvedBy == "" {
+		return nil, fmt.Errorf("%w: resolved_by is required", ErrInvalidParams)
+	}
+
+	repoPath := params.RepoPath
+
+	absPath, err := repoAbsPath(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get all comments to find the one to resolve
+	allComments := stateMgr.GetAllComments(absPath)
+
+	var targetComment *state.Comment
+	for _, c := range allComments {
+		if c.ID == params.CommentID {
+			targetComment = c
+			break
+		}
+	}
+
+	if targetComment == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommentNotFound, params.CommentID)
+	}
+
+	// Resolve the comment
+	if err := stateMgr.ResolveComment(absPath, targetComment.Branch, targetComment.Commit, params.CommentID, params.ResolvedBy); err != nil {
+		return nil, fmt.Errorf("failed to resolve comment: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"comment_id":  params.CommentID,
+		"resolved_by": params.ResolvedBy,
+		"repo_path":   absPath,
+	}, nil
+}
+
+func UnresolveComment(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return UnresolveCommentWithManager(paramsRaw, stateMgr)
+}
+
+// UnresolveCommentWithManager reopens a resolved comment wherever it was made
+func UnresolveCommentWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params UnresolveCommentParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.CommentID == "" {
+		return nil, fmt.Errorf("%w: comment_id is required", ErrInvalidParams)
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetComment *state.Comment
+	for _, c := range stateMgr.GetAllComments(absPath) {
+		if c.ID == params.CommentID {
+			targetComment = c
+			break
+		}
+	}
+
+	if targetComment == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommentNotFound, params.CommentID)
+	}
+
+	if !targetComment.Resolved {
+		return nil, fmt.Errorf("%w: %s", ErrCommentNotResolved, params.CommentID)
+	}
+
+	if err := stateMgr.UnresolveComment(absPath, targetComment.Branch, targetComment.Commit, params.CommentID); err != nil {
+		return nil, fmt.Errorf("failed to reopen comment: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"comment_id": params.CommentID,
+		"repo_path":  absPath,
+	}, nil
+}
+
+func AddComment(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return AddCommentWithManager(paramsRaw, stateMgr)
+}
+
+func AddCommentWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params AddCommentParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.Branch == "" {
+		return nil, fmt.Errorf("%w: branch is required", ErrInvalidParams)
+	}
+
+	if params.Commit == "" {
+		return nil, fmt.Errorf("%w: commit is required", ErrInvalidParams)
+	}
+
+	if params.FilePath == "" {
+		return nil, fmt.Errorf("%w: file_path is required", ErrInvalidParams)
+	}
+
+	if params.Text == "" && params.Suggestion == "" {
+		return nil, fmt.Errorf("%w: text or suggestion is required", ErrInvalidParams)
+	}
+
+	if params.Author == "" {
+		return nil, fmt.Errorf("%w: author is required", ErrInvalidParams)
+	}
+
+	if err := author.Validate(params.Author); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if err := state.ValidateLineRange(params.LineNumber, params.EndLine); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if err := state.ValidateSide(params.Side); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	commentType := params.Type
+	if commentType == "" && params.Suggestion != "" {
+		commentType = "suggestion"
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the comments being superseded before the new one exists
+	var superseded []*state.Comment
+	if params.ResolveExisting {
+		for _, c := range stateMgr.GetAllComments(absPath) {
+			if !c.Resolved && c.Author == params.Author && c.FilePath == params.FilePath && sameLine(c.LineNumber, params.LineNumber) && (c.LineNumber == nil || sameSide(c.Side, params.Side)) {
+				superseded = append(superseded, c)
+			}
+		}
+	}
+
+	comment, err := stateMgr.AddComment(
+		absPath,
+		params.Branch,
+		params.Commit,
+		params.FilePath,
+		params.LineNumber,
+		params.EndLine,
+		params.Side,
+		params.Text,
+		params.Suggestion,
+		params.Author,
+		commentType,
+		params.ParentID,
+		params.Metadata,
+	)
+	if errors.Is(err, state.ErrParentNotFound) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"comment_id": comment.ID,
+		"author":     comment.Author,
+		"repo_path":  absPath,
+	}
+
+	if params.ResolveExisting {
+		resolvedIDs := []string{}
+		for _, c := range superseded {
+			if err := stateMgr.ResolveComment(absPath, c.Branch, c.Commit, c.ID, params.Author); err != nil {
+				return nil, fmt.Errorf("failed to resolve comment %s: %w", c.ID, err)
+			}
+			resolvedIDs = append(resolvedIDs, c.ID)
+		}
+		result["resolved_comment_ids"] = resolvedIDs
+	}
+
+	return result, nil
+}
+
+// sameLine reports whether two comments target the same line, treating two
+// file-level comments as matching
+func sameLine(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// sameSide reports whether two line comment sides match, treating an empty
+// side as state.SideNew
+func sameSide(a, b string) bool {
+	if a == "" {
+		a = state.SideNew
+	}
+	if b == "" {
+		b = state.SideNew
+	}
+	return a == b
+}
+
+func DeleteComment(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return DeleteCommentWithManager(paramsRaw, stateMgr)
+}
+
+func DeleteCommentWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params DeleteCommentParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.CommentID == "" {
+		return nil, fmt.Errorf("%w: comment_id is required", ErrInvalidParams)
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get all comments to find the one to delete
+	allComments := stateMgr.GetAllComments(absPath)
+
+	var targetComment *state.Comment
+	for _, c := range allComments {
+		if c.ID == params.CommentID {
+			targetComment = c
+			break
+		}
+	}
+
+	if targetComment == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommentNotFound, params.CommentID)
+	}
+
+	// Delete the comment
+	if err := stateMgr.DeleteComment(absPath, targetComment.Branch, targetComment.Commit, params.CommentID); err != nil {
+		return nil, fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"comment_id": params.CommentID,
+		"repo_path":  absPath,
+	}, nil
+}
+
+func AddNote(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return AddNoteWithManager(paramsRaw, stateMgr)
+}
+
+func AddNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params AddNoteParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.Branch == "" {
+		return nil, fmt.Errorf("%w: branch is required", ErrInvalidParams)
+	}
+
+	if params.Commit == "" {
+		return nil, fmt.Errorf("%w: commit is required", ErrInvalidParams)
+	}
+
+	if params.FilePath == "" {
+		return nil, fmt.Errorf("%w: file_path is required", ErrInvalidParams)
+	}
+
+	if params.Text == "" {
+		return nil, fmt.Errorf("%w: text is required", ErrInvalidParams)
+	}
+
+	if params.Author == "" {
+		return nil, fmt.Errorf("%w: author is required", ErrInvalidParams)
+	}
+
+	if err := author.Validate(params.Author); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	// Default type to "explanation" if not provided
+	noteType := params.Type
+	if noteType == "" {
+		noteType = "explanation"
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := stateMgr.AddNote(
+		absPath,
+		params.Branch,
+		params.Commit,
+		params.FilePath,
+		params.LineNumber,
+		params.Text,
+		params.Author,
+		noteType,
+		params.Metadata,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success":   true,
+		"note_id":   note.ID,
+		"author":    note.Author,
+		"type":      note.Type,
+		"repo_path": absPath,
+	}, nil
+}
+
+func ListNotes(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return ListNotesWithManager(paramsRaw, stateMgr)
+}
+
+func ListNotesWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params ListNotesParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.Limit < 0 || params.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must be non-negative", ErrInvalidParams)
+	}
+
+	if err := validateSort(params.Sort); err != nil {
+		return nil, err
+	}
+
+	createdIn, err := parseTimeRange(params.Since, params.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.AuthorKind != nil {
+		if err := author.ValidateKind(*params.AuthorKind); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths, err := resolveFilePaths(absPath, params.FilePath, params.FollowRenames)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*state.Note
+
+	// If branch and commit are specified, get notes for that specific state
+	if params.Branch != nil && params.Commit != nil {
+		// Renamed files are matched against every historical path below
+		filePath := params.FilePath
+		if params.FollowRenames {
+			filePath = nil
+		}
+		notes = stateMgr.GetNotes(absPath, *params.Branch, *params.Commit, filePath)
+	} else {
+		// Otherwise get all notes for the repo
+		notes = stateMgr.GetAllNotes(absPath)
+	}
+
+	// Filter by dismissal status if specified
+	if params.Dismissed != nil {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if n.Dismissed == *params.Dismissed {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by author if specified
+	if params.Author != nil {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if n.Author == *params.Author {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by author kind if specified
+	if params.AuthorKind != nil {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if author.IsKind(n.Author, *params.AuthorKind) {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by file path if specified (and not already filtered by GetNotes)
+	if params.FilePath != nil && (params.Branch == nil || params.Commit == nil || params.FollowRenames) {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if filePaths[n.FilePath] {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by text or metadata values if specified
+	if params.Search != nil && *params.Search != "" {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if noteMatches(n, *params.Search) {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by metadata pairs if specified
+	if len(params.Metadata) > 0 {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if hasMetadata(n.Metadata, params.Metadata) {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	// Filter by when the note was made if specified
+	if createdIn.bounded {
+		filtered := []*state.Note{}
+		for _, n := range notes {
+			if createdIn.contains(n.Timestamp) {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
+
+	notes = append([]*state.Note(nil), notes...)
+	sortItems(notes, params.Sort, func(n *state.Note) sortKey {
+		return sortKey{n.Timestamp, n.ID, n.FilePath, n.LineNumber}
+	})
+	total := len(notes)
+	notes = paginate(notes, params.Offset, params.Limit)
+
+	// Convert to result format
+	results := make([]NoteResult, len(notes))
+	for i, n := range notes {
+		results[i] = NoteResult{
+			ID:          n.ID,
+			FilePath:    n.FilePath,
+			LineNumber:  n.LineNumber,
+			Text:        n.Text,
+			Timestamp:   n.Timestamp,
+			Branch:      n.Branch,
+			Commit:      n.Commit,
+			Author:      n.Author,
+			Type:        n.Type,
+			Metadata:    n.Metadata,
+			Dismissed:   n.Dismissed,
+			DismissedBy: n.DismissedBy,
+			DismissedAt: n.DismissedAt,
+		}
+	}
+
+	return map[string]interface{}{
+		"notes":     results,
+		"count":     len(results),
+		"total":     total,
+		"repo_path": absPath,
+	}, nil
+}
+
+func NotesSummary(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return NotesSummaryWithManager(paramsRaw, stateMgr)
+}
+
+// NotesSummaryWithManager counts a repository's notes overall, by author and
+// by type
+func NotesSummaryWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params NotesSummaryParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts NoteCounts
+	byAuthor := map[string]NoteCounts{}
+	byType := map[string]NoteCounts{}
+	for _, n := range stateMgr.GetAllNotes(absPath) {
+		if params.Branch != nil && n.Branch != *params.Branch {
+			continue
 		}
+
+		counts.add(n)
+		authorCounts := byAuthor[n.Author]
+		authorCounts.add(n)
+		byAuthor[n.Author] = authorCounts
+		typeCounts := byType[n.Type]
+		typeCounts.add(n)
+		byType[n.Type] = typeCounts
 	}
 
 	return map[string]interface{}{
-		"comments":  results,
-		"count":     len(results),
+		"total":     counts.Total,
+		"active":    counts.Active,
+		"dismissed": counts.Dismissed,
+		"by_author": byAuthor,
+		"by_type":   byType,
 		"repo_path": absPath,
 	}, nil
 }
 
-func ResolveComment(paramsRaw json.RawMessage) (interface{}, error) {
-	stateMgr, err := state.NewManager()
+func DismissNote(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
-	return ResolveCommentWithManager(paramsRaw, stateMgr)
+	return DismissNoteWithManager(paramsRaw, stateMgr)
 }
 
-func ResolveCommentWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
-	var params ResolveCommentParams
+func DismissNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params DismissNoteParams
 	if err := json.Unmarshal(paramsRaw, &params); err != nil {
-		return nil, fmt.Errorf("invalid params: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
 	}
 
 	if params.RepoPath == "" {
-		return nil, fmt.Errorf("repo_path is required")
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
 	}
 
-	if params.CommentID == "" {
-		return nil, fmt.Errorf("comment_id is required")
+	if params.NoteID == "" {
+		return nil, fmt.Errorf("%w: note_id is required", ErrInvalidParams)
 	}
 
-	if params.ResolvedBy == "" {
-		return nil, fmt.Errorf("resolved_by is required")
+	if params.DismissedBy == "" {
+		return nil, fmt.Errorf("%w: dismissed_by is required", ErrInvalidParams)
 	}
 
-	repoPath := params.RepoPath
-
-	// Make path absolute
-	absPath, err := filepath.Abs(repoPath)
+	absPath, err := repoAbsPath(params.RepoPath)
 	if err != nil {
-		return nil, fmt.Errorf("invalid repo_path: %w", err)
+		return nil, err
 	}
 
-	// Get all comments to find the one to resolve
-	allComments := stateMgr.GetAllComments(absPath)
+	// Get all notes to find the one to dismiss
+	allNotes := stateMgr.GetAllNotes(absPath)
 
-	var targetComment *state.Comment
-	for _, c := range allComments {
-		if c.ID == params.CommentID {
-			targetComment = c
+	var targetNote *state.Note
+	for _, n := range allNotes {
+		if n.ID == params.NoteID {
+			targetNote = n
 			break
 		}
 	}
 
-	if targetComment == nil {
-		return nil, fmt.Errorf("comment not found: %s", params.CommentID)
+	if targetNote == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, params.NoteID)
 	}
 
-	// Resolve the comment
-	if err := stateMgr.ResolveComment(absPath, targetComment.Branch, targetComment.Commit, params.CommentID, params.ResolvedBy); err != nil {
-		return nil, fmt.Errorf("failed to resolve comment: %w", err)
+	// Dismiss the note
+	if err := stateMgr.DismissNote(absPath, targetNote.Branch, targetNote.Commit, params.NoteID, params.DismissedBy); err != nil {
+		return nil, fmt.Errorf("failed to dismiss note: %w", err)
 	}
 
 	return map[string]interface{}{
-		"success":     true,
-		"comment_id":  params.CommentID,
-		"resolved_by": params.ResolvedBy,
-		"repo_path":   absPath,
+		"success":      true,
+		"note_id":      params.NoteID,
+		"dismissed_by": params.DismissedBy,
+		"repo_path":    absPath,
 	}, nil
 }
 
-func AddNote(paramsRaw json.RawMessage) (interface{}, error) {
-	stateMgr, err := state.NewManager()
+func RestoreNote(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
-	return AddNoteWithManager(paramsRaw, stateMgr)
+	return RestoreNoteWithManager(paramsRaw, stateMgr)
 }
 
-func AddNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
-	var params AddNoteParams
+// RestoreNoteWithManager un-dismisses a note wherever it was made
+func RestoreNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params RestoreNoteParams
 	if err := json.Unmarshal(paramsRaw, &params); err != nil {
-		return nil, fmt.Errorf("invalid params: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
 	}
 
 	if params.RepoPath == "" {
-		return nil, fmt.Errorf("repo_path is required")
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
 	}
 
-	if params.Branch == "" {
-		return nil, fmt.Errorf("branch is required")
+	if params.NoteID == "" {
+		return nil, fmt.Errorf("%w: note_id is required", ErrInvalidParams)
 	}
 
-	if params.Commit == "" {
-		return nil, fmt.Errorf("commit is required")
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
 	}
 
-	if params.FilePath == "" {
-		return nil, fmt.Errorf("file_path is required")
+	var targetNote *state.Note
+	for _, n := range stateMgr.GetAllNotes(absPath) {
+		if n.ID == params.NoteID {
+			targetNote = n
+			break
+		}
 	}
 
-	if params.Text == "" {
-		return nil, fmt.Errorf("text is required")
+	if targetNote == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, params.NoteID)
 	}
 
-	if params.Author == "" {
-		return nil, fmt.Errorf("author is required")
+	if !targetNote.Dismissed {
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotDismissed, params.NoteID)
 	}
 
-	// Default type to "explanation" if not provided
-	noteType := params.Type
-	if noteType == "" {
-		noteType = "explanation"
+	if err := stateMgr.RestoreNote(absPath, targetNote.Branch, targetNote.Commit, params.NoteID); err != nil {
+		return nil, fmt.Errorf("failed to restore note: %w", err)
 	}
 
-	// Make path absolute
-	absPath, err := filepath.Abs(params.RepoPath)
+	return map[string]interface{}{
+		"success":   true,
+		"note_id":   params.NoteID,
+		"repo_path": absPath,
+	}, nil
+}
+
+func DeleteNote(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
 	if err != nil {
-		return nil, fmt.Errorf("invalid repo_path: %w", err)
+		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
+	return DeleteNoteWithManager(paramsRaw, stateMgr)
+}
 
-	note, err := stateMgr.AddNote(
-		absPath,
-		params.Branch,
-		params.Commit,
-		params.FilePath,
-		params.LineNumber,
-		params.Text,
-		params.Author,
-		noteType,
-		params.Metadata,
-	)
+func DeleteNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params DeleteNoteParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.NoteID == "" {
+		return nil, fmt.Errorf("%w: note_id is required", ErrInvalidParams)
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add note: %w", err)
+		return nil, err
+	}
+
+	// Get all notes to find the one to delete
+	allNotes := stateMgr.GetAllNotes(absPath)
+
+	var targetNote *state.Note
+	for _, n := range allNotes {
+		if n.ID == params.NoteID {
+			targetNote = n
+			break
+		}
+	}
+
+	if targetNote == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, params.NoteID)
+	}
+
+	// Delete the note
+	if err := stateMgr.DeleteNote(absPath, targetNote.Branch, targetNote.Commit, params.NoteID); err != nil {
+		return nil, fmt.Errorf("failed to delete note: %w", err)
 	}
 
 	return map[string]interface{}{
 		"success":   true,
-		"note_id":   note.ID,
-		"author":    note.Author,
-		"type":      note.Type,
+		"note_id":   params.NoteID,
 		"repo_path": absPath,
 	}, nil
 }
 
-func ListNotes(paramsRaw json.RawMessage) (interface{}, error) {
-	stateMgr, err := state.NewManager()
+func ListViewed(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load state: %w", err)
 	}
-	return ListNotesWithManager(paramsRaw, stateMgr)
+	return ListViewedWithManager(paramsRaw, stateMgr)
 }
 
-func ListNotesWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
-	var params ListNotesParams
+func ListViewedWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	var params ListViewedParams
 	if err := json.Unmarshal(paramsRaw, &params); err != nil {
-		return nil, fmt.Errorf("invalid params: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
 	}
 
 	if params.RepoPath == "" {
-		return nil, fmt.Errorf("repo_path is required")
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
 	}
 
-	// Make path absolute
-	absPath, err := filepath.Abs(params.RepoPath)
+	absPath, err := repoAbsPath(params.RepoPath)
 	if err != nil {
-		return nil, fmt.Errorf("invalid repo_path: %w", err)
+		return nil, err
 	}
 
-	var notes []*state.Note
-
-	// If branch and commit are specified, get notes for that specific state
-	if params.Branch != nil && params.Commit != nil {
-		notes = stateMgr.GetNotes(absPath, *params.Branch, *params.Commit, params.FilePath)
-	} else {
-		// Otherwise get all notes for the repo
-		notes = stateMgr.GetAllNotes(absPath)
+	branches := stateMgr.RepoBranches(absPath)
+	branchNames := make([]string, 0, len(branches))
+	for branch := range branches {
+		if params.Branch == nil || branch == *params.Branch {
+			branchNames = append(branchNames, branch)
+		}
 	}
-
-	// Filter by dismissal status if specified
-	if params.Dismissed != nil {
-		filtered := []*state.Note{}
-		for _, n := range notes {
-			if n.Dismissed == *params.Dismissed {
-				filtered = append(filtered, n)
+	sort.Strings(branchNames)
+
+	results := []ViewedFileResult{}
+	for _, branch := range branchNames {
+		commits := make([]string, 0, len(branches[branch]))
+		for commit := range branches[branch] {
+			if params.Commit == nil || commit == *params.Commit {
+				commits = append(commits, commit)
 			}
 		}
-		notes = filtered
-	}
-
-	// Filter by author if specified
-	if params.Author != nil {
-		filtered := []*state.Note{}
-		for _, n := range notes {
-			if n.Author == *params.Author {
-				filtered = append(filtered, n)
+		sort.Strings(commits)
+
+		for _, commit := range commits {
+			for _, filePath := range stateMgr.GetViewedFiles(absPath, branch, commit) {
+				results = append(results, ViewedFileResult{
+					FilePath: filePath,
+					Branch:   branch,
+					Commit:   commit,
+				})
 			}
 		}
-		notes = filtered
 	}
 
-	// Filter by file path if specified (and not already filtered by GetNotes)
-	if params.FilePath != nil && (params.Branch == nil || params.Commit == nil) {
-		filtered := []*state.Note{}
-		for _, n := range notes {
-			if n.FilePath == *params.FilePath {
-				filtered = append(filtered, n)
+	return map[string]interface{}{
+		"viewed_files": results,
+		"count":        len(results),
+		"repo_path":    absPath,
+	}, nil
+}
+
+func MarkViewed(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return MarkViewedWithManager(paramsRaw, stateMgr)
+}
+
+func MarkViewedWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	return setViewed(paramsRaw, stateMgr, true)
+}
+
+func UnmarkViewed(paramsRaw json.RawMessage) (interface{}, error) {
+	stateMgr, err := newStateManager(paramsRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return UnmarkViewedWithManager(paramsRaw, stateMgr)
+}
+
+func UnmarkViewedWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
+	return setViewed(paramsRaw, stateMgr, false)
+}
+
+// setViewed marks or unmarks a file as viewed, defaulting the branch and
+// commit to the repository's current HEAD
+func setViewed(paramsRaw json.RawMessage, stateMgr *state.Manager, viewed bool) (interface{}, error) {
+	var params MarkViewedParams
+	if err := json.Unmarshal(paramsRaw, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	if params.RepoPath == "" {
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
+	}
+
+	if params.FilePath == "" {
+		return nil, fmt.Errorf("%w: file_path is required", ErrInvalidParams)
+	}
+
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var branch, commit string
+	if params.Branch != nil {
+		branch = *params.Branch
+	}
+	if params.Commit != nil {
+		commit = *params.Commit
+	}
+
+	if branch == "" || commit == "" {
+		gitRepo, err := git.Open(absPath)
+		if err != nil {
+			return nil, err
+		}
+		if branch == "" {
+			if branch, err = gitRepo.CurrentBranch(); err != nil {
+				return nil, err
+			}
+		}
+		if commit == "" {
+			if commit, err = gitRepo.CurrentCommit(); err != nil {
+				return nil, err
 			}
 		}
-		notes = filtered
 	}
 
-	// Convert to result format
-	results := make([]NoteResult, len(notes))
-	for i, n := range notes {
-		results[i] = NoteResult{
-			ID:          n.ID,
-			FilePath:    n.FilePath,
-			LineNumber:  n.LineNumber,
-			Text:        n.Text,
-			Timestamp:   n.Timestamp,
-			Branch:      n.Branch,
-			Commit:      n.Commit,
-			Author:      n.Author,
-			Type:        n.Type,
-			Metadata:    n.Metadata,
-			Dismissed:   n.Dismissed,
-			DismissedBy: n.DismissedBy,
-			DismissedAt: n.DismissedAt,
-		}
+	if viewed {
+		err = stateMgr.MarkFileViewed(absPath, branch, commit, params.FilePath)
+	} else {
+		err = stateMgr.UnmarkFileViewed(absPath, branch, commit, params.FilePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update viewed state: %w", err)
 	}
 
 	return map[string]interface{}{
-		"notes":     results,
-		"count":     len(results),
+		"success":   true,
+		"file_path": params.FilePath,
+		"viewed":    viewed,
+		"branch":    branch,
+		"commit":    commit,
 		"repo_path": absPath,
 	}, nil
 }
 
-func DismissNote(paramsRaw json.RawMessage) (interface{}, error) {
-	stateMgr, err := state.NewManager()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load state: %w", err)
-	}
-	return DismissNoteWithManager(paramsRaw, stateMgr)
+func GetDiff(paramsRaw json.RawMessage) (interface{}, error) {
+	return GetDiffWithProgress(paramsRaw, nil)
 }
 
-func DismissNoteWithManager(paramsRaw json.RawMessage, stateMgr *state.Manager) (interface{}, error) {
-	var params DismissNoteParams
+// ProgressFunc reports how far a long-running tool call has got; total is 0
+// when it is not yet known
+type ProgressFunc func(progress, total int, message string)
+
+// GetDiffWithProgress is GetDiff reporting progress as files are processed
+func GetDiffWithProgress(paramsRaw json.RawMessage, progress ProgressFunc) (interface{}, error) {
+	if progress == nil {
+		progress = func(int, int, string) {}
+	}
+
+	var params GetDiffParams
 	if err := json.Unmarshal(paramsRaw, &params); err != nil {
-		return nil, fmt.Errorf("invalid params: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
 	}
 
 	if params.RepoPath == "" {
-		return nil, fmt.Errorf("repo_path is required")
+		return nil, fmt.Errorf("%w: repo_path is required", ErrInvalidParams)
 	}
 
-	if params.NoteID == "" {
-		return nil, fmt.Errorf("note_id is required")
+	absPath, err := repoAbsPath(params.RepoPath)
+	if err != nil {
+		return nil, err
 	}
 
-	if params.DismissedBy == "" {
-		return nil, fmt.Errorf("dismissed_by is required")
+	cfg, err := config.LoadForRepo(absPath)
+	if err != nil {
+		return nil, err
 	}
 
-	// Make path absolute
-	absPath, err := filepath.Abs(params.RepoPath)
-	if err != nil {
-		return nil, fmt.Errorf("invalid repo_path: %w", err)
+	base := cfg.BaseBranch
+	if params.Base != nil && *params.Base != "" {
+		base = *params.Base
 	}
 
-	// Get all notes to find the one to dismiss
-	allNotes := stateMgr.GetAllNotes(absPath)
+	opts := git.DiffOptions{IgnoreWhitespace: cfg.IgnoreWhitespace}
+	if params.IgnoreWhitespace != nil {
+		opts.IgnoreWhitespace = *params.IgnoreWhitespace
+	}
 
-	var targetNote *state.Note
-	for _, n := range allNotes {
-		if n.ID == params.NoteID {
-			targetNote = n
-			break
-		}
+	gitRepo, err := git.Open(absPath)
+	if err != nil {
+		return nil, err
 	}
 
-	if targetNote == nil {
-		return nil, fmt.Errorf("note not found: %s", params.NoteID)
+	var diff *git.DiffResult
+	if params.Uncommitted {
+		progress(0, 0, "Computing uncommitted changes")
+		headCommit, err := gitRepo.CurrentCommit()
+		if err != nil {
+			return nil, err
+		}
+		files, err := gitRepo.GetUncommittedChanges(opts)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		diff = &git.DiffResult{BaseCommit: headCommit, Files: files}
+	} else {
+		progress(0, 0, fmt.Sprintf("Computing diff against %s", base))
+		diff, err = gitRepo.GetDiffFiles(base, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Dismiss the note
-	if err := stateMgr.DismissNote(absPath, targetNote.Branch, targetNote.Commit, params.NoteID, params.DismissedBy); err != nil {
-		return nil, fmt.Errorf("failed to dismiss note: %w", err)
+	step := len(diff.Files)/20 + 1
+	files := make([]DiffFileResult, len(diff.Files))
+	for i, file := range diff.Files {
+		files[i] = DiffFileResult{
+			Path:          file.Path,
+			Status:        file.Status,
+			Additions:     file.Additions,
+			Deletions:     file.Deletions,
+			Patch:         file.Patch,
+			StagingStatus: file.StagingStatus,
+			FromPath:      file.FromPath,
+			Similarity:    file.Similarity,
+		}
+
+		// Binary patches carry no useful content, so describe the blobs instead
+		if file.Binary {
+			files[i].Patch = ""
+			files[i].IsBinary = true
+			if info, err := gitRepo.DescribeBinary(file, diff.BaseCommit, diff.HeadCommit); err == nil {
+				files[i].OldSize = &info.OldSize
+				files[i].NewSize = &info.NewSize
+				files[i].MimeType = info.MimeType
+			}
+		}
+
+		// Report about every 5% so huge diffs don't flood the client
+		if done := i + 1; done%step == 0 || done == len(diff.Files) {
+			progress(done, len(diff.Files), file.Path)
+		}
 	}
 
-	return map[string]interface{}{
-		"success":      true,
-		"note_id":      params.NoteID,
-		"dismissed_by": params.DismissedBy,
-		"repo_path":    absPath,
-	}, nil
+	result := map[string]interface{}{
+		"base_commit": diff.BaseCommit,
+		"head_commit": diff.HeadCommit,
+		"files":       files,
+		"count":       len(files),
+		"uncommitted": params.Uncommitted,
+		"repo_path":   absPath,
+	}
+	if !params.Uncommitted {
+		result["base"] = base
+	}
+	return result, nil
 }
@@ -1,10 +1,18 @@
 package mcp
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/tuist/guck/internal/git"
 	"github.com/tuist/guck/internal/state"
 )
 
@@ -14,6 +22,12 @@ func createTestManager(t *testing.T) (*state.Manager, string) {
 	tempDir := t.TempDir()
 	testRepoPath := filepath.Join(tempDir, "test-repo")
 
+	// Tools reject a repo_path that isn't a git repository
+	if err := os.MkdirAll(testRepoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	runGit(t, testRepoPath, "init", "-q", "-b", "main")
+
 	// Override XDG_STATE_HOME to use temp directory
 	t.Setenv("XDG_STATE_HOME", tempDir)
 
@@ -25,6 +39,17 @@ func createTestManager(t *testing.T) (*state.Manager, string) {
 	return manager, testRepoPath
 }
 
+// runGit runs a git command in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+}
+
 func TestListTools(t *testing.T) {
 	tools := ListTools()
 
@@ -33,8 +58,8 @@ func TestListTools(t *testing.T) {
 		t.Fatal("Expected tools to be a slice of maps")
 	}
 
-	if len(toolsList) != 5 {
-		t.Errorf("Expected 5 tools, got %d", len(toolsList))
+	if len(toolsList) != 15 {
+		t.Errorf("Expected 15 tools, got %d", len(toolsList))
 	}
 
 	// Check list_comments tool
@@ -48,6 +73,22 @@ func TestListTools(t *testing.T) {
 	if resolveCommentTool["name"] != "resolve_comment" {
 		t.Errorf("Expected second tool to be resolve_comment, got %s", resolveCommentTool["name"])
 	}
+
+	// A suggestion can stand in for a comment's text, but notes always need text
+	wantText := map[string]bool{"add_comment": false, "add_note": true}
+	for _, tool := range toolsList {
+		want, ok := wantText[tool["name"].(string)]
+		if !ok {
+			continue
+		}
+		required := false
+		for _, name := range tool["inputSchema"].(map[string]interface{})["required"].([]string) {
+			required = required || name == "text"
+		}
+		if required != want {
+			t.Errorf("Expected %s to require text: %v, got %v", tool["name"], want, required)
+		}
+	}
 }
 
 func TestListCommentsWithManager_EmptyRepo(t *testing.T) {
@@ -87,12 +128,12 @@ func TestListCommentsWithManager_WithComments(t *testing.T) {
 	filePath := "test.go"
 	lineNumber := 42
 
-	_, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, "Test comment 1")
+	_, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, nil, "", "Test comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, "Test comment 2")
+	_, err = manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, nil, "", "Test comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -127,17 +168,17 @@ func TestListCommentsWithManager_FilterByBranchAndCommit(t *testing.T) {
 	lineNumber := 42
 
 	// Add comments to different branches/commits
-	_, err := manager.AddComment(repoPath, "main", "commit1", "file.go", &lineNumber, "Comment 1")
+	_, err := manager.AddComment(repoPath, "main", "commit1", "file.go", &lineNumber, nil, "", "Comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, "main", "commit2", "file.go", &lineNumber, "Comment 2")
+	_, err = manager.AddComment(repoPath, "main", "commit2", "file.go", &lineNumber, nil, "", "Comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, "feature", "commit3", "file.go", &lineNumber, "Comment 3")
+	_, err = manager.AddComment(repoPath, "feature", "commit3", "file.go", &lineNumber, nil, "", "Comment 3", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -165,6 +206,46 @@ func TestListCommentsWithManager_FilterByBranchAndCommit(t *testing.T) {
 	}
 }
 
+func TestStaleCommentsWithManager(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 42
+
+	stale, err := manager.AddComment(repoPath, "main", "commit1", "file.go", &lineNumber, nil, "", "Still open", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	fixed, err := manager.AddComment(repoPath, "main", "commit1", "file.go", &lineNumber, nil, "", "Fixed", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if err := manager.ResolveComment(repoPath, "main", "commit1", fixed.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "main", "commit2", "file.go", &lineNumber, nil, "", "At HEAD", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "feature", "commit1", "file.go", &lineNumber, nil, "", "Other branch", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	comments, err := StaleCommentsWithManager(repoPath, "main", "commit2", manager)
+	if err != nil {
+		t.Fatalf("StaleCommentsWithManager failed: %v", err)
+	}
+	if len(comments) != 1 || comments[0].ID != stale.ID {
+		t.Fatalf("Expected only the open comment from commit1, got %+v", comments)
+	}
+
+	comments, err = StaleCommentsWithManager(repoPath, "feature", "commit1", manager)
+	if err != nil {
+		t.Fatalf("StaleCommentsWithManager failed: %v", err)
+	}
+	if len(comments) != 0 {
+		t.Errorf("Expected no stale comments at the commit they were made on, got %+v", comments)
+	}
+}
+
 func TestListCommentsWithManager_FilterByResolved(t *testing.T) {
 	manager, repoPath := createTestManager(t)
 
@@ -173,12 +254,12 @@ func TestListCommentsWithManager_FilterByResolved(t *testing.T) {
 	lineNumber := 42
 
 	// Add comments
-	comment1, err := manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, "Comment 1")
+	comment1, err := manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, nil, "", "Comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, "Comment 2")
+	_, err = manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, nil, "", "Comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -238,12 +319,12 @@ func TestListCommentsWithManager_FilterByFilePath(t *testing.T) {
 	lineNumber := 42
 
 	// Add comments to different files
-	_, err := manager.AddComment(repoPath, branch, commit, "file1.go", &lineNumber, "Comment 1")
+	_, err := manager.AddComment(repoPath, branch, commit, "file1.go", &lineNumber, nil, "", "Comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, "file2.go", &lineNumber, "Comment 2")
+	_, err = manager.AddComment(repoPath, branch, commit, "file2.go", &lineNumber, nil, "", "Comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -277,7 +358,7 @@ func TestResolveCommentWithManager_Success(t *testing.T) {
 	lineNumber := 42
 
 	// Add a comment
-	comment, err := manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, "Test comment")
+	comment, err := manager.AddComment(repoPath, branch, commit, "file.go", &lineNumber, nil, "", "Test comment", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -317,6 +398,30 @@ func TestResolveCommentWithManager_Success(t *testing.T) {
 	}
 }
 
+func TestUnresolveCommentWithManager(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	comment, err := manager.AddComment(repoPath, "feature", "def456", "file.go", nil, nil, "", "Test comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if err := manager.ResolveComment(repoPath, "feature", "def456", comment.ID, "test-user"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+
+	paramsJSON, _ := json.Marshal(UnresolveCommentParams{RepoPath: repoPath, CommentID: comment.ID})
+	if _, err := UnresolveCommentWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("UnresolveCommentWithManager failed: %v", err)
+	}
+	if manager.GetComments(repoPath, "feature", "def456", nil)[0].Resolved {
+		t.Error("Comment should be unresolved")
+	}
+
+	if _, err := UnresolveCommentWithManager(paramsJSON, manager); err == nil || !strings.Contains(err.Error(), "not resolved") {
+		t.Errorf("Expected a not resolved error, got %v", err)
+	}
+}
+
 func TestResolveCommentWithManager_MissingCommentID(t *testing.T) {
 	manager, repoPath := createTestManager(t)
 
@@ -365,6 +470,336 @@ func TestResolveCommentWithManager_CommentNotFound(t *testing.T) {
 	}
 }
 
+func TestAddCommentWithManager_KeepsAuthor(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 7
+	params := AddCommentParams{
+		RepoPath:   repoPath,
+		Branch:     "main",
+		Commit:     "abc123",
+		FilePath:   "file.go",
+		LineNumber: &lineNumber,
+		Text:       "Consider a table-driven test",
+		Author:     "claude",
+		Type:       "suggestion",
+		Metadata:   map[string]string{"model": "claude-sonnet-4"},
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := AddCommentWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("AddCommentWithManager failed: %v", err)
+	}
+
+	comments := manager.GetComments(repoPath, "main", "abc123", nil)
+	if len(comments) != 1 {
+		t.Fatalf("Expected 1 comment, got %d", len(comments))
+	}
+
+	if comments[0].Author != "claude" {
+		t.Errorf("Expected author claude, got %s", comments[0].Author)
+	}
+	if comments[0].Type != "suggestion" {
+		t.Errorf("Expected type suggestion, got %s", comments[0].Type)
+	}
+	if comments[0].Metadata["model"] != "claude-sonnet-4" {
+		t.Errorf("Expected metadata model to be kept, got %v", comments[0].Metadata)
+	}
+}
+
+func TestAddCommentWithManager_SuggestionOnly(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 10
+	params := AddCommentParams{
+		RepoPath:   repoPath,
+		Branch:     "main",
+		Commit:     "abc123",
+		FilePath:   "file.go",
+		LineNumber: &lineNumber,
+		Suggestion: "return nil\n",
+		Author:     "cli",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := AddCommentWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("AddCommentWithManager failed: %v", err)
+	}
+
+	comments := manager.GetComments(repoPath, "main", "abc123", nil)
+	if len(comments) != 1 {
+		t.Fatalf("Expected 1 comment, got %d", len(comments))
+	}
+
+	if comments[0].Suggestion != "return nil\n" {
+		t.Errorf("Expected suggestion to be kept, got %q", comments[0].Suggestion)
+	}
+	if comments[0].Type != "suggestion" {
+		t.Errorf("Expected type to default to suggestion, got %s", comments[0].Type)
+	}
+}
+
+func TestAddCommentWithManager_LineRange(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber, endLine := 40, 55
+	params := AddCommentParams{
+		RepoPath:   repoPath,
+		Branch:     "main",
+		Commit:     "abc123",
+		FilePath:   "file.go",
+		LineNumber: &lineNumber,
+		EndLine:    &endLine,
+		Text:       "Extract this block",
+		Author:     "claude",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := AddCommentWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("AddCommentWithManager failed: %v", err)
+	}
+
+	comments := manager.GetComments(repoPath, "main", "abc123", nil)
+	if len(comments) != 1 {
+		t.Fatalf("Expected 1 comment, got %d", len(comments))
+	}
+	if comments[0].EndLine == nil || *comments[0].EndLine != endLine {
+		t.Errorf("Expected end line %d, got %v", endLine, comments[0].EndLine)
+	}
+
+	endLine = 30
+	paramsJSON, _ = json.Marshal(params)
+	if _, err := AddCommentWithManager(paramsJSON, manager); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams for an end line before the line number, got %v", err)
+	}
+}
+
+func TestAddCommentWithManager_InvalidSide(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 7
+	params := AddCommentParams{
+		RepoPath:   repoPath,
+		Branch:     "main",
+		Commit:     "abc123",
+		FilePath:   "file.go",
+		LineNumber: &lineNumber,
+		Side:       "right",
+		Text:       "Wrong side",
+		Author:     "claude",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := AddCommentWithManager(paramsJSON, manager); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams for an unknown side, got %v", err)
+	}
+}
+
+func TestListCommentsWithManager_AuthorKind(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for _, author := range []string{"human:alice", "agent:claude", "agent:copilot", "web-ui"} {
+		if _, err := manager.AddComment(repoPath, "main", "abc123", "main.go", nil, nil, "", "Comment", "", author, "", "", nil); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+	}
+
+	for kind, want := range map[string]int{"human": 1, "agent": 2} {
+		paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, AuthorKind: &kind})
+		result, err := ListCommentsWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListCommentsWithManager failed: %v", err)
+		}
+		if count := result.(map[string]interface{})["count"]; count != want {
+			t.Errorf("%s: expected %d comments, got %v", kind, want, count)
+		}
+	}
+
+	kind := "robot"
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, AuthorKind: &kind})
+	if _, err := ListCommentsWithManager(paramsJSON, manager); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected an invalid params error, got %v", err)
+	}
+}
+
+func TestAddCommentWithManager_NamespaceWithoutName(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	paramsJSON, _ := json.Marshal(AddCommentParams{
+		RepoPath: repoPath,
+		Branch:   "main",
+		Commit:   "abc123",
+		FilePath: "main.go",
+		Text:     "Comment",
+		Author:   "agent:",
+	})
+	if _, err := AddCommentWithManager(paramsJSON, manager); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected an invalid params error, got %v", err)
+	}
+}
+
+func TestAddCommentWithManager_MissingAuthor(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	params := AddCommentParams{
+		RepoPath: repoPath,
+		Branch:   "main",
+		Commit:   "abc123",
+		FilePath: "file.go",
+		Text:     "No author",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := AddCommentWithManager(paramsJSON, manager); err == nil {
+		t.Error("Expected error for missing author")
+	}
+}
+
+func TestDeleteCommentWithManager_Success(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 42
+	comment, err := manager.AddComment(repoPath, "main", "abc123", "file.go", &lineNumber, nil, "", "Test comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	params := DeleteCommentParams{
+		RepoPath:  repoPath,
+		CommentID: comment.ID,
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := DeleteCommentWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("DeleteCommentWithManager failed: %v", err)
+	}
+
+	if comments := manager.GetAllComments(repoPath); len(comments) != 0 {
+		t.Errorf("Expected 0 comments after delete, got %d", len(comments))
+	}
+}
+
+func TestDeleteCommentWithManager_CommentNotFound(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	params := DeleteCommentParams{
+		RepoPath:  repoPath,
+		CommentID: "nonexistent-id",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	_, err := DeleteCommentWithManager(paramsJSON, manager)
+	if err == nil {
+		t.Error("Expected error for nonexistent comment")
+	}
+}
+
+func TestDeleteNoteWithManager_Success(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	note, err := manager.AddNote(repoPath, "main", "abc123", "file.go", nil, "Test note", "claude", "explanation", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+
+	params := DeleteNoteParams{
+		RepoPath: repoPath,
+		NoteID:   note.ID,
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	if _, err := DeleteNoteWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("DeleteNoteWithManager failed: %v", err)
+	}
+
+	if notes := manager.GetAllNotes(repoPath); len(notes) != 0 {
+		t.Errorf("Expected 0 notes after delete, got %d", len(notes))
+	}
+}
+
+func TestRestoreNoteWithManager(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	note, err := manager.AddNote(repoPath, "main", "abc123", "file.go", nil, "Test note", "claude", "explanation", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+
+	paramsJSON, _ := json.Marshal(RestoreNoteParams{RepoPath: repoPath, NoteID: note.ID})
+	if _, err := RestoreNoteWithManager(paramsJSON, manager); err == nil || !strings.Contains(err.Error(), "not dismissed") {
+		t.Errorf("Expected a not dismissed error, got %v", err)
+	}
+
+	if err := manager.DismissNote(repoPath, "main", "abc123", note.ID, "claude"); err != nil {
+		t.Fatalf("Failed to dismiss note: %v", err)
+	}
+	if _, err := RestoreNoteWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("RestoreNoteWithManager failed: %v", err)
+	}
+
+	restored := manager.GetAllNotes(repoPath)[0]
+	if restored.Dismissed || restored.DismissedBy != "" || restored.DismissedAt != 0 {
+		t.Errorf("Expected dismissal cleared, got %+v", restored)
+	}
+}
+
+func TestNotesSummaryWithManager(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	note, err := manager.AddNote(repoPath, "main", "abc123", "file.go", nil, "Note", "claude", "suggestion", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	if err := manager.DismissNote(repoPath, "main", "abc123", note.ID, "user"); err != nil {
+		t.Fatalf("Failed to dismiss note: %v", err)
+	}
+	for _, n := range []struct{ branch, author, noteType string }{
+		{"main", "claude", "suggestion"},
+		{"main", "claude", "explanation"},
+		{"main", "copilot", "suggestion"},
+		{"feature", "claude", "suggestion"},
+	} {
+		if _, err := manager.AddNote(repoPath, n.branch, "abc123", "file.go", nil, "Note", n.author, n.noteType, nil); err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+	}
+
+	branch := "main"
+	paramsJSON, _ := json.Marshal(NotesSummaryParams{RepoPath: repoPath, Branch: &branch})
+	result, err := NotesSummaryWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("NotesSummaryWithManager failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	if resultMap["total"] != 4 || resultMap["active"] != 3 || resultMap["dismissed"] != 1 {
+		t.Errorf("Expected 4 notes with 1 dismissed, got %+v", resultMap)
+	}
+	byAuthor := resultMap["by_author"].(map[string]NoteCounts)
+	if byAuthor["claude"] != (NoteCounts{Total: 3, Active: 2, Dismissed: 1}) || byAuthor["copilot"].Total != 1 {
+		t.Errorf("Unexpected counts by author: %+v", byAuthor)
+	}
+	byType := resultMap["by_type"].(map[string]NoteCounts)
+	if byType["suggestion"].Total != 3 || byType["explanation"].Total != 1 {
+		t.Errorf("Unexpected counts by type: %+v", byType)
+	}
+}
+
+func TestDeleteNoteWithManager_NoteNotFound(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	params := DeleteNoteParams{
+		RepoPath: repoPath,
+		NoteID:   "nonexistent-id",
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	_, err := DeleteNoteWithManager(paramsJSON, manager)
+	if err == nil {
+		t.Error("Expected error for nonexistent note")
+	}
+}
+
 func TestListCommentsWithManager_InvalidJSON(t *testing.T) {
 	manager, _ := createTestManager(t)
 
@@ -388,3 +823,871 @@ func TestListCommentsWithManager_MissingRepoPath(t *testing.T) {
 		t.Error("Expected error for missing repo_path")
 	}
 }
+
+func TestListCommentsWithManager_FollowRenames(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	runGit(t, repoPath, "init")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(repoPath, "old.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add old.go")
+	runGit(t, repoPath, "mv", "old.go", "new.go")
+	runGit(t, repoPath, "commit", "-m", "Rename to new.go")
+
+	lineNumber := 1
+	if _, err := manager.AddComment(repoPath, "main", "commit1", "old.go", &lineNumber, nil, "", "On old path", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "main", "commit2", "new.go", &lineNumber, nil, "", "On new path", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	filePath := "new.go"
+
+	// Without following renames only the new path matches
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, FilePath: &filePath})
+	result, err := ListCommentsWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListCommentsWithManager failed: %v", err)
+	}
+	if count := result.(map[string]interface{})["count"].(int); count != 1 {
+		t.Errorf("Expected 1 comment without follow_renames, got %d", count)
+	}
+
+	// Following renames includes the comment recorded under old.go
+	paramsJSON, _ = json.Marshal(ListCommentsParams{RepoPath: repoPath, FilePath: &filePath, FollowRenames: true})
+	result, err = ListCommentsWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListCommentsWithManager failed: %v", err)
+	}
+	if count := result.(map[string]interface{})["count"].(int); count != 2 {
+		t.Errorf("Expected 2 comments with follow_renames, got %d", count)
+	}
+}
+
+func TestListCommentsWithManager_ContextLines(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	runGit(t, repoPath, "init")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	content := "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	if err := os.WriteFile(filepath.Join(repoPath, "file.go"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add file.go")
+
+	out, err := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	lineNumber := 2
+	if _, err := manager.AddComment(repoPath, "main", commit, "file.go", &lineNumber, nil, "", "Near the top", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	// Comments on commits that don't exist are returned without context
+	if _, err := manager.AddComment(repoPath, "main", "__uncommitted__", "file.go", &lineNumber, nil, "", "Uncommitted", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, ContextLines: 2})
+	result, err := ListCommentsWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListCommentsWithManager failed: %v", err)
+	}
+
+	comments := result.(map[string]interface{})["comments"].([]CommentResult)
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments, got %d", len(comments))
+	}
+
+	for _, c := range comments {
+		if c.Commit != commit {
+			if c.Context != nil {
+				t.Errorf("Expected no context for missing commit, got %+v", c.Context)
+			}
+			continue
+		}
+
+		if c.Context == nil {
+			t.Fatal("Expected context for committed comment")
+		}
+		if c.Context.StartLine != 1 {
+			t.Errorf("Expected context to start at line 1, got %d", c.Context.StartLine)
+		}
+		expected := []string{"line 1", "line 2", "line 3", "line 4"}
+		if strings.Join(c.Context.Lines, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected context %v, got %v", expected, c.Context.Lines)
+		}
+	}
+}
+
+func TestListViewedWithManager(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for _, entry := range []struct{ branch, commit, file string }{
+		{"main", "abc123", "a.go"},
+		{"main", "abc123", "b.go"},
+		{"feature", "def456", "c.go"},
+	} {
+		if err := manager.MarkFileViewed(repoPath, entry.branch, entry.commit, entry.file); err != nil {
+			t.Fatalf("Failed to mark file viewed: %v", err)
+		}
+	}
+
+	paramsJSON, _ := json.Marshal(ListViewedParams{RepoPath: repoPath})
+	result, err := ListViewedWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListViewedWithManager failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	if resultMap["count"] != 3 {
+		t.Errorf("Expected 3 viewed files, got %v", resultMap["count"])
+	}
+
+	branch := "main"
+	paramsJSON, _ = json.Marshal(ListViewedParams{RepoPath: repoPath, Branch: &branch})
+	result, err = ListViewedWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListViewedWithManager failed: %v", err)
+	}
+
+	viewed := result.(map[string]interface{})["viewed_files"].([]ViewedFileResult)
+	if len(viewed) != 2 || viewed[0].FilePath != "a.go" || viewed[1].FilePath != "b.go" {
+		t.Errorf("Expected a.go and b.go on main, got %v", viewed)
+	}
+
+	if _, err := ListViewedWithManager(json.RawMessage(`{}`), manager); err == nil {
+		t.Error("Expected error when repo_path is missing")
+	}
+}
+
+func TestMarkViewedWithManager_DefaultsToHead(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	runGit(t, repoPath, "init", "-b", "main")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(repoPath, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Initial commit")
+
+	paramsJSON, _ := json.Marshal(MarkViewedParams{RepoPath: repoPath, FilePath: "main.go"})
+	result, err := MarkViewedWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("MarkViewedWithManager failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	commit, _ := resultMap["commit"].(string)
+	if resultMap["branch"] != "main" || len(commit) != 40 {
+		t.Fatalf("Expected HEAD of main, got branch %v commit %v", resultMap["branch"], resultMap["commit"])
+	}
+	if !manager.IsFileViewed(repoPath, "main", commit, "main.go") {
+		t.Error("Expected file to be marked viewed at HEAD")
+	}
+
+	result, err = UnmarkViewedWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("UnmarkViewedWithManager failed: %v", err)
+	}
+	if result.(map[string]interface{})["viewed"] != false {
+		t.Error("Expected viewed to be false after unmarking")
+	}
+	if manager.IsFileViewed(repoPath, "main", commit, "main.go") {
+		t.Error("Expected file to be unmarked")
+	}
+}
+
+func TestMarkViewedWithManager_ExplicitCommit(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	// No git repository is needed when branch and commit are given
+	branch, commit := "feature", "abc123"
+	paramsJSON, _ := json.Marshal(MarkViewedParams{RepoPath: repoPath, Branch: &branch, Commit: &commit, FilePath: "a.go"})
+	if _, err := MarkViewedWithManager(paramsJSON, manager); err != nil {
+		t.Fatalf("MarkViewedWithManager failed: %v", err)
+	}
+	if !manager.IsFileViewed(repoPath, branch, commit, "a.go") {
+		t.Error("Expected file to be marked viewed")
+	}
+
+	paramsJSON, _ = json.Marshal(MarkViewedParams{RepoPath: repoPath, Branch: &branch, Commit: &commit})
+	if _, err := MarkViewedWithManager(paramsJSON, manager); err == nil {
+		t.Error("Expected error when file_path is missing")
+	}
+}
+
+func TestGetDiff(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repoPath := t.TempDir()
+
+	runGit(t, repoPath, "init", "-b", "main")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(repoPath, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Initial commit")
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(repoPath, "feature.go"), []byte("package main\n\nfunc feature() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add feature")
+	if err := os.WriteFile(filepath.Join(repoPath, "main.go"), []byte("package main\n\n// edited\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	base := "main"
+	paramsJSON, _ := json.Marshal(GetDiffParams{RepoPath: repoPath, Base: &base})
+	result, err := GetDiff(paramsJSON)
+	if err != nil {
+		t.Fatalf("GetDiff failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	files := resultMap["files"].([]DiffFileResult)
+	if len(files) != 1 || files[0].Path != "feature.go" || files[0].Status != "added" {
+		t.Fatalf("Expected feature.go to be added, got %+v", files)
+	}
+	if !strings.Contains(files[0].Patch, "+func feature() {}") {
+		t.Errorf("Expected patch to contain the new function, got %q", files[0].Patch)
+	}
+	if resultMap["base_commit"] == "" || resultMap["head_commit"] == "" {
+		t.Errorf("Expected base and head commits, got %v", resultMap)
+	}
+
+	paramsJSON, _ = json.Marshal(GetDiffParams{RepoPath: repoPath, Uncommitted: true})
+	result, err = GetDiff(paramsJSON)
+	if err != nil {
+		t.Fatalf("GetDiff failed: %v", err)
+	}
+
+	files = result.(map[string]interface{})["files"].([]DiffFileResult)
+	if len(files) != 1 || files[0].Path != "main.go" || files[0].StagingStatus != git.StagingStatusUnstaged {
+		t.Errorf("Expected unstaged main.go, got %+v", files)
+	}
+
+	if _, err := GetDiff(json.RawMessage(`{}`)); err == nil {
+		t.Error("Expected error when repo_path is missing")
+	}
+}
+
+func TestListCommentsWithManager_Search(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for _, text := range []string{"Handle the ERROR here", "Rename this variable", "error wrapping is missing"} {
+		if _, err := manager.AddComment(repoPath, "main", "abc123", "main.go", nil, nil, "", text, "", "", "", "", nil); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+	}
+
+	search := "error"
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, Search: &search})
+	result, err := ListCommentsWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListCommentsWithManager failed: %v", err)
+	}
+
+	if count := result.(map[string]interface{})["count"]; count != 2 {
+		t.Errorf("Expected 2 matching comments, got %v", count)
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-05-01T09:00:00Z", time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.AddDate(0, 0, -7)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound("since", tt.value, now)
+		if err != nil || got != tt.want.Unix() {
+			t.Errorf("%q: expected %v, got %v (%v)", tt.value, tt.want, time.Unix(got, 0).UTC(), err)
+		}
+	}
+
+	for _, value := range []string{"yesterday", "-24h", "2024-05-01"} {
+		if _, err := parseTimeBound("since", value, now); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: expected an invalid params error, got %v", value, err)
+		}
+	}
+}
+
+func TestListCommentsWithManager_TimeRange(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	now := time.Now()
+	for _, age := range []time.Duration{time.Hour, 3 * 24 * time.Hour, 10 * 24 * time.Hour} {
+		comment, err := manager.AddComment(repoPath, "main", "abc123", "main.go", nil, nil, "", age.String(), "", "", "", "", nil)
+		if err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		comment.Timestamp = now.Add(-age).Unix()
+	}
+
+	for _, tt := range []struct {
+		since, until string
+		want         int
+	}{
+		{"24h", "", 1},
+		{"7d", "", 2},
+		{"", "2d", 2},
+		{"7d", "2d", 1},
+		{now.Add(-time.Hour).Format(time.RFC3339), "", 1},
+	} {
+		params := ListCommentsParams{RepoPath: repoPath}
+		if tt.since != "" {
+			params.Since = &tt.since
+		}
+		if tt.until != "" {
+			params.Until = &tt.until
+		}
+		paramsJSON, _ := json.Marshal(params)
+		result, err := ListCommentsWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListCommentsWithManager failed: %v", err)
+		}
+		if count := result.(map[string]interface{})["count"]; count != tt.want {
+			t.Errorf("since %q until %q: expected %d comments, got %v", tt.since, tt.until, tt.want, count)
+		}
+	}
+}
+
+func TestListNotesWithManager_Metadata(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for _, metadata := range []map[string]string{
+		{"model": "claude-sonnet-4", "confidence": "high"},
+		{"model": "claude-sonnet-4", "confidence": "low"},
+		{"model": "gpt-4", "confidence": "high"},
+		nil,
+	} {
+		if _, err := manager.AddNote(repoPath, "main", "abc123", "main.go", nil, "Note", "claude", "suggestion", metadata); err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		metadata map[string]string
+		want     int
+	}{
+		{map[string]string{"model": "claude-sonnet-4"}, 2},
+		{map[string]string{"model": "claude-sonnet-4", "confidence": "high"}, 1},
+		{map[string]string{"confidence": "medium"}, 0},
+	} {
+		paramsJSON, _ := json.Marshal(ListNotesParams{RepoPath: repoPath, Metadata: tt.metadata})
+		result, err := ListNotesWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListNotesWithManager failed: %v", err)
+		}
+		if count := result.(map[string]interface{})["count"]; count != tt.want {
+			t.Errorf("%v: expected %d notes, got %v", tt.metadata, tt.want, count)
+		}
+	}
+}
+
+func TestListNotesWithManager_Search(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	if _, err := manager.AddNote(repoPath, "main", "abc123", "main.go", nil, "Uses binary search", "claude", "explanation", nil); err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	if _, err := manager.AddNote(repoPath, "main", "abc123", "main.go", nil, "Caches results", "claude", "rationale", map[string]string{"ticket": "PERF-12"}); err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+
+	for query, expected := range map[string]int{"BINARY": 1, "perf-12": 1, "missing": 0} {
+		search := query
+		paramsJSON, _ := json.Marshal(ListNotesParams{RepoPath: repoPath, Search: &search})
+		result, err := ListNotesWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListNotesWithManager failed: %v", err)
+		}
+
+		if count := result.(map[string]interface{})["count"]; count != expected {
+			t.Errorf("Expected %d notes matching %q, got %v", expected, query, count)
+		}
+	}
+}
+
+func TestGetDiff_BinaryDescriptor(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repoPath := t.TempDir()
+
+	runGit(t, repoPath, "init", "-b", "main")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Test\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Initial commit")
+	runGit(t, repoPath, "checkout", "-b", "feature")
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	if err := os.WriteFile(filepath.Join(repoPath, "logo.png"), png, 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add logo")
+
+	base := "main"
+	paramsJSON, _ := json.Marshal(GetDiffParams{RepoPath: repoPath, Base: &base})
+	result, err := GetDiff(paramsJSON)
+	if err != nil {
+		t.Fatalf("GetDiff failed: %v", err)
+	}
+
+	files := result.(map[string]interface{})["files"].([]DiffFileResult)
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %+v", files)
+	}
+
+	file := files[0]
+	if !file.IsBinary || file.Patch != "" {
+		t.Errorf("Expected a binary descriptor without a patch, got %+v", file)
+	}
+	if file.OldSize == nil || *file.OldSize != 0 || file.NewSize == nil || *file.NewSize != int64(len(png)) {
+		t.Errorf("Expected sizes 0 -> %d, got %v -> %v", len(png), file.OldSize, file.NewSize)
+	}
+	if file.MimeType != "image/png" {
+		t.Errorf("Expected image/png, got %s", file.MimeType)
+	}
+
+	data, _ := json.Marshal(file)
+	if strings.Contains(string(data), "\"patch\"") {
+		t.Errorf("Expected no patch in JSON, got %s", data)
+	}
+}
+
+func TestHandleToolsCall_GetDiffProgress(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repoPath := t.TempDir()
+
+	runGit(t, repoPath, "init", "-b", "main")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	runGit(t, repoPath, "commit", "--allow-empty", "-m", "Initial commit")
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	runGit(t, repoPath, "add", ".")
+
+	var out bytes.Buffer
+	sess := newSession(&out)
+	handleInitialize(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Params: map[string]interface{}{"protocolVersion": "2025-03-26"}}, sess)
+
+	params := map[string]interface{}{
+		"name":      "get_diff",
+		"arguments": map[string]interface{}{"repo_path": repoPath, "uncommitted": true},
+		"_meta":     map[string]interface{}{"progressToken": "diff-1"},
+	}
+	response := handleToolsCall(JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: params}, newNotifier(sess, params), "")
+	if result, ok := response.Result.(CallToolResult); !ok || result.IsError {
+		t.Fatalf("Expected get_diff to succeed, got %+v", response)
+	}
+
+	var last map[string]interface{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var notification JSONRPCRequest
+		if err := decoder.Decode(&notification); err != nil {
+			t.Fatalf("Failed to decode notification: %v", err)
+		}
+		if notification.Method != "notifications/progress" || notification.ID != nil {
+			t.Fatalf("Unexpected message on stdout: %+v", notification)
+		}
+		last = notification.Params.(map[string]interface{})
+		if last["progressToken"] != "diff-1" {
+			t.Errorf("Expected progress token diff-1, got %v", last["progressToken"])
+		}
+	}
+
+	if last == nil {
+		t.Fatal("Expected a progress notification")
+	}
+	if last["progress"] != float64(3) || last["total"] != float64(3) {
+		t.Errorf("Expected final progress 3/3, got %v", last)
+	}
+	if last["message"] != "c.go" {
+		t.Errorf("Expected the last file in the progress message, got %v", last["message"])
+	}
+
+	// Without a negotiated protocol version nothing is sent
+	out.Reset()
+	handleToolsCall(JSONRPCRequest{JSONRPC: "2.0", ID: 3, Method: "tools/call", Params: params}, newNotifier(newSession(&out), params), "")
+	if out.Len() != 0 {
+		t.Errorf("Expected no notifications before initialize, got %s", out.String())
+	}
+}
+
+func TestToolsListMatchesDispatch(t *testing.T) {
+	response := handleToolsList(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}, "")
+	result, ok := response.Result.(ListToolsResult)
+	if !ok {
+		t.Fatalf("Expected a ListToolsResult, got %+v", response.Result)
+	}
+
+	advertised := make(map[string]bool)
+	for _, tool := range result.Tools {
+		advertised[tool.Name] = true
+		if tool.Description == "" || tool.InputSchema["type"] != "object" {
+			t.Errorf("Expected %s to have a description and an object schema", tool.Name)
+		}
+	}
+
+	if len(result.Tools) != len(ListTools()["tools"].([]map[string]interface{})) {
+		t.Errorf("Expected tools/list to advertise every tool from ListTools, got %d", len(result.Tools))
+	}
+
+	for name := range toolHandlers {
+		if !advertised[name] {
+			t.Errorf("Tool %s is dispatched but not advertised in tools/list", name)
+		}
+	}
+	for name := range advertised {
+		if _, ok := toolHandlers[name]; !ok {
+			t.Errorf("Tool %s is advertised but not dispatched", name)
+		}
+	}
+}
+
+func TestDefaultRepo(t *testing.T) {
+	_, repoPath := createTestManager(t)
+	otherRepoPath := filepath.Join(t.TempDir(), "other-repo")
+	if err := os.MkdirAll(otherRepoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	runGit(t, otherRepoPath, "init", "-q")
+
+	response := handleToolsList(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}, repoPath)
+	for _, tool := range response.Result.(ListToolsResult).Tools {
+		for _, name := range tool.InputSchema["required"].([]string) {
+			if name == "repo_path" {
+				t.Errorf("Expected repo_path to be optional for %s with a default repo", tool.Name)
+			}
+		}
+	}
+
+	for arguments, want := range map[string]string{
+		`{}`:                                     repoPath,
+		`{"repo_path": "` + otherRepoPath + `"}`: otherRepoPath,
+	} {
+		var args map[string]interface{}
+		_ = json.Unmarshal([]byte(arguments), &args)
+		params := map[string]interface{}{"name": "list_notes", "arguments": args}
+
+		response := handleToolsCall(JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: params}, nil, repoPath)
+		result, ok := response.Result.(CallToolResult)
+		if !ok || result.IsError {
+			t.Fatalf("%s: expected a successful tool result, got %+v", arguments, response)
+		}
+
+		var listed struct {
+			RepoPath string `json:"repo_path"`
+		}
+		if err := json.Unmarshal([]byte(result.Content[0].Text), &listed); err != nil {
+			t.Fatalf("Failed to decode result: %v", err)
+		}
+		if listed.RepoPath != want {
+			t.Errorf("%s: expected repo_path %s, got %s", arguments, want, listed.RepoPath)
+		}
+	}
+}
+
+func TestToolErrorCodes(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	comment, err := manager.AddComment(repoPath, "main", "abc123", "file.go", nil, nil, "", "Test comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		code string
+	}{
+		{"missing repo_path", func() error {
+			_, err := ListCommentsWithManager(json.RawMessage(`{}`), manager)
+			return err
+		}, ErrorCodeInvalidParams},
+		{"not a repository", func() error {
+			paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: t.TempDir()})
+			_, err := ListCommentsWithManager(paramsJSON, manager)
+			if !errors.Is(err, ErrNotRepository) {
+				t.Errorf("Expected ErrNotRepository for a plain directory, got %v", err)
+			}
+			return err
+		}, ErrorCodeInvalidParams},
+		{"invalid sort", func() error {
+			paramsJSON, _ := json.Marshal(ListNotesParams{RepoPath: repoPath, Sort: "random"})
+			_, err := ListNotesWithManager(paramsJSON, manager)
+			return err
+		}, ErrorCodeInvalidParams},
+		{"unknown comment", func() error {
+			paramsJSON, _ := json.Marshal(DeleteCommentParams{RepoPath: repoPath, CommentID: "missing"})
+			_, err := DeleteCommentWithManager(paramsJSON, manager)
+			return err
+		}, ErrorCodeNotFound},
+		{"unknown note", func() error {
+			paramsJSON, _ := json.Marshal(DeleteNoteParams{RepoPath: repoPath, NoteID: "missing"})
+			_, err := DeleteNoteWithManager(paramsJSON, manager)
+			return err
+		}, ErrorCodeNotFound},
+		{"comment not resolved", func() error {
+			paramsJSON, _ := json.Marshal(UnresolveCommentParams{RepoPath: repoPath, CommentID: comment.ID})
+			_, err := UnresolveCommentWithManager(paramsJSON, manager)
+			return err
+		}, ErrorCodeConflict},
+	}
+
+	for _, tt := range tests {
+		err := tt.call()
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		if code := ErrorCode(err); code != tt.code {
+			t.Errorf("%s: expected code %s, got %s (%v)", tt.name, tt.code, code, err)
+		}
+	}
+
+	if code := ErrorCode(fmt.Errorf("disk full")); code != ErrorCodeInternal {
+		t.Errorf("Expected unknown errors to be internal, got %s", code)
+	}
+
+	params := map[string]interface{}{
+		"name":      "resolve_comment",
+		"arguments": map[string]interface{}{"repo_path": repoPath, "resolved_by": "claude"},
+	}
+	var out bytes.Buffer
+	response := handleToolsCall(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: params}, newNotifier(newSession(&out), params), "")
+	result, ok := response.Result.(CallToolResult)
+	if !ok || !result.IsError {
+		t.Fatalf("Expected a failed tool result, got %+v", response)
+	}
+	if toolErr, ok := result.StructuredContent.(ToolError); !ok || toolErr.Code != ErrorCodeInvalidParams {
+		t.Errorf("Expected invalid_params structured content, got %+v", result.StructuredContent)
+	}
+}
+
+func TestAddCommentWithManager_ResolveExisting(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	lineNumber := 12
+	otherLine := 13
+	old, _ := manager.AddComment(repoPath, "main", "abc123", "file.go", &lineNumber, nil, "", "Missing error check", "", "claude", "", "", nil)
+	otherAuthor, _ := manager.AddComment(repoPath, "main", "abc123", "file.go", &lineNumber, nil, "", "Agreed", "", "alice", "", "", nil)
+	otherLineComment, _ := manager.AddComment(repoPath, "main", "abc123", "file.go", &otherLine, nil, "", "Rename this", "", "claude", "", "", nil)
+
+	params := AddCommentParams{
+		RepoPath:        repoPath,
+		Branch:          "main",
+		Commit:          "abc123",
+		FilePath:        "file.go",
+		LineNumber:      &lineNumber,
+		Text:            "Error is still ignored",
+		Author:          "claude",
+		ResolveExisting: true,
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	result, err := AddCommentWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("AddCommentWithManager failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	resolvedIDs := resultMap["resolved_comment_ids"].([]string)
+	if len(resolvedIDs) != 1 || resolvedIDs[0] != old.ID {
+		t.Errorf("Expected only %s to be resolved, got %v", old.ID, resolvedIDs)
+	}
+	if resultMap["comment_id"] == "" || resultMap["comment_id"] == old.ID {
+		t.Errorf("Expected a new comment ID, got %v", resultMap["comment_id"])
+	}
+
+	for _, comment := range manager.GetComments(repoPath, "main", "abc123", nil) {
+		switch comment.ID {
+		case resultMap["comment_id"]:
+			if comment.Resolved {
+				t.Error("Expected the new comment to be unresolved")
+			}
+		case old.ID:
+			if !comment.Resolved || comment.ResolvedBy != "claude" {
+				t.Errorf("Expected the previous comment to be resolved by claude, got %+v", comment)
+			}
+		case otherAuthor.ID, otherLineComment.ID:
+			if comment.Resolved {
+				t.Errorf("Expected %q to stay unresolved", comment.Text)
+			}
+		}
+	}
+}
+
+func TestListCommentsWithManager_Pagination(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for _, commit := range []string{"abc123", "def456"} {
+		for i := 0; i < 3; i++ {
+			if _, err := manager.AddComment(repoPath, "main", commit, "main.go", nil, nil, "", fmt.Sprintf("%s #%d", commit, i), "", "claude", "", "", nil); err != nil {
+				t.Fatalf("Failed to add comment: %v", err)
+			}
+		}
+	}
+
+	list := func(offset, limit int) map[string]interface{} {
+		t.Helper()
+		paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, Offset: offset, Limit: limit})
+		result, err := ListCommentsWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListCommentsWithManager failed: %v", err)
+		}
+		return result.(map[string]interface{})
+	}
+
+	seen := map[string]bool{}
+	for offset := 0; offset < 6; offset += 4 {
+		page := list(offset, 4)
+		if page["total"] != 6 {
+			t.Errorf("Expected total 6, got %v", page["total"])
+		}
+		for _, comment := range page["comments"].([]CommentResult) {
+			if seen[comment.ID+comment.Commit] {
+				t.Errorf("Comment %q returned on more than one page", comment.Text)
+			}
+			seen[comment.ID+comment.Commit] = true
+		}
+	}
+	if len(seen) != 6 {
+		t.Errorf("Expected pages to cover all 6 comments, got %d", len(seen))
+	}
+
+	if page := list(4, 4); page["count"] != 2 {
+		t.Errorf("Expected a short last page of 2, got %v", page["count"])
+	}
+	if page := list(10, 0); page["count"] != 0 || page["total"] != 6 {
+		t.Errorf("Expected an empty page past the end, got %v of %v", page["count"], page["total"])
+	}
+	if page := list(0, 0); page["count"] != 6 {
+		t.Errorf("Expected limit 0 to return everything, got %v", page["count"])
+	}
+
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, Limit: -1})
+	if _, err := ListCommentsWithManager(paramsJSON, manager); err == nil {
+		t.Error("Expected error for a negative limit")
+	}
+}
+
+func TestListNotesWithManager_Pagination(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	for i := 0; i < 5; i++ {
+		if _, err := manager.AddNote(repoPath, "main", "abc123", "main.go", nil, fmt.Sprintf("Note %d", i), "claude", "explanation", nil); err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+	}
+
+	paramsJSON, _ := json.Marshal(ListNotesParams{RepoPath: repoPath, Sort: SortOldest, Offset: 1, Limit: 2})
+	result, err := ListNotesWithManager(paramsJSON, manager)
+	if err != nil {
+		t.Fatalf("ListNotesWithManager failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	notes := resultMap["notes"].([]NoteResult)
+	if resultMap["total"] != 5 || len(notes) != 2 {
+		t.Fatalf("Expected 2 of 5 notes, got %d of %v", len(notes), resultMap["total"])
+	}
+	if notes[0].Text != "Note 1" || notes[1].Text != "Note 2" {
+		t.Errorf("Expected notes 1 and 2, got %q and %q", notes[0].Text, notes[1].Text)
+	}
+}
+
+func TestListCommentsWithManager_Sort(t *testing.T) {
+	manager, repoPath := createTestManager(t)
+
+	line := func(n int) *int { return &n }
+	added := []struct {
+		file      string
+		line      *int
+		timestamp int64
+	}{
+		{"b.go", line(3), 100},
+		{"a.go", line(10), 300},
+		{"a.go", nil, 200},
+		{"a.go", line(2), 400},
+	}
+	for _, c := range added {
+		comment, err := manager.AddComment(repoPath, "main", "abc123", c.file, c.line, nil, "", "text", "", "claude", "", "", nil)
+		if err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		comment.Timestamp = c.timestamp
+	}
+
+	list := func(order string) []CommentResult {
+		t.Helper()
+		paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, Sort: order})
+		result, err := ListCommentsWithManager(paramsJSON, manager)
+		if err != nil {
+			t.Fatalf("ListCommentsWithManager(%q) failed: %v", order, err)
+		}
+		return result.(map[string]interface{})["comments"].([]CommentResult)
+	}
+
+	tests := []struct {
+		order string
+		want  []int64
+	}{
+		{"", []int64{400, 300, 200, 100}},
+		{SortNewest, []int64{400, 300, 200, 100}},
+		{SortOldest, []int64{100, 200, 300, 400}},
+		{SortFile, []int64{200, 400, 300, 100}},
+	}
+	for _, tt := range tests {
+		comments := list(tt.order)
+		got := make([]int64, len(comments))
+		for i, comment := range comments {
+			got[i] = comment.Timestamp
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Sort %q: expected timestamps %v, got %v", tt.order, tt.want, got)
+		}
+	}
+
+	paramsJSON, _ := json.Marshal(ListCommentsParams{RepoPath: repoPath, Sort: "alphabetical"})
+	if _, err := ListCommentsWithManager(paramsJSON, manager); err == nil {
+		t.Error("Expected error for an unknown sort order")
+	}
+}
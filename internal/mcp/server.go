@@ -6,6 +6,8 @@ import (
 	"io"
 	"log"
 	"os"
+
+	"github.com/tuist/guck/internal/version"
 )
 
 // JSON-RPC 2.0 message types
@@ -42,12 +44,127 @@ type ServerInfo struct {
 }
 
 type Capabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools   *ToolsCapability   `json:"tools,omitempty"`
+	Logging *LoggingCapability `json:"logging,omitempty"`
 }
 
 type ToolsCapability struct {
 }
 
+type LoggingCapability struct {
+}
+
+// Protocol revisions the server can negotiate, newest first
+const (
+	protocolVersion20250326 = "2025-03-26"
+	protocolVersion20241105 = "2024-11-05"
+)
+
+var supportedProtocolVersions = []string{protocolVersion20250326, protocolVersion20241105}
+
+// logLevels orders the syslog severities used by MCP logging
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// session holds what was negotiated with the client during initialize, and
+// writes every message to stdout through a single encoder so that stdout
+// only ever carries JSON-RPC
+type session struct {
+	encoder         *json.Encoder
+	protocolVersion string
+	logLevel        string
+}
+
+func newSession(w io.Writer) *session {
+	return &session{
+		encoder:  json.NewEncoder(w),
+		logLevel: "info",
+	}
+}
+
+// notify sends a JSON-RPC notification, which carries no ID
+func (s *session) notify(method string, params interface{}) {
+	if err := s.encoder.Encode(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		log.Printf("Error encoding notification: %v", err)
+	}
+}
+
+// notifier sends logging and progress notifications for a single request.
+// A nil notifier, or one whose session has not negotiated a protocol
+// version, sends nothing.
+type notifier struct {
+	session       *session
+	progressToken interface{}
+}
+
+func newNotifier(s *session, params interface{}) *notifier {
+	n := &notifier{session: s}
+	if paramsMap, ok := params.(map[string]interface{}); ok {
+		if meta, ok := paramsMap["_meta"].(map[string]interface{}); ok {
+			n.progressToken = meta["progressToken"]
+		}
+	}
+	return n
+}
+
+func (n *notifier) active() bool {
+	return n != nil && n.session != nil && n.session.protocolVersion != ""
+}
+
+// progress sends notifications/progress when the client asked for it by
+// passing a progress token; total is omitted when 0
+func (n *notifier) progress(progress, total int, message string) {
+	if !n.active() || n.progressToken == nil {
+		return
+	}
+
+	params := map[string]interface{}{
+		"progressToken": n.progressToken,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	// Progress messages were added in the 2025-03-26 revision
+	if message != "" && n.session.protocolVersion >= protocolVersion20250326 {
+		params["message"] = message
+	}
+	n.session.notify("notifications/progress", params)
+}
+
+// log sends notifications/message when level is at or above the level the
+// client set with logging/setLevel
+func (n *notifier) log(level, message string) {
+	if !n.active() || logLevelIndex(level) < logLevelIndex(n.session.logLevel) {
+		return
+	}
+
+	n.session.notify("notifications/message", map[string]interface{}{
+		"level":  level,
+		"logger": "guck",
+		"data":   message,
+	})
+}
+
+func logLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// negotiateProtocolVersion echoes the client's requested revision when the
+// server supports it, and otherwise offers the newest one it does
+func negotiateProtocolVersion(requested string) string {
+	for _, version := range supportedProtocolVersions {
+		if version == requested {
+			return version
+		}
+	}
+	return supportedProtocolVersions[0]
+}
+
 type ListToolsResult struct {
 	Tools []Tool `json:"tools"`
 }
@@ -60,7 +177,9 @@ type Tool struct {
 
 type CallToolResult struct {
 	Content []ToolContent `json:"content"`
-	IsError bool          `json:"isError,omitempty"`
+	// StructuredContent carries a ToolError when IsError is set
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
 }
 
 type ToolContent struct {
@@ -68,16 +187,21 @@ type ToolContent struct {
 	Text string `json:"text"`
 }
 
-// StartStdioServer starts the MCP server using stdio transport
-func StartStdioServer() error {
+// StartStdioServer starts the MCP server using stdio transport. Tool calls
+// that omit repo_path use defaultRepo, unless it is empty; an explicit
+// repo_path always wins.
+func StartStdioServer(defaultRepo string) error {
 	// Configure logging to stderr (stdout is reserved for JSON-RPC)
 	log.SetOutput(os.Stderr)
 	log.SetPrefix("[guck-mcp] ")
 
 	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	sess := newSession(os.Stdout)
 
 	log.Println("MCP server started")
+	if defaultRepo != "" {
+		log.Printf("Default repository: %s", defaultRepo)
+	}
 
 	for {
 		var request JSONRPCRequest
@@ -96,17 +220,20 @@ func StartStdioServer() error {
 
 		switch request.Method {
 		case "initialize":
-			response = handleInitialize(request)
+			response = handleInitialize(request, sess)
 
 		case "notifications/initialized", "initialized":
 			log.Println("Server initialized successfully")
 			continue // Notifications don't need responses
 
 		case "tools/list":
-			response = handleToolsList(request)
+			response = handleToolsList(request, defaultRepo)
 
 		case "tools/call":
-			response = handleToolsCall(request)
+			response = handleToolsCall(request, newNotifier(sess, request.Params), defaultRepo)
+
+		case "logging/setLevel":
+			response = handleSetLevel(request, sess)
 
 		default:
 			response = &JSONRPCResponse{
@@ -120,83 +247,72 @@ func StartStdioServer() error {
 		}
 
 		if response != nil {
-			if err := encoder.Encode(response); err != nil {
+			if err := sess.encoder.Encode(response); err != nil {
 				log.Printf("Error encoding response: %v", err)
 			}
 		}
 	}
 }
 
-func handleInitialize(request JSONRPCRequest) *JSONRPCResponse {
+func handleInitialize(request JSONRPCRequest, sess *session) *JSONRPCResponse {
+	requested := ""
+	if params, ok := request.Params.(map[string]interface{}); ok {
+		requested, _ = params["protocolVersion"].(string)
+	}
+	sess.protocolVersion = negotiateProtocolVersion(requested)
+
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      request.ID,
 		Result: InitializeResult{
-			ProtocolVersion: "2024-11-05",
+			ProtocolVersion: sess.protocolVersion,
 			ServerInfo: ServerInfo{
 				Name:    "guck",
-				Version: "0.5.0",
+				Version: version.String(),
 			},
 			Capabilities: Capabilities{
-				Tools: &ToolsCapability{},
+				Tools:   &ToolsCapability{},
+				Logging: &LoggingCapability{},
 			},
 		},
 	}
 }
 
-func handleToolsList(request JSONRPCRequest) *JSONRPCResponse {
-	tools := []Tool{
-		{
-			Name:        "list_comments",
-			Description: "List all code review comments for a repository with optional filtering by branch, commit, file path, or resolution status",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"repo_path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the Git repository (defaults to current working directory)",
-					},
-					"branch": map[string]interface{}{
-						"type":        "string",
-						"description": "Filter comments by branch name",
-					},
-					"commit": map[string]interface{}{
-						"type":        "string",
-						"description": "Filter comments by commit hash",
-					},
-					"file_path": map[string]interface{}{
-						"type":        "string",
-						"description": "Filter comments by file path",
-					},
-					"resolved": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Filter comments by resolution status (true for resolved, false for unresolved)",
-					},
-				},
-			},
-		},
-		{
-			Name:        "resolve_comment",
-			Description: "Mark a code review comment as resolved, recording who resolved it and when",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"comment_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the comment to resolve",
-					},
-					"resolved_by": map[string]interface{}{
-						"type":        "string",
-						"description": "Identifier of who/what is resolving the comment (e.g., 'claude', 'copilot', user name)",
-					},
-					"repo_path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the Git repository (defaults to current working directory)",
-					},
-				},
-				"required": []string{"comment_id", "resolved_by"},
+func handleSetLevel(request JSONRPCRequest, sess *session) *JSONRPCResponse {
+	params, _ := request.Params.(map[string]interface{})
+	level, _ := params["level"].(string)
+	if logLevelIndex(level) < 0 {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid log level: %s", level),
 			},
-		},
+		}
+	}
+
+	sess.logLevel = level
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  map[string]interface{}{},
+	}
+}
+
+// handleToolsList advertises the tools defined by ListTools. With a default
+// repository, repo_path is advertised as optional.
+func handleToolsList(request JSONRPCRequest, defaultRepo string) *JSONRPCResponse {
+	var tools []Tool
+	for _, tool := range ListTools()["tools"].([]map[string]interface{}) {
+		if defaultRepo != "" {
+			makeRepoPathOptional(tool["inputSchema"].(map[string]interface{}), defaultRepo)
+		}
+		tools = append(tools, Tool{
+			Name:        tool["name"].(string),
+			Description: tool["description"].(string),
+			InputSchema: tool["inputSchema"].(map[string]interface{}),
+		})
 	}
 
 	return &JSONRPCResponse{
@@ -208,7 +324,60 @@ func handleToolsList(request JSONRPCRequest) *JSONRPCResponse {
 	}
 }
 
-func handleToolsCall(request JSONRPCRequest) *JSONRPCResponse {
+// makeRepoPathOptional drops repo_path from a tool schema's required
+// parameters and says what it defaults to
+func makeRepoPathOptional(schema map[string]interface{}, defaultRepo string) {
+	required, _ := schema["required"].([]string)
+	optional := []string{}
+	for _, name := range required {
+		if name != "repo_path" {
+			optional = append(optional, name)
+		}
+	}
+	schema["required"] = optional
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	if repoPath, ok := properties["repo_path"].(map[string]interface{}); ok {
+		repoPath["description"] = fmt.Sprintf("Optional: Absolute path to the git repository (defaults to %s)", defaultRepo)
+	}
+}
+
+// toolHandler runs a tool with its JSON arguments, reporting progress and
+// logs through notify
+type toolHandler func(args json.RawMessage, notify *notifier) (interface{}, error)
+
+// withoutNotifier adapts a tool that reports nothing while it runs
+func withoutNotifier(tool func(json.RawMessage) (interface{}, error)) toolHandler {
+	return func(args json.RawMessage, _ *notifier) (interface{}, error) {
+		return tool(args)
+	}
+}
+
+// toolHandlers dispatches tools/call by tool name. Every tool here must be
+// advertised by ListTools.
+var toolHandlers = map[string]toolHandler{
+	"list_comments":     withoutNotifier(ListComments),
+	"resolve_comment":   withoutNotifier(ResolveComment),
+	"unresolve_comment": withoutNotifier(UnresolveComment),
+	"add_comment":       withoutNotifier(AddComment),
+	"delete_comment":    withoutNotifier(DeleteComment),
+	"add_note":          withoutNotifier(AddNote),
+	"list_notes":        withoutNotifier(ListNotes),
+	"notes_summary":     withoutNotifier(NotesSummary),
+	"dismiss_note":      withoutNotifier(DismissNote),
+	"restore_note":      withoutNotifier(RestoreNote),
+	"delete_note":       withoutNotifier(DeleteNote),
+	"list_viewed":       withoutNotifier(ListViewed),
+	"get_diff": func(args json.RawMessage, notify *notifier) (interface{}, error) {
+		return GetDiffWithProgress(args, notify.progress)
+	},
+	"mark_viewed":   withoutNotifier(MarkViewed),
+	"unmark_viewed": withoutNotifier(UnmarkViewed),
+}
+
+// handleToolsCall runs a tool, filling in repo_path from defaultRepo when
+// the call leaves it out
+func handleToolsCall(request JSONRPCRequest, notify *notifier, defaultRepo string) *JSONRPCResponse {
 	params, ok := request.Params.(map[string]interface{})
 	if !ok {
 		return &JSONRPCResponse{
@@ -237,6 +406,9 @@ func handleToolsCall(request JSONRPCRequest) *JSONRPCResponse {
 	if !ok {
 		arguments = make(map[string]interface{})
 	}
+	if repoPath, _ := arguments["repo_path"].(string); repoPath == "" && defaultRepo != "" {
+		arguments["repo_path"] = defaultRepo
+	}
 
 	// Convert arguments to JSON for existing functions
 	argsJSON, err := json.Marshal(arguments)
@@ -251,26 +423,8 @@ func handleToolsCall(request JSONRPCRequest) *JSONRPCResponse {
 		}
 	}
 
-	var result interface{}
-	var toolErr error
-
-	switch toolName {
-	case "list_comments":
-		result, toolErr = ListComments(json.RawMessage(argsJSON))
-
-	case "resolve_comment":
-		result, toolErr = ResolveComment(json.RawMessage(argsJSON))
-
-	case "add_note":
-		result, toolErr = AddNote(json.RawMessage(argsJSON))
-
-	case "list_notes":
-		result, toolErr = ListNotes(json.RawMessage(argsJSON))
-
-	case "dismiss_note":
-		result, toolErr = DismissNote(json.RawMessage(argsJSON))
-
-	default:
+	handler, ok := toolHandlers[toolName]
+	if !ok {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
@@ -281,7 +435,9 @@ func handleToolsCall(request JSONRPCRequest) *JSONRPCResponse {
 		}
 	}
 
+	result, toolErr := handler(json.RawMessage(argsJSON), notify)
 	if toolErr != nil {
+		notify.log("error", fmt.Sprintf("%s failed: %v", toolName, toolErr))
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
@@ -292,6 +448,10 @@ func handleToolsCall(request JSONRPCRequest) *JSONRPCResponse {
 						Text: fmt.Sprintf("Error: %v", toolErr),
 					},
 				},
+				StructuredContent: ToolError{
+					Code:    ErrorCode(toolErr),
+					Message: toolErr.Error(),
+				},
 				IsError: true,
 			},
 		}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight response
+const corsMaxAge = "600"
+
+// cors lets pages from the configured origins, such as an editor extension's
+// webview, call the API. Requests from other origins get no CORS headers, so
+// browsers keep blocking them. Preflight requests are answered by the
+// method-not-allowed handler, which this also wraps.
+func (s *AppState) cors(router *mux.Router) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !s.allowsOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods(router, r), ", "))
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowsOrigin reports whether origin is one of the configured CORS origins;
+// "*" allows any
+func (s *AppState) allowsOrigin(origin string) bool {
+	return slices.Contains(s.CORSOrigins, origin) || slices.Contains(s.CORSOrigins, "*")
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+const (
+	// eventDebounce coalesces bursts of filesystem events (e.g. a checkout)
+	// into a single diff-changed event
+	eventDebounce = 250 * time.Millisecond
+	// eventHeartbeat keeps idle connections from being closed by proxies
+	eventHeartbeat = 30 * time.Second
+)
+
+// eventsHandler streams a "diff-changed" Server-Sent Event whenever the
+// working tree, the index or HEAD changes. Clients share one watcher, see
+// eventHub.
+func (s *AppState) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := s.events.subscribe(s.RepoPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer s.events.unsubscribe(changes)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	_, _ = fmt.Fprint(w, ": connected\n\n") // Ignore write error for HTTP response
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changes:
+			if _, err := fmt.Fprint(w, "event: diff-changed\ndata: {}\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// eventHub fans a single repoWatcher out to every events client, so that
+// opening more tabs doesn't walk the worktree and add its watches again. The
+// watcher is created for the first subscriber and closed with the last one.
+type eventHub struct {
+	mu          sync.Mutex
+	watcher     *repoWatcher
+	subscribers map[chan struct{}]struct{}
+}
+
+// subscribe returns a channel that receives a value after each debounced
+// burst of changes. Pass it to unsubscribe when done.
+func (h *eventHub) subscribe(repoPath string) (chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watcher == nil {
+		watcher, err := newRepoWatcher(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		h.watcher = watcher
+		h.subscribers = map[chan struct{}]struct{}{}
+		go h.run(watcher)
+	}
+
+	// Buffered so a change is kept for a client busy writing the previous one
+	changes := make(chan struct{}, 1)
+	h.subscribers[changes] = struct{}{}
+	return changes, nil
+}
+
+func (h *eventHub) unsubscribe(changes chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, changes)
+	if len(h.subscribers) == 0 && h.watcher != nil {
+		h.watcher.Close()
+		h.watcher = nil
+	}
+}
+
+// run debounces watcher's events and notifies the subscribers, until the
+// watcher is closed
+func (h *eventHub) run(watcher *repoWatcher) {
+	debounce := time.NewTimer(eventDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if watcher.handle(event) {
+				debounce.Reset(eventDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("Warning: file watcher error: %v\n", err)
+		case <-debounce.C:
+			h.notify()
+		}
+	}
+}
+
+func (h *eventHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for changes := range h.subscribers {
+		select {
+		case changes <- struct{}{}:
+		default:
+			// The client already has a change pending
+		}
+	}
+}
+
+// repoWatcher watches a repository's worktree plus the HEAD and index files
+type repoWatcher struct {
+	*fsnotify.Watcher
+	repoPath string
+	gitDir   string
+	ignore   gitignore.Matcher
+}
+
+func newRepoWatcher(repoPath string) (*repoWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	rw := &repoWatcher{
+		Watcher:  watcher,
+		repoPath: repoPath,
+		gitDir:   filepath.Join(repoPath, ".git"),
+		ignore:   gitignore.NewMatcher(readIgnorePatterns(repoPath)),
+	}
+
+	// HEAD and index live directly in the git directory
+	if info, err := os.Stat(rw.gitDir); err == nil && info.IsDir() {
+		if err := watcher.Add(rw.gitDir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch git directory: %w", err)
+		}
+	}
+
+	if err := rw.addTree(repoPath); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return rw, nil
+}
+
+// addTree watches dir and all of its subdirectories that are not ignored
+func (rw *repoWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear while walking; skip them
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path == rw.gitDir || (path != rw.repoPath && rw.isIgnored(path, true)) {
+			return filepath.SkipDir
+		}
+		if err := rw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// handle starts watching newly created directories and reports whether the
+// event may affect the diff
+func (rw *repoWatcher) handle(event fsnotify.Event) bool {
+	if filepath.Dir(event.Name) == rw.gitDir {
+		name := filepath.Base(event.Name)
+		return name == "HEAD" || name == "index"
+	}
+
+	if filepath.Base(event.Name) == ".gitignore" {
+		rw.ignore = gitignore.NewMatcher(readIgnorePatterns(rw.repoPath))
+	}
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if rw.isIgnored(event.Name, true) {
+				return false
+			}
+			_ = rw.addTree(event.Name) // Best effort, the diff still refreshes
+		}
+	}
+
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	return !rw.isIgnored(event.Name, false)
+}
+
+func (rw *repoWatcher) isIgnored(path string, isDir bool) bool {
+	rel, err := filepath.Rel(rw.repoPath, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rw.ignore.Match(strings.Split(filepath.ToSlash(rel), "/"), isDir)
+}
+
+// readIgnorePatterns loads the patterns of every .gitignore in the worktree,
+// scoped to their directories, and of .git/info/exclude
+func readIgnorePatterns(repoPath string) []gitignore.Pattern {
+	patterns, _ := gitignore.ReadPatterns(osfs.New(repoPath), nil) // Unreadable directories just contribute no patterns
+	return patterns
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// idleCheckInterval caps how often an idle timeout is checked
+const idleCheckInterval = time.Minute
+
+// trackActivity records when requests start and finish so the server can tell
+// how long it has gone unused. Open requests, such as an event stream from a
+// browser tab, keep it busy.
+func (s *AppState) trackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.activityMu.Lock()
+		s.activeRequests++
+		s.lastRequest = time.Now()
+		s.activityMu.Unlock()
+
+		defer func() {
+			s.activityMu.Lock()
+			s.activeRequests--
+			s.lastRequest = time.Now()
+			s.activityMu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// idleFor reports how long the server has had no requests, or 0 while one is
+// in progress
+func (s *AppState) idleFor() time.Duration {
+	s.activityMu.Lock()
+	defer s.activityMu.Unlock()
+
+	if s.activeRequests > 0 {
+		return 0
+	}
+	return time.Since(s.lastRequest)
+}
+
+// waitForIdle blocks until the server has gone timeout without requests,
+// counting from the call if none has been served yet
+func (s *AppState) waitForIdle(timeout time.Duration) {
+	s.activityMu.Lock()
+	if s.lastRequest.IsZero() {
+		s.lastRequest = time.Now()
+	}
+	s.activityMu.Unlock()
+
+	ticker := time.NewTicker(min(timeout/4, idleCheckInterval))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.idleFor() >= timeout {
+			return
+		}
+	}
+}
@@ -1,34 +1,118 @@
 package server
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/tuist/guck/internal/author"
+	"github.com/tuist/guck/internal/config"
 	"github.com/tuist/guck/internal/git"
 	"github.com/tuist/guck/internal/state"
+	"github.com/tuist/guck/internal/version"
 )
 
 //go:embed static/index.html
 var indexHTML string
 
 type AppState struct {
-	RepoPath     string
-	BaseBranch   string
+	RepoPath          string
+	BaseBranch        string
+	RefreshIntervalMs int
+	AutoFetch         bool
+	IgnoreWhitespace  bool
+	// GeneratedPatterns flag matching files as generated in diffs
+	GeneratedPatterns []string
+	// CORSOrigins are the origins allowed to call the API from a browser
+	CORSOrigins []string
+	// MaxPatchBytes truncates longer patches in diffs; 0 means no limit
+	MaxPatchBytes int
+	// MaxPatchLines truncates patches with more lines in diffs; 0 means no limit
+	MaxPatchLines int
+	// Reviewer is who the web UI records on the comments it adds, resolves
+	// and the notes it dismisses
+	Reviewer string
+	// DiffMode is what the branch diff compares against unless ?mode= says otherwise
+	DiffMode     git.DiffMode
+	DefaultView  string
 	StateManager *state.Manager
 	mu           sync.Mutex
+
+	// fetchMu guards lastFetch. Fetches run without holding mu, so a slow
+	// or offline remote doesn't hold up other requests.
+	fetchMu   sync.Mutex
+	lastFetch time.Time
+
+	// activityMu guards the request tracking used by the idle timeout. It is
+	// separate from mu, which handlers hold while computing diffs.
+	activityMu     sync.Mutex
+	lastRequest    time.Time
+	activeRequests int
+
+	// events shares one repository watcher between /api/events clients
+	events eventHub
+}
+
+// Diff views served by /api/diff
+const (
+	// ViewFull compares HEAD against the base branch and lists uncommitted changes
+	ViewFull = "full"
+	// ViewStaged shows only the changes staged for the next commit
+	ViewStaged = "staged"
+)
+
+// Options configures the server started by Start
+type Options struct {
+	// AutoFetch fetches origin before computing diffs
+	AutoFetch bool
+	// Staged makes the staged view the default for /api/diff
+	Staged bool
+	// DiffMode is the default comparison for /api/diff; empty means merge-base
+	DiffMode git.DiffMode
+	// IdleTimeout shuts the server down once it has gone this long without
+	// requests; 0 keeps it running
+	IdleTimeout time.Duration
 }
 
+// uncommittedCommit is the commit identifier viewed state of uncommitted
+// changes is recorded under, keyed by path and staging status
+const uncommittedCommit = state.UncommittedCommit
+
+// shutdownTimeout bounds how long a shutdown waits for requests to finish
+const shutdownTimeout = 5 * time.Second
+
+// fetchInterval limits how often the remote is fetched when auto-fetch is enabled
+const fetchInterval = time.Minute
+
 type DiffResponse struct {
 	Files            []FileDiff `json:"files"`
 	UncommittedFiles []FileDiff `json:"uncommitted_files,omitempty"`
+	View             string     `json:"view"`
 	Branch           string     `json:"branch"`
 	Commit           string     `json:"commit"`
 	RepoPath         string     `json:"repo_path"`
 	RemoteURL        string     `json:"remote_url,omitempty"`
+	// RemoteName is the remote RemoteURL belongs to: origin, or the first
+	// remote by name when there is no origin
+	RemoteName string `json:"remote_name,omitempty"`
+	// Mode and BaseCommit describe what the full view's files were compared
+	// against: the merge base in merge-base mode, the base's commit in direct mode
+	Mode       git.DiffMode `json:"mode,omitempty"`
+	BaseCommit string       `json:"base_commit,omitempty"`
+	// Commits are the commits between BaseCommit and Commit, newest first
+	Commits []git.CommitInfo `json:"commits,omitempty"`
 }
 
 type FileDiff struct {
@@ -39,16 +123,52 @@ type FileDiff struct {
 	Patch         string `json:"patch"`
 	Viewed        bool   `json:"viewed"`
 	StagingStatus string `json:"staging_status,omitempty"`
+	FromPath      string `json:"from_path,omitempty"`
+	Similarity    int    `json:"similarity,omitempty"`
+	Binary        bool   `json:"binary,omitempty"`
+	// IsSymlink marks symbolic links, whose patch shows their target
+	IsSymlink bool `json:"symlink,omitempty"`
+	// Language is the highlight.js identifier for the file, empty if unknown
+	Language string `json:"language,omitempty"`
+	// Generated marks lockfiles, vendored and generated files
+	Generated bool `json:"generated,omitempty"`
+	// WordDiffs marks the changed words of modified lines, with ?word_diff=true
+	WordDiffs []git.WordDiff `json:"word_diffs,omitempty"`
+	// WebURL links to the committed file on the remote's web host
+	WebURL string `json:"web_url,omitempty"`
+	// Truncated is set when Patch was cut short at the max-patch-lines or
+	// max-patch-bytes limit; /api/diff/file/full serves the whole patch
+	Truncated bool `json:"truncated,omitempty"`
+	// SubmoduleFrom and SubmoduleTo are the commits a submodule moved
+	// between, for files with status "submodule"
+	SubmoduleFrom string `json:"submodule_from,omitempty"`
+	SubmoduleTo   string `json:"submodule_to,omitempty"`
+}
+
+// CommentResponse is a comment with a link to its line on the remote's web host
+type CommentResponse struct {
+	*state.Comment
+	WebURL string `json:"web_url,omitempty"`
 }
 
 type MarkViewedRequest struct {
 	FilePath string `json:"file_path"`
+	// Commit is the commit the file was reviewed at, when reviewing a single
+	// commit with /api/diff?commit=; empty means HEAD
+	Commit string `json:"commit,omitempty"`
 }
 
 type AddCommentRequest struct {
-	FilePath   string `json:"file_path"`
-	LineNumber *int   `json:"line_number,omitempty"`
-	Text       string `json:"text"`
+	FilePath   string            `json:"file_path"`
+	LineNumber *int              `json:"line_number,omitempty"`
+	EndLine    *int              `json:"end_line,omitempty"`
+	Side       string            `json:"side,omitempty"` // "old" or "new", defaulting to "new"
+	Text       string            `json:"text"`
+	Suggestion string            `json:"suggestion,omitempty"`
+	Author     string            `json:"author,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
 type GetCommentsQuery struct {
@@ -73,12 +193,84 @@ type DismissNoteRequest struct {
 }
 
 type StatusResponse struct {
-	RepoPath string `json:"repo_path"`
-	Branch   string `json:"branch"`
-	Commit   string `json:"commit"`
+	RepoPath          string `json:"repo_path"`
+	Branch            string `json:"branch"`
+	Commit            string `json:"commit"`
+	RefreshIntervalMs int    `json:"refresh_interval_ms"`
+	BaseBranch        string `json:"base_branch"`
+	ReviewCounts
+}
+
+// ReviewCounts summarizes review progress on the current branch and commit.
+// File counts cover the committed and uncommitted changes of the full view.
+type ReviewCounts struct {
+	TotalFiles         int `json:"total_files"`
+	ViewedFiles        int `json:"viewed_files"`
+	TotalComments      int `json:"total_comments"`
+	UnresolvedComments int `json:"unresolved_comments"`
+	TotalNotes         int `json:"total_notes"`
+	ActiveNotes        int `json:"active_notes"`
+}
+
+// DiffDebugResponse reports files whose status differs between go-git and native git
+type DiffDebugResponse struct {
+	File          string                  `json:"file,omitempty"`
+	Discrepancies []git.StatusDiscrepancy `json:"discrepancies"`
 }
 
-func Start(port int, baseBranch string) error {
+// IsLoopbackHost reports whether listening on host keeps the server
+// reachable only from this machine
+func IsLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newAppState loads the configuration and review state of the repository a
+// server is started for. The allowed CORS origins come from the global config
+// only: a .guck.toml committed to a cloned repository must not be able to let
+// other websites read its diff or change its review state.
+func newAppState(repoPath, baseBranch string, opts Options) (*AppState, error) {
+	stateMgr, err := state.NewManagerForRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	globalCfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	appState := &AppState{
+		RepoPath:          repoPath,
+		BaseBranch:        baseBranch,
+		RefreshIntervalMs: cfg.RefreshIntervalMs,
+		AutoFetch:         opts.AutoFetch || cfg.AutoFetch,
+		IgnoreWhitespace:  cfg.IgnoreWhitespace,
+		GeneratedPatterns: cfg.GeneratedPatterns,
+		CORSOrigins:       globalCfg.CORSOrigins,
+		MaxPatchBytes:     cfg.MaxPatchBytes,
+		MaxPatchLines:     cfg.MaxPatchLines,
+		Reviewer:          cfg.Reviewer,
+		DiffMode:          opts.DiffMode,
+		DefaultView:       ViewFull,
+		StateManager:      stateMgr,
+	}
+	if opts.Staged {
+		appState.DefaultView = ViewStaged
+	}
+
+	return appState, nil
+}
+
+func Start(host string, port int, baseBranch string, opts Options) error {
 	gitRepo, err := git.Open(".")
 	if err != nil {
 		return err
@@ -89,119 +281,719 @@ func Start(port int, baseBranch string) error {
 		return err
 	}
 
-	stateMgr, err := state.NewManager()
+	appState, err := newAppState(repoPath, baseBranch, opts)
 	if err != nil {
 		return err
 	}
 
-	appState := &AppState{
-		RepoPath:     repoPath,
-		BaseBranch:   baseBranch,
-		StateManager: stateMgr,
+	r := appState.router()
+
+	if host == "" {
+		host = config.DefaultHost
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	fmt.Printf("Starting guck %s server on http://%s\n", version.String(), addr)
+	if !IsLoopbackHost(host) {
+		fmt.Printf("Warning: listening on %s makes the review UI and its API reachable from other machines\n", host)
+	}
+	fmt.Printf("Comparing against base branch: %s\n", baseBranch)
+	if appState.DiffMode == git.DiffModeDirect {
+		fmt.Println("Diffing directly against the base instead of the merge base")
+	}
+	if appState.AutoFetch {
+		fmt.Println("Auto-fetch enabled: refreshing origin before computing diffs")
+	}
+	if appState.DefaultView == ViewStaged {
+		fmt.Println("Showing staged changes only")
+	}
+
+	// Cancelling the base context ends open event streams, which would
+	// otherwise hold up a shutdown
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	idle := make(chan struct{})
+	if opts.IdleTimeout > 0 {
+		fmt.Printf("Shutting down after %s without requests\n", opts.IdleTimeout)
+		go func() {
+			appState.waitForIdle(opts.IdleTimeout)
+			close(idle)
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-signals.Done():
+		fmt.Println("Received shutdown signal, shutting down")
+	case <-idle:
+		fmt.Printf("No requests for %s, shutting down\n", opts.IdleTimeout)
 	}
 
+	cancelRequests()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
+
+// fetchIfDue refreshes origin when auto-fetch is enabled and the last fetch is
+// older than fetchInterval. Failures (e.g. offline) are logged and the diff
+// falls back to the cached remote ref. Callers must not hold s.mu; requests
+// arriving while a fetch runs use the cached refs rather than wait for it.
+func (s *AppState) fetchIfDue() {
+	if !s.AutoFetch {
+		return
+	}
+
+	s.fetchMu.Lock()
+	if time.Since(s.lastFetch) < fetchInterval {
+		s.fetchMu.Unlock()
+		return
+	}
+	s.lastFetch = time.Now()
+	s.fetchMu.Unlock()
+
+	gitRepo, err := git.Open(".")
+	if err == nil {
+		err = gitRepo.Fetch("origin")
+	}
+	if err != nil {
+		fmt.Printf("Warning: auto-fetch failed, using cached refs: %v\n", err)
+	}
+}
+
+// router registers the HTTP routes served for the app
+func (s *AppState) router() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/", appState.indexHandler).Methods("GET")
-	r.HandleFunc("/api/diff", appState.diffHandler).Methods("GET")
-	r.HandleFunc("/api/mark-viewed", appState.markViewedHandler).Methods("POST")
-	r.HandleFunc("/api/unmark-viewed", appState.unmarkViewedHandler).Methods("POST")
-	r.HandleFunc("/api/status", appState.statusHandler).Methods("GET")
-	r.HandleFunc("/api/comments", appState.getCommentsHandler).Methods("GET")
-	r.HandleFunc("/api/comments", appState.addCommentHandler).Methods("POST")
-	r.HandleFunc("/api/comments/resolve", appState.resolveCommentHandler).Methods("POST")
-	r.HandleFunc("/api/notes", appState.getNotesHandler).Methods("GET")
-	r.HandleFunc("/api/notes", appState.addNoteHandler).Methods("POST")
-	r.HandleFunc("/api/notes/dismiss", appState.dismissNoteHandler).Methods("POST")
-
-	addr := fmt.Sprintf("127.0.0.1:%d", port)
-	fmt.Printf("Starting server on http://%s\n", addr)
-	fmt.Printf("Comparing against base branch: %s\n", baseBranch)
+	r.HandleFunc("/", s.indexHandler).Methods("GET")
+	r.HandleFunc("/api/diff", s.diffHandler).Methods("GET")
+	r.HandleFunc("/api/diff/file", s.diffFileHandler).Methods("GET")
+	r.HandleFunc("/api/diff/file/full", s.diffFullFileHandler).Methods("GET")
+	r.HandleFunc("/api/diff/debug", s.diffDebugHandler).Methods("GET")
+	r.HandleFunc("/api/commits", s.commitsHandler).Methods("GET")
+	r.HandleFunc("/api/mark-viewed", s.markViewedHandler).Methods("POST")
+	r.HandleFunc("/api/unmark-viewed", s.unmarkViewedHandler).Methods("POST")
+	r.HandleFunc("/api/status", s.statusHandler).Methods("GET")
+	r.HandleFunc("/api/health", s.healthHandler).Methods("GET")
+	r.HandleFunc("/api/events", s.eventsHandler).Methods("GET")
+	r.HandleFunc("/api/comments", s.getCommentsHandler).Methods("GET")
+	r.HandleFunc("/api/comments", s.addCommentHandler).Methods("POST")
+	r.HandleFunc("/api/comments/resolve", s.resolveCommentHandler).Methods("POST")
+	r.HandleFunc("/api/comments/unresolve", s.unresolveCommentHandler).Methods("POST")
+	r.HandleFunc("/api/mentions", s.mentionsHandler).Methods("GET")
+	r.HandleFunc("/api/notes", s.getNotesHandler).Methods("GET")
+	r.HandleFunc("/api/notes", s.addNoteHandler).Methods("POST")
+	r.HandleFunc("/api/notes/dismiss", s.dismissNoteHandler).Methods("POST")
+	r.HandleFunc("/api/preferences", s.getPreferencesHandler).Methods("GET")
+	r.HandleFunc("/api/preferences", s.updatePreferencesHandler).Methods("PUT")
+	r.Use(securityHeaders)
+	r.Use(s.cors(r))
+	r.Use(s.trackActivity)
+	r.MethodNotAllowedHandler = securityHeaders(s.cors(r)(methodNotAllowedHandler(r)))
+
+	return r
+}
 
-	return http.ListenAndServe(addr, r)
+// routeMethods are the methods probed when building the Allow header
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// allowedMethods returns the methods registered on the router for the request's path
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := []string{}
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// methodNotAllowedHandler answers OPTIONS requests and reports 405s with an
+// Allow header listing the methods the route supports
+func methodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(router, r)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := map[string]interface{}{
+			"error":   fmt.Sprintf("method %s not allowed", r.Method),
+			"allowed": allowed,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(response) // Ignore encode error for HTTP response
+	})
+}
+
+// contentSecurityPolicy restricts the UI to its own API and the CDNs it loads
+// assets from. Babel transpiles the inline app script in the browser, which
+// requires 'unsafe-inline' and 'unsafe-eval' for scripts.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://unpkg.com https://cdnjs.cloudflare.com; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com https://cdnjs.cloudflare.com; " +
+	"font-src 'self' data: https://unpkg.com; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'none'; " +
+	"form-action 'none'; " +
+	"frame-ancestors 'none'"
+
+// securityHeaders sets headers that apply to every response
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Frame-Options", "DENY")
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *AppState) indexHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 	w.Header().Set("Content-Type", "text/html")
 	_, _ = w.Write([]byte(indexHTML)) // Ignore write error for HTTP response
 }
 
 func (s *AppState) diffHandler(w http.ResponseWriter, r *http.Request) {
+	s.fetchIfDue()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	gitRepo, err := git.Open(".")
+	req, err := s.parseDiffRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stream, err := parseBoolParam(r, "stream")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if stream {
+		s.streamDiff(w, req)
 		return
 	}
 
+	response, err := s.buildDiff(req)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response) // Ignore encode error for HTTP response
+}
+
+// diffErrorStatus is the HTTP status for a failed diff: 409 Conflict when the
+// base shares no history with HEAD, which the UI explains, 404 for an unknown
+// ?commit= and 500 otherwise
+func diffErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, git.ErrNoMergeBase):
+		return http.StatusConflict
+	case errors.Is(err, git.ErrUnknownCommit):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// diffFileHandler responds with a single file of the diff /api/diff would
+// return for the same parameters, computing only that file's patch. A file
+// with changes at several stages is picked with ?staging_status=; otherwise
+// its committed changes come first, or its staged ones in the staged view.
+func (s *AppState) diffFileHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveDiffFile(w, r, false)
+}
+
+// diffFullFileHandler is diffFileHandler without the patch size limits, for
+// loading the whole of a file whose patch was truncated
+func (s *AppState) diffFullFileHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveDiffFile(w, r, true)
+}
+
+// serveDiffFile responds with a single file of the diff; full lifts the
+// max-patch-lines and max-patch-bytes limits
+func (s *AppState) serveDiffFile(w http.ResponseWriter, r *http.Request, full bool) {
+	s.fetchIfDue()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	stagingStatus := r.URL.Query().Get("staging_status")
+
+	req, err := s.parseDiffRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.opts.Paths = []string{filePath}
+	req.opts.SkipPatches = false
+	if full {
+		req.opts.MaxPatchLines = 0
+		req.opts.MaxPatchBytes = 0
+	}
+
+	response, err := s.buildDiff(req)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	for _, file := range append(response.Files, response.UncommittedFiles...) {
+		if file.Path == filePath && (stagingStatus == "" || file.StagingStatus == stagingStatus) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(file) // Ignore encode error for HTTP response
+			return
+		}
+	}
+
+	http.Error(w, fmt.Sprintf("%s has no changes in this diff", filePath), http.StatusNotFound)
+}
+
+// diffRequest holds the query parameters shared by the diff endpoints
+type diffRequest struct {
+	opts          git.DiffOptions
+	stagingFilter git.StagingFilter
+	view          string
+	hideGenerated bool
+	wordDiff      bool
+	// commit shows a single commit's changes instead of the view's
+	commit string
+}
+
+// parseDiffRequest reads the diff query parameters, falling back to the
+// server's defaults
+func (s *AppState) parseDiffRequest(r *http.Request) (diffRequest, error) {
+	req := diffRequest{
+		opts: git.DiffOptions{IgnoreWhitespace: s.IgnoreWhitespace, GeneratedPatterns: s.GeneratedPatterns, MaxPatchLines: s.MaxPatchLines, MaxPatchBytes: s.MaxPatchBytes},
+	}
+	var err error
+
+	if value := r.URL.Query().Get("ignore_whitespace"); value != "" {
+		ignoreWhitespace, err := strconv.ParseBool(value)
+		if err != nil {
+			return req, fmt.Errorf("invalid ignore_whitespace value")
+		}
+		req.opts.IgnoreWhitespace = ignoreWhitespace
+	}
+
+	if req.hideGenerated, err = parseBoolParam(r, "hide_generated"); err != nil {
+		return req, err
+	}
+
+	if req.wordDiff, err = parseBoolParam(r, "word_diff"); err != nil {
+		return req, err
+	}
+
+	// Metadata-only diffs skip computing patches so large diffs list
+	// quickly; /api/diff/file serves each file's patch when it is expanded
+	if req.opts.SkipPatches, err = parseBoolParam(r, "metadata_only"); err != nil {
+		return req, err
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = string(s.DiffMode)
+	}
+	if req.opts.Mode, err = git.ParseDiffMode(mode); err != nil {
+		return req, err
+	}
+
+	if req.stagingFilter, err = git.ParseStagingFilter(r.URL.Query().Get("staging")); err != nil {
+		return req, err
+	}
+
+	req.view = r.URL.Query().Get("view")
+	if req.view == "" {
+		req.view = s.DefaultView
+	}
+	switch req.view {
+	case ViewFull, ViewStaged, "":
+	default:
+		return req, fmt.Errorf("invalid view %q (expected %s or %s)", req.view, ViewFull, ViewStaged)
+	}
+
+	req.commit = r.URL.Query().Get("commit")
+
+	return req, nil
+}
+
+// diffContext is the repository state a diff is computed against
+type diffContext struct {
+	gitRepo    *git.Repo
+	branch     string
+	commit     string
+	remoteName string
+	remoteURL  string
+}
+
+// openDiffContext opens the repository and reads its branch, commit and
+// remote. Callers must hold s.mu, and fetch first with fetchIfDue.
+func (s *AppState) openDiffContext() (*diffContext, error) {
+	gitRepo, err := git.Open(".")
+	if err != nil {
+		return nil, err
+	}
+
 	currentBranch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	currentCommit, err := gitRepo.CurrentCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteName, remoteURL, _ := gitRepo.GetRemoteURL() // Ignore error, remote is optional
+
+	return &diffContext{
+		gitRepo:    gitRepo,
+		branch:     currentBranch,
+		commit:     currentCommit,
+		remoteName: remoteName,
+		remoteURL:  remoteURL,
+	}, nil
+}
+
+// committedFileDiff describes a file changed on the branch
+func (s *AppState) committedFileDiff(dc *diffContext, file git.FileInfo, req diffRequest) FileDiff {
+	webURL := ""
+	if file.Status != "deleted" {
+		webURL = git.WebURL(dc.remoteURL, webRef(dc.branch, dc.commit), file.Path, 0)
+	}
+
+	return FileDiff{
+		Path:          file.Path,
+		Status:        file.Status,
+		Additions:     file.Additions,
+		Deletions:     file.Deletions,
+		Patch:         file.Patch,
+		Viewed:        s.StateManager.IsFileViewed(s.RepoPath, dc.branch, dc.commit, file.Path),
+		StagingStatus: string(git.StagingStatusCommitted),
+		Binary:        file.Binary,
+		IsSymlink:     file.IsSymlink,
+		Language:      git.LanguageForPath(file.Path),
+		Generated:     file.Generated,
+		Truncated:     file.Truncated,
+		SubmoduleFrom: file.SubmoduleFrom,
+		SubmoduleTo:   file.SubmoduleTo,
+		WordDiffs:     wordDiffs(file.Patch, req.wordDiff),
+		WebURL:        webURL,
+	}
+}
+
+// uncommittedFileDiff describes a staged or unstaged change. In the staged
+// view, viewed state is kept under the current commit, as it is for the
+// branch's files; otherwise it is kept per staging status.
+func (s *AppState) uncommittedFileDiff(dc *diffContext, file git.FileInfo, req diffRequest) FileDiff {
+	viewed := s.StateManager.IsFileViewed(s.RepoPath, dc.branch, uncommittedCommit, file.Path+":"+string(file.StagingStatus))
+	if req.view == ViewStaged {
+		viewed = s.StateManager.IsFileViewed(s.RepoPath, dc.branch, dc.commit, file.Path)
+	}
+
+	return FileDiff{
+		Path:          file.Path,
+		Status:        file.Status,
+		Additions:     file.Additions,
+		Deletions:     file.Deletions,
+		Patch:         file.Patch,
+		Viewed:        viewed,
+		StagingStatus: string(file.StagingStatus),
+		FromPath:      file.FromPath,
+		Similarity:    file.Similarity,
+		Binary:        file.Binary,
+		IsSymlink:     file.IsSymlink,
+		Language:      git.LanguageForPath(file.Path),
+		Generated:     file.Generated,
+		Truncated:     file.Truncated,
+		SubmoduleFrom: file.SubmoduleFrom,
+		SubmoduleTo:   file.SubmoduleTo,
+		WordDiffs:     wordDiffs(file.Patch, req.wordDiff),
+	}
+}
+
+// buildDiff computes the diff of the current branch for a request. Callers
+// must hold s.mu.
+func (s *AppState) buildDiff(req diffRequest) (*DiffResponse, error) {
+	dc, err := s.openDiffContext()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.commit != "" {
+		return s.commitDiff(dc, req)
+	}
+	if req.view == ViewStaged {
+		return s.stagedDiff(dc, req)
+	}
+
+	diff, err := dc.gitRepo.GetDiffFiles(s.BaseBranch, req.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fileDiffs := []FileDiff{}
+	for _, file := range diff.Files {
+		if req.hideGenerated && file.Generated {
+			continue
+		}
+		fileDiffs = append(fileDiffs, s.committedFileDiff(dc, file, req))
+	}
+
+	response := s.diffSummary(dc, req, diff.BaseCommit)
+	response.Files = fileDiffs
+	response.UncommittedFiles = s.uncommittedFileDiffs(dc, req)
+	return response, nil
+}
+
+// uncommittedFileDiffs describes the uncommitted changes shown alongside the
+// branch diff. They are left out, rather than failing the diff, when the
+// working tree can't be read.
+func (s *AppState) uncommittedFileDiffs(dc *diffContext, req diffRequest) []FileDiff {
+	fileDiffs := []FileDiff{}
+	uncommittedFiles, err := dc.gitRepo.GetUncommittedChangesFiltered(req.stagingFilter, req.opts)
+	if err != nil {
+		return fileDiffs
+	}
+
+	for _, file := range uncommittedFiles {
+		if req.hideGenerated && file.Generated {
+			continue
+		}
+		fileDiffs = append(fileDiffs, s.uncommittedFileDiff(dc, file, req))
+	}
+	return fileDiffs
+}
+
+// diffSummary is a full-view response without its files
+func (s *AppState) diffSummary(dc *diffContext, req diffRequest, baseCommit string) *DiffResponse {
+	return &DiffResponse{
+		View:       ViewFull,
+		Branch:     dc.branch,
+		Commit:     dc.commit,
+		RepoPath:   s.RepoPath,
+		RemoteURL:  dc.remoteURL,
+		RemoteName: dc.remoteName,
+		Mode:       req.opts.Mode,
+		BaseCommit: baseCommit,
+		Commits:    diffCommits(dc, baseCommit),
+	}
+}
+
+// diffCommits lists the commits under review. They are left out, rather than
+// failing the diff, when git can't list them.
+func diffCommits(dc *diffContext, baseCommit string) []git.CommitInfo {
+	commits, err := dc.gitRepo.GetCommitRange(baseCommit, dc.commit)
+	if err != nil {
+		return nil
+	}
+	return commits
+}
+
+// stagedDiff computes the changes staged for the next commit
+func (s *AppState) stagedDiff(dc *diffContext, req diffRequest) (*DiffResponse, error) {
+	staged, err := dc.gitRepo.GetStagedDiff(req.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fileDiffs := []FileDiff{}
+	for _, file := range staged.Files {
+		if req.hideGenerated && file.Generated {
+			continue
+		}
+		fileDiffs = append(fileDiffs, s.uncommittedFileDiff(dc, file, req))
+	}
+
+	return &DiffResponse{
+		Files:      fileDiffs,
+		View:       ViewStaged,
+		Branch:     dc.branch,
+		Commit:     dc.commit,
+		RepoPath:   s.RepoPath,
+		RemoteURL:  dc.remoteURL,
+		RemoteName: dc.remoteName,
+	}, nil
+}
+
+// commitDiff computes the changes of the single commit req.commit names,
+// against its parent. Files are viewed and linked at that commit, so each
+// commit of a branch keeps its own review state.
+func (s *AppState) commitDiff(dc *diffContext, req diffRequest) (*DiffResponse, error) {
+	diff, err := dc.gitRepo.GetCommitDiff(req.commit, req.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	commitContext := *dc
+	commitContext.commit = diff.HeadCommit
+
+	fileDiffs := []FileDiff{}
+	for _, file := range diff.Files {
+		if req.hideGenerated && file.Generated {
+			continue
+		}
+		fileDiffs = append(fileDiffs, s.committedFileDiff(&commitContext, file, req))
+	}
+
+	return &DiffResponse{
+		Files:      fileDiffs,
+		View:       ViewFull,
+		Branch:     dc.branch,
+		Commit:     diff.HeadCommit,
+		RepoPath:   s.RepoPath,
+		RemoteURL:  dc.remoteURL,
+		RemoteName: dc.remoteName,
+		BaseCommit: diff.BaseCommit,
+	}, nil
+}
+
+// commitsHandler lists the commits under review, newest first: those
+// between the full view's base commit and HEAD
+func (s *AppState) commitsHandler(w http.ResponseWriter, r *http.Request) {
+	s.fetchIfDue()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := s.parseDiffRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dc, err := s.openDiffContext()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	currentCommit, err := gitRepo.CurrentCommit()
+	baseCommit, err := dc.gitRepo.DiffBaseCommit(s.BaseBranch, req.opts)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	commits, err := dc.gitRepo.GetCommitRange(baseCommit, dc.commit)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	remoteURL, _ := gitRepo.GetRemoteURL() // Ignore error, remote is optional
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(commits) // Ignore encode error for HTTP response
+}
 
-	files, err := gitRepo.GetDiffFiles(s.BaseBranch)
+// diffStreamLine is one line of a streamed diff: a file, then finally the
+// summary, or an error that ends the stream
+type diffStreamLine struct {
+	File    *FileDiff     `json:"file,omitempty"`
+	Summary *DiffResponse `json:"summary,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// streamDiff writes the diff as newline-delimited JSON, encoding the branch's
+// files one at a time as their patches are computed so large diffs are never
+// held in memory whole. Failures found before the first line, such as a base
+// with no merge base or an unknown commit, get the status /api/diff would
+// send; later ones end the stream with an error line. Callers must hold s.mu.
+func (s *AppState) streamDiff(w http.ResponseWriter, req diffRequest) {
+	dc, err := s.openDiffContext()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	fileDiffs := []FileDiff{}
-	for _, file := range files {
-		viewed := s.StateManager.IsFileViewed(s.RepoPath, currentBranch, currentCommit, file.Path)
-
-		fileDiffs = append(fileDiffs, FileDiff{
-			Path:          file.Path,
-			Status:        file.Status,
-			Additions:     file.Additions,
-			Deletions:     file.Deletions,
-			Patch:         file.Patch,
-			Viewed:        viewed,
-			StagingStatus: string(git.StagingStatusCommitted),
-		})
+	// The single-commit and staged diffs are computed whole, and the full
+	// view's base is resolved, before the stream starts
+	var summary *DiffResponse
+	switch {
+	case req.commit != "":
+		summary, err = s.commitDiff(dc, req)
+	case req.view == ViewStaged:
+		summary, err = s.stagedDiff(dc, req)
+	default:
+		_, err = dc.gitRepo.DiffBaseCommit(s.BaseBranch, req.opts)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
 	}
 
-	// Get uncommitted changes
-	uncommittedFiles, err := gitRepo.GetUncommittedChanges()
-	uncommittedFileDiffs := []FileDiff{}
-	if err == nil {
-		for _, file := range uncommittedFiles {
-			// Use a special commit identifier for uncommitted changes state
-			uncommittedCommit := "__uncommitted__"
-			viewed := s.StateManager.IsFileViewed(s.RepoPath, currentBranch, uncommittedCommit, file.Path+":"+string(file.StagingStatus))
-
-			uncommittedFileDiffs = append(uncommittedFileDiffs, FileDiff{
-				Path:          file.Path,
-				Status:        file.Status,
-				Additions:     file.Additions,
-				Deletions:     file.Deletions,
-				Patch:         file.Patch,
-				Viewed:        viewed,
-				StagingStatus: string(file.StagingStatus),
-			})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	writeLine := func(line diffStreamLine) error {
+		if err := encoder.Encode(line); err != nil {
+			return err
 		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	writeFiles := func(files []FileDiff) error {
+		for i := range files {
+			if err := writeLine(diffStreamLine{File: &files[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	response := DiffResponse{
-		Files:            fileDiffs,
-		UncommittedFiles: uncommittedFileDiffs,
-		Branch:           currentBranch,
-		Commit:           currentCommit,
-		RepoPath:         s.RepoPath,
-		RemoteURL:        remoteURL,
+	if summary != nil {
+		err = writeFiles(summary.Files)
+	} else {
+		var diff *git.DiffResult
+		diff, err = dc.gitRepo.EachDiffFile(s.BaseBranch, req.opts, func(file git.FileInfo) error {
+			if req.hideGenerated && file.Generated {
+				return nil
+			}
+			fileDiff := s.committedFileDiff(dc, file, req)
+			return writeLine(diffStreamLine{File: &fileDiff})
+		})
+		if err == nil {
+			summary = s.diffSummary(dc, req, diff.BaseCommit)
+			err = writeFiles(s.uncommittedFileDiffs(dc, req))
+		}
+	}
+	if err != nil {
+		_ = writeLine(diffStreamLine{Error: err.Error()}) // The status line has already been sent
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response) // Ignore encode error for HTTP response
+	summary.Files = nil
+	_ = writeLine(diffStreamLine{Summary: summary}) // Ignore encode error for HTTP response
 }
 
 func (s *AppState) markViewedHandler(w http.ResponseWriter, r *http.Request) {
@@ -231,6 +1023,9 @@ func (s *AppState) markViewedHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if payload.Commit != "" {
+		currentCommit = payload.Commit
+	}
 
 	if err := s.StateManager.MarkFileViewed(s.RepoPath, currentBranch, currentCommit, payload.FilePath); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -267,6 +1062,9 @@ func (s *AppState) unmarkViewedHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if payload.Commit != "" {
+		currentCommit = payload.Commit
+	}
 
 	if err := s.StateManager.UnmarkFileViewed(s.RepoPath, currentBranch, currentCommit, payload.FilePath); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -276,6 +1074,13 @@ func (s *AppState) unmarkViewedHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// healthHandler answers without touching the repository or review state, so
+// readiness probes succeed quickly however large the diff is
+func (s *AppState) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "version": version.String()}) // Ignore encode error for HTTP response
+}
+
 func (s *AppState) statusHandler(w http.ResponseWriter, r *http.Request) {
 	gitRepo, err := git.Open(".")
 	if err != nil {
@@ -295,10 +1100,142 @@ func (s *AppState) statusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hideGenerated, err := parseBoolParam(r, "hide_generated")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	counts := s.reviewCounts(gitRepo, currentBranch, currentCommit, hideGenerated)
+
+	// Report the detected branch rather than "auto"
+	baseBranch, err := gitRepo.ResolveBaseBranch(s.BaseBranch)
+	if err != nil {
+		baseBranch = s.BaseBranch
+	}
+
 	response := StatusResponse{
-		RepoPath: s.RepoPath,
-		Branch:   currentBranch,
-		Commit:   currentCommit,
+		RepoPath:          s.RepoPath,
+		Branch:            currentBranch,
+		Commit:            currentCommit,
+		RefreshIntervalMs: s.RefreshIntervalMs,
+		BaseBranch:        baseBranch,
+		ReviewCounts:      counts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response) // Ignore encode error for HTTP response
+}
+
+// parseBoolParam reads an optional boolean query parameter such as
+// hide_generated, which leaves generated files out of diffs and counts, or
+// word_diff, which adds word-level changes to diffs
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value", name)
+	}
+	return enabled, nil
+}
+
+// webRef is the ref web links point at: the branch, or the commit when HEAD is
+// detached
+func webRef(branch, commit string) string {
+	if strings.HasPrefix(branch, git.DetachedBranchPrefix) {
+		return commit
+	}
+	return branch
+}
+
+// wordDiffs computes the word-level changes of a patch when enabled
+func wordDiffs(patch string, enabled bool) []git.WordDiff {
+	if !enabled {
+		return nil
+	}
+	return git.WordDiffs(patch)
+}
+
+// reviewCounts tallies the files, comments and notes of the current branch and
+// commit, leaving generated files out of the file counts when hideGenerated
+// is set. Files are listed without their patches and before taking s.mu, so
+// polling the status doesn't hold up other requests. A diff that can't be
+// computed leaves the file counts at zero so the status endpoint keeps
+// answering.
+func (s *AppState) reviewCounts(gitRepo *git.Repo, branch, commit string, hideGenerated bool) ReviewCounts {
+	opts := git.DiffOptions{IgnoreWhitespace: s.IgnoreWhitespace, GeneratedPatterns: s.GeneratedPatterns, Mode: s.DiffMode, SkipPatches: true}
+
+	var committed, uncommitted []git.FileInfo
+	if diff, err := gitRepo.GetDiffFiles(s.BaseBranch, opts); err == nil {
+		committed = diff.Files
+	}
+	if files, err := gitRepo.GetUncommittedChangesFiltered(git.StagingFilterAll, opts); err == nil {
+		uncommitted = files
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var counts ReviewCounts
+	for _, file := range committed {
+		if hideGenerated && file.Generated {
+			continue
+		}
+		counts.TotalFiles++
+		if s.StateManager.IsFileViewed(s.RepoPath, branch, commit, file.Path) {
+			counts.ViewedFiles++
+		}
+	}
+
+	for _, file := range uncommitted {
+		if hideGenerated && file.Generated {
+			continue
+		}
+		counts.TotalFiles++
+		if s.StateManager.IsFileViewed(s.RepoPath, branch, uncommittedCommit, file.Path+":"+string(file.StagingStatus)) {
+			counts.ViewedFiles++
+		}
+	}
+
+	for _, comment := range s.StateManager.GetComments(s.RepoPath, branch, commit, nil) {
+		counts.TotalComments++
+		if !comment.Resolved {
+			counts.UnresolvedComments++
+		}
+	}
+
+	for _, note := range s.StateManager.GetNotes(s.RepoPath, branch, commit, nil) {
+		counts.TotalNotes++
+		if !note.Dismissed {
+			counts.ActiveNotes++
+		}
+	}
+
+	return counts
+}
+
+// diffDebugHandler reports status discrepancies between go-git and native git,
+// optionally limited to the file given by ?file=, to help diagnose bug reports
+func (s *AppState) diffDebugHandler(w http.ResponseWriter, r *http.Request) {
+	gitRepo, err := git.Open(".")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filePath := r.URL.Query().Get("file")
+	discrepancies, err := gitRepo.StatusDiscrepancies(filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := DiffDebugResponse{
+		File:          filePath,
+		Discrepancies: discrepancies,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -333,7 +1270,38 @@ func (s *AppState) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
 		filePathPtr = &filePath
 	}
 
-	comments := s.StateManager.GetComments(s.RepoPath, currentBranch, currentCommit, filePathPtr)
+	_, remoteURL, _ := gitRepo.GetRemoteURL() // Ignore error, remote is optional
+	ref := webRef(currentBranch, currentCommit)
+
+	comments := []CommentResponse{}
+	for _, comment := range s.StateManager.GetComments(s.RepoPath, currentBranch, currentCommit, filePathPtr) {
+		line := 0
+		if comment.LineNumber != nil {
+			line = *comment.LineNumber
+		}
+		comments = append(comments, CommentResponse{
+			Comment: comment,
+			WebURL:  git.WebURL(remoteURL, ref, comment.FilePath, line),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(comments) // Ignore encode error for HTTP response
+}
+
+// mentionsHandler lists the comments on any branch that @mention the user
+// given by ?user=, newest first
+func (s *AppState) mentionsHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		http.Error(w, "user is required", http.StatusBadRequest)
+		return
+	}
+
+	comments := s.StateManager.GetMentions(s.RepoPath, user)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(comments) // Ignore encode error for HTTP response
@@ -367,7 +1335,44 @@ func (s *AppState) addCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comment, err := s.StateManager.AddComment(s.RepoPath, currentBranch, currentCommit, payload.FilePath, payload.LineNumber, payload.Text)
+	if err := state.ValidateLineRange(payload.LineNumber, payload.EndLine); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := state.ValidateSide(payload.Side); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Default author to the configured reviewer for comments added from the browser
+	commentAuthor := payload.Author
+	if commentAuthor == "" {
+		commentAuthor = s.reviewer()
+	} else if err := author.Validate(commentAuthor); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comment, err := s.StateManager.AddComment(
+		s.RepoPath,
+		currentBranch,
+		currentCommit,
+		payload.FilePath,
+		payload.LineNumber,
+		payload.EndLine,
+		payload.Side,
+		payload.Text,
+		payload.Suggestion,
+		commentAuthor,
+		payload.Type,
+		payload.ParentID,
+		payload.Metadata,
+	)
+	if errors.Is(err, state.ErrParentNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -377,6 +1382,14 @@ func (s *AppState) addCommentHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(comment) // Ignore encode error for HTTP response
 }
 
+// reviewer is who the web UI acts as, config.DefaultReviewer unless configured
+func (s *AppState) reviewer() string {
+	if s.Reviewer == "" {
+		return config.DefaultReviewer
+	}
+	return s.Reviewer
+}
+
 func (s *AppState) resolveCommentHandler(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -405,7 +1418,43 @@ func (s *AppState) resolveCommentHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := s.StateManager.ResolveComment(s.RepoPath, currentBranch, currentCommit, payload.CommentID, "web-ui"); err != nil {
+	if err := s.StateManager.ResolveComment(s.RepoPath, currentBranch, currentCommit, payload.CommentID, s.reviewer()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *AppState) unresolveCommentHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var payload ResolveCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gitRepo, err := git.Open(".")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	currentBranch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	currentCommit, err := gitRepo.CurrentCommit()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.StateManager.UnresolveComment(s.RepoPath, currentBranch, currentCommit, payload.CommentID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -475,6 +1524,11 @@ func (s *AppState) addNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := author.Validate(payload.Author); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Default type to "explanation" if not provided
 	noteType := payload.Type
 	if noteType == "" {
@@ -529,10 +1583,52 @@ func (s *AppState) dismissNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.StateManager.DismissNote(s.RepoPath, currentBranch, currentCommit, payload.NoteID, "web-ui"); err != nil {
+	if err := s.StateManager.DismissNote(s.RepoPath, currentBranch, currentCommit, payload.NoteID, s.reviewer()); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (s *AppState) getPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	prefs, err := config.LoadPreferences(s.RepoPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prefs) // Ignore encode error for HTTP response
+}
+
+// updatePreferencesHandler saves the repository's web UI preferences. Fields
+// missing from the request keep their saved values.
+func (s *AppState) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, err := config.LoadPreferences(s.RepoPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := prefs.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := config.SavePreferences(s.RepoPath, prefs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prefs) // Ignore encode error for HTTP response
+}
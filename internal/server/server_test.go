@@ -0,0 +1,1059 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/git"
+	"github.com/tuist/guck/internal/state"
+)
+
+// setupTestAppState creates a git repository, changes into it and returns an
+// AppState backed by a temporary state directory
+func setupTestAppState(t *testing.T) *AppState {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tempDir, "state"))
+
+	repoPath := filepath.Join(tempDir, "repo")
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+
+	runGit(t, repoPath, "init")
+	runGit(t, repoPath, "config", "user.email", "test@test.com")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Test Repo\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Initial commit")
+
+	t.Chdir(repoPath)
+
+	stateMgr, err := state.NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	return &AppState{
+		RepoPath:     repoPath,
+		BaseBranch:   "main",
+		StateManager: stateMgr,
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+func TestStatusHandlerIncludesRefreshInterval(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.RefreshIntervalMs = 2500
+	appState.BaseBranch = "develop"
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.RefreshIntervalMs != 2500 {
+		t.Errorf("Expected refresh_interval_ms 2500, got %d", response.RefreshIntervalMs)
+	}
+	if response.BaseBranch != "develop" {
+		t.Errorf("Expected base_branch develop, got %q", response.BaseBranch)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	// A diff in progress must not hold up readiness probes
+	appState.mu.Lock()
+	defer appState.mu.Unlock()
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Errorf("Expected an ok status, got %s", rec.Body.String())
+	}
+}
+
+func TestSlowFetchDoesNotBlockOtherRequests(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.AutoFetch = true
+
+	// A remote that takes a while to answer, like one on a slow network
+	runGit(t, appState.RepoPath, "config", "protocol.ext.allow", "always")
+	runGit(t, appState.RepoPath, "remote", "add", "origin", "ext::sleep 2")
+
+	diffDone := make(chan struct{})
+	go func() {
+		defer close(diffDone)
+		appState.router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/diff", nil))
+	}()
+
+	// Wait for the diff request to start fetching
+	for started := false; !started; {
+		appState.fetchMu.Lock()
+		started = !appState.lastFetch.IsZero()
+		appState.fetchMu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/mark-viewed", strings.NewReader(`{"file_path": "README.md"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected mark-viewed not to wait for the fetch, took %s", elapsed)
+	}
+
+	<-diffDone
+}
+
+func TestStatusHandlerReportsDetectedBaseBranch(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = git.AutoBaseBranch
+	runGit(t, appState.RepoPath, "branch", "-M", "feature")
+	runGit(t, appState.RepoPath, "branch", "trunk")
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+	var response StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.BaseBranch != "trunk" {
+		t.Errorf("Expected the detected base branch trunk, got %q", response.BaseBranch)
+	}
+}
+
+func TestDiffHandlerReportsUnrelatedBase(t *testing.T) {
+	appState := setupTestAppState(t)
+	branch := strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, appState.RepoPath, "checkout", "-q", "--orphan", "unrelated")
+	runGit(t, appState.RepoPath, "commit", "-q", "-m", "Unrelated root")
+	runGit(t, appState.RepoPath, "checkout", "-q", branch)
+	appState.BaseBranch = "unrelated"
+
+	for _, url := range []string{"/api/diff", "/api/diff?stream=true"} {
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("Expected status 409 from %s, got %d: %s", url, rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), "no common history with unrelated") {
+			t.Errorf("Expected the error from %s to name the base, got %q", url, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?stream=true&commit=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 streaming an unknown commit, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStatusHandlerIncludesReviewCounts(t *testing.T) {
+	appState := setupTestAppState(t)
+	repoPath := appState.RepoPath
+
+	appState.BaseBranch = strings.TrimSpace(runGit(t, repoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "Add files")
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	commit := strings.TrimSpace(runGit(t, repoPath, "rev-parse", "HEAD"))
+	manager := appState.StateManager
+	if err := manager.MarkFileViewed(repoPath, "feature", commit, "a.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+	comment, err := manager.AddComment(repoPath, "feature", commit, "a.go", nil, nil, "", "Resolved", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if err := manager.ResolveComment(repoPath, "feature", commit, comment.ID, "me"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "feature", commit, "b.go", nil, nil, "", "Open", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if _, err := manager.AddNote(repoPath, "feature", commit, "b.go", nil, "Note", "claude", "", nil); err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	expected := ReviewCounts{
+		TotalFiles:         3,
+		ViewedFiles:        1,
+		TotalComments:      2,
+		UnresolvedComments: 1,
+		TotalNotes:         1,
+		ActiveNotes:        1,
+	}
+	if response.ReviewCounts != expected {
+		t.Errorf("Expected counts %+v, got %+v", expected, response.ReviewCounts)
+	}
+}
+
+func TestDiffHandlerIncludesCommits(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, appState.RepoPath, "checkout", "-q", "-b", "feature")
+	runGit(t, appState.RepoPath, "commit", "--allow-empty", "-q", "-m", "Explain the change\n\nBecause reviewers need context.")
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response DiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Commits) != 1 {
+		t.Fatalf("Expected the branch's commit, got %+v", response.Commits)
+	}
+	commit := response.Commits[0]
+	if commit.Hash != response.Commit || commit.Subject != "Explain the change" || commit.Body != "Because reviewers need context." || commit.Author != "Test User" {
+		t.Errorf("Unexpected commit %+v", commit)
+	}
+}
+
+func TestDiffHandlerSingleCommit(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, appState.RepoPath, "checkout", "-q", "-b", "feature")
+	for _, name := range []string{"first.go", "second.go"} {
+		if err := os.WriteFile(filepath.Join(appState.RepoPath, name), []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		runGit(t, appState.RepoPath, "add", name)
+		runGit(t, appState.RepoPath, "commit", "-q", "-m", "Add "+name)
+	}
+	first := strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "HEAD~1"))
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/commits", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var commits []git.CommitInfo
+	if err := json.NewDecoder(rec.Body).Decode(&commits); err != nil {
+		t.Fatalf("Failed to decode commits: %v", err)
+	}
+	if len(commits) != 2 || commits[1].Hash != first {
+		t.Fatalf("Expected both branch commits, oldest last, got %+v", commits)
+	}
+
+	// Viewed state is kept per commit
+	body := strings.NewReader(`{"file_path": "first.go", "commit": "` + first + `"}`)
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/mark-viewed", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?commit="+first, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response DiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Commit != first || len(response.Files) != 1 || response.Files[0].Path != "first.go" || !response.Files[0].Viewed {
+		t.Errorf("Expected only first.go, viewed at %s, got %+v", first, response)
+	}
+	if len(response.UncommittedFiles) != 0 {
+		t.Errorf("Expected no uncommitted files for a single commit, got %+v", response.UncommittedFiles)
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?commit=nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown commit, got %d", rec.Code)
+	}
+}
+
+func TestDiffHandlerHideGenerated(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.GeneratedPatterns = []string{"go.sum"}
+
+	for _, name := range []string{"go.sum", "main.go"} {
+		if err := os.WriteFile(filepath.Join(appState.RepoPath, name), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	runGit(t, appState.RepoPath, "add", ".")
+
+	diff := func(query string) []FileDiff {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?view=staged"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var response DiffResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Files
+	}
+
+	files := diff("")
+	if len(files) != 2 || files[0].Path != "go.sum" || !files[0].Generated || files[1].Generated {
+		t.Errorf("Expected go.sum flagged as generated alongside main.go, got %+v", files)
+	}
+
+	if files := diff("&hide_generated=true"); len(files) != 1 || files[0].Path != "main.go" {
+		t.Errorf("Expected only main.go with hide_generated, got %+v", files)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?hide_generated=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid hide_generated, got %d", rec.Code)
+	}
+}
+
+func TestDiffHandlerWordDiff(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "README.md"), []byte("# Guck Repo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write README.md: %v", err)
+	}
+	runGit(t, appState.RepoPath, "add", "README.md")
+
+	diff := func(query string) []FileDiff {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?view=staged"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var response DiffResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Files
+	}
+
+	if files := diff(""); len(files) != 1 || files[0].WordDiffs != nil {
+		t.Errorf("Expected no word diffs unless requested, got %+v", files)
+	}
+
+	files := diff("&word_diff=true")
+	if len(files) != 1 || len(files[0].WordDiffs) != 1 {
+		t.Fatalf("Expected one word diff for README.md, got %+v", files)
+	}
+	added := files[0].WordDiffs[0].Added
+	if len(added) != 1 || "# Guck Repo"[added[0].Start:added[0].End] != "Guck" {
+		t.Errorf("Expected Guck as the only added word, got %+v", added)
+	}
+}
+
+func TestCommentsHandlerIncludesWebURL(t *testing.T) {
+	appState := setupTestAppState(t)
+	runGit(t, appState.RepoPath, "checkout", "-q", "-b", "feature")
+	runGit(t, appState.RepoPath, "remote", "add", "origin", "git@github.com:tuist/guck.git")
+	commit := strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "HEAD"))
+
+	line := 3
+	if _, err := appState.StateManager.AddComment(appState.RepoPath, "feature", commit, "README.md", &line, nil, "", "Typo", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/comments", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var comments []CommentResponse
+	if err := json.NewDecoder(rec.Body).Decode(&comments); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Text != "Typo" {
+		t.Fatalf("Expected the comment, got %+v", comments)
+	}
+	if expected := "https://github.com/tuist/guck/blob/feature/README.md#L3"; comments[0].WebURL != expected {
+		t.Errorf("Expected web_url %s, got %s", expected, comments[0].WebURL)
+	}
+}
+
+func TestCommentHandlersUseConfiguredReviewer(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.Reviewer = "human:alice"
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/comments", strings.NewReader(`{"file_path": "README.md", "text": "Typo"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var comment state.Comment
+	if err := json.NewDecoder(rec.Body).Decode(&comment); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if comment.Author != "human:alice" {
+		t.Errorf("Expected the reviewer as author, got %q", comment.Author)
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/comments/resolve", strings.NewReader(`{"comment_id": "`+comment.ID+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if resolved := appState.StateManager.GetAllComments(appState.RepoPath)[0]; resolved.ResolvedBy != "human:alice" {
+		t.Errorf("Expected the comment resolved by the reviewer, got %q", resolved.ResolvedBy)
+	}
+}
+
+func TestAddCommentReply(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	addComment := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/comments", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := addComment(`{"file_path": "README.md", "text": "Why?"}`)
+	var parent state.Comment
+	if err := json.NewDecoder(rec.Body).Decode(&parent); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	rec = addComment(`{"file_path": "README.md", "text": "Because", "parent_id": "` + parent.ID + `"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var reply state.Comment
+	if err := json.NewDecoder(rec.Body).Decode(&reply); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if reply.ParentID != parent.ID {
+		t.Errorf("Expected a reply to %s, got parent %q", parent.ID, reply.ParentID)
+	}
+
+	if rec := addComment(`{"file_path": "README.md", "text": "Lost", "parent_id": "missing"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown parent, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAddRejectsInvalidAuthors(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	tests := []struct {
+		url, body string
+		want      int
+	}{
+		{"/api/comments", `{"file_path": "README.md", "text": "Hi", "author": "human:"}`, http.StatusBadRequest},
+		{"/api/comments", `{"file_path": "README.md", "text": "Hi", "author": "human:alice"}`, http.StatusOK},
+		{"/api/notes", `{"file_path": "README.md", "text": "Hi", "author": "agent:"}`, http.StatusBadRequest},
+		{"/api/notes", `{"file_path": "README.md", "text": "Hi"}`, http.StatusBadRequest},
+		{"/api/notes", `{"file_path": "README.md", "text": "Hi", "author": "agent:claude"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("POST %s %s: expected status %d, got %d: %s", tt.url, tt.body, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestPreferencesHandlers(t *testing.T) {
+	appState := setupTestAppState(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	getPreferences := func() config.Preferences {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/preferences", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var prefs config.Preferences
+		if err := json.NewDecoder(rec.Body).Decode(&prefs); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return prefs
+	}
+
+	if prefs := getPreferences(); prefs != config.DefaultPreferences {
+		t.Errorf("Expected default preferences, got %+v", prefs)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/preferences", strings.NewReader(`{"diff_view": "split", "theme": "dark"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A partial update keeps the other saved preferences
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/preferences", strings.NewReader(`{"wrap_lines": true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	expected := config.Preferences{DiffView: config.DiffViewSplit, WrapLines: true, Theme: config.ThemeDark}
+	if prefs := getPreferences(); prefs != expected {
+		t.Errorf("Expected %+v, got %+v", expected, prefs)
+	}
+
+	// Preferences are saved per repository
+	if prefs, err := config.LoadPreferences("/some/other/repo"); err != nil || prefs != config.DefaultPreferences {
+		t.Errorf("Expected defaults for another repo, got %+v (%v)", prefs, err)
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/preferences", strings.NewReader(`{"diff_view": "sideways"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid diff_view, got %d", rec.Code)
+	}
+	if prefs := getPreferences(); prefs != expected {
+		t.Errorf("Expected a rejected update to change nothing, got %+v", prefs)
+	}
+}
+
+func TestEventsHandlerSendsDiffChanged(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	server := httptest.NewServer(appState.router())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("Failed to connect to events endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", contentType)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	// Wait for the connection comment so the watcher is in place
+	if line := <-lines; line != ": connected" {
+		t.Fatalf("Expected connection comment, got %q", line)
+	}
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "README.md"), []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Event stream closed before diff-changed was sent")
+			}
+			if line == "event: diff-changed" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for diff-changed event")
+		}
+	}
+}
+
+func TestEventHubSharesOneWatcher(t *testing.T) {
+	appState := setupTestAppState(t)
+	hub := &appState.events
+
+	first, err := hub.subscribe(appState.RepoPath)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	watcher := hub.watcher
+
+	second, err := hub.subscribe(appState.RepoPath)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if hub.watcher != watcher {
+		t.Fatal("Expected the second subscriber to reuse the watcher")
+	}
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "README.md"), []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	for _, changes := range []chan struct{}{first, second} {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a subscriber to be notified")
+		}
+	}
+
+	hub.unsubscribe(first)
+	if hub.watcher == nil {
+		t.Fatal("Expected the watcher to stay open for the remaining subscriber")
+	}
+	hub.unsubscribe(second)
+	if hub.watcher != nil {
+		t.Error("Expected the watcher to be closed with the last subscriber")
+	}
+}
+
+func TestRepoWatcherHonoursNestedIgnoreFiles(t *testing.T) {
+	appState := setupTestAppState(t)
+	repoPath := appState.RepoPath
+
+	for _, dir := range []string{"web/dist", "web/src", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(repoPath, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "web", ".gitignore"), []byte("dist/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, ".git", "info", "exclude"), []byte("tmp/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write exclude: %v", err)
+	}
+
+	watcher, err := newRepoWatcher(repoPath)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	watched := map[string]bool{}
+	for _, path := range watcher.WatchList() {
+		watched[path] = true
+	}
+	for dir, want := range map[string]bool{"web/src": true, "web/dist": false, "tmp": false} {
+		if got := watched[filepath.Join(repoPath, dir)]; got != want {
+			t.Errorf("Expected watching %s to be %v, got %v", dir, want, got)
+		}
+	}
+}
+
+func TestWaitForIdle(t *testing.T) {
+	appState := setupTestAppState(t)
+	const timeout = 50 * time.Millisecond
+
+	server := httptest.NewServer(appState.router())
+	defer server.Close()
+
+	// An open event stream keeps the server busy
+	resp, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("Failed to connect to events endpoint: %v", err)
+	}
+
+	idle := make(chan time.Time)
+	go func() {
+		appState.waitForIdle(timeout)
+		idle <- time.Now()
+	}()
+
+	select {
+	case <-idle:
+		t.Fatal("Server went idle while a request was open")
+	case <-time.After(4 * timeout):
+	}
+
+	closed := time.Now()
+	resp.Body.Close()
+
+	select {
+	case idleAt := <-idle:
+		if idleAt.Sub(closed) < timeout {
+			t.Errorf("Expected at least %s without requests, went idle after %s", timeout, idleAt.Sub(closed))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the server to go idle")
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	appState := setupTestAppState(t)
+	router := appState.router()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	for _, directive := range []string{"default-src 'self'", "object-src 'none'", "frame-ancestors 'none'", "connect-src 'self'"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("Expected CSP to contain %q, got %q", directive, csp)
+		}
+	}
+
+	for _, path := range []string{"/", "/api/status"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: expected X-Content-Type-Options nosniff, got %q", path, got)
+		}
+		if got := rec.Header().Get("Referrer-Policy"); got != "no-referrer" {
+			t.Errorf("%s: expected Referrer-Policy no-referrer, got %q", path, got)
+		}
+	}
+}
+
+func TestMethodNotAllowedSetsAllowHeader(t *testing.T) {
+	appState := setupTestAppState(t)
+	router := appState.router()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/diff", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("Expected Allow: GET, got %q", allow)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected JSON error body: %v", err)
+	}
+	if body["error"] == nil {
+		t.Errorf("Expected error field in body, got %v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/api/comments", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 for OPTIONS, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, POST" {
+		t.Errorf("Expected Allow: GET, POST, got %q", allow)
+	}
+}
+
+func TestRepoConfigCannotWidenCORSOrigins(t *testing.T) {
+	appState := setupTestAppState(t)
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	if err := os.MkdirAll(filepath.Join(configHome, "guck"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	global := "cors_origins = [\"vscode-webview://guck\"]\n"
+	if err := os.WriteFile(filepath.Join(configHome, "guck", "config.toml"), []byte(global), 0644); err != nil {
+		t.Fatalf("Failed to write global config: %v", err)
+	}
+	repoConfig := "cors_origins = [\"*\"]\nignore_whitespace = true\n"
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, config.RepoConfigFile), []byte(repoConfig), 0644); err != nil {
+		t.Fatalf("Failed to write repo config: %v", err)
+	}
+
+	started, err := newAppState(appState.RepoPath, "main", Options{})
+	if err != nil {
+		t.Fatalf("newAppState failed: %v", err)
+	}
+
+	if len(started.CORSOrigins) != 1 || started.CORSOrigins[0] != "vscode-webview://guck" {
+		t.Errorf("Expected only the global CORS origins, got %v", started.CORSOrigins)
+	}
+	if !started.IgnoreWhitespace {
+		t.Error("Expected other repo config keys to still apply")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.CORSOrigins = []string{"vscode-webview://guck"}
+	router := appState.router()
+
+	// Simple requests from a configured origin are allowed
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.Header.Set("Origin", "vscode-webview://guck")
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "vscode-webview://guck" {
+		t.Errorf("Expected the origin to be allowed, got %q", got)
+	}
+
+	// Preflight requests list the route's methods
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodOptions, "/api/comments", nil)
+	req.Header.Set("Origin", "vscode-webview://guck")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 for a preflight request, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "vscode-webview://guck" {
+		t.Errorf("Expected the preflight origin to be allowed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("Expected Access-Control-Allow-Methods: GET, POST, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("Expected Access-Control-Allow-Headers: Content-Type, got %q", got)
+	}
+
+	// Other origins get no CORS headers
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for an unknown origin, got %q", got)
+	}
+}
+
+func TestDiffMetadataOnlyAndFileEndpoint(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "README.md"), []byte("# Changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "draft.txt"), []byte("draft\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?metadata_only=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response DiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.UncommittedFiles) != 2 {
+		t.Fatalf("Expected 2 uncommitted files, got %+v", response.UncommittedFiles)
+	}
+	for _, file := range response.UncommittedFiles {
+		if file.Patch != "" {
+			t.Errorf("Expected no patch for %s, got %q", file.Path, file.Patch)
+		}
+		if file.Additions != 1 {
+			t.Errorf("Expected 1 addition for %s, got %d", file.Path, file.Additions)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff/file?path=README.md", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var file FileDiff
+	if err := json.NewDecoder(rec.Body).Decode(&file); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if file.Path != "README.md" || file.StagingStatus != "unstaged" || !strings.Contains(file.Patch, "+# Changed") {
+		t.Errorf("Expected README.md's unstaged patch, got %+v", file)
+	}
+
+	for _, tt := range []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusBadRequest},
+		{"?path=README.md&staging_status=staged", http.StatusNotFound},
+		{"?path=missing.txt", http.StatusNotFound},
+	} {
+		rec = httptest.NewRecorder()
+		appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff/file"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.code, rec.Code)
+		}
+	}
+}
+
+func TestDiffTruncatedAndFullFileEndpoint(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	appState.MaxPatchLines = 3
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "big.txt"), []byte("a\nb\nc\nd\ne\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	var file FileDiff
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff/file?path=big.txt", nil))
+	if err := json.NewDecoder(rec.Body).Decode(&file); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !file.Truncated || strings.Contains(file.Patch, "+e") || file.Additions != 5 {
+		t.Errorf("Expected a truncated patch counting all 5 additions, got %+v", file)
+	}
+
+	file = FileDiff{}
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff/file/full?path=big.txt", nil))
+	if err := json.NewDecoder(rec.Body).Decode(&file); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if file.Truncated || !strings.Contains(file.Patch, "+e") {
+		t.Errorf("Expected the whole patch, got %+v", file)
+	}
+}
+
+func TestDiffStream(t *testing.T) {
+	appState := setupTestAppState(t)
+	appState.BaseBranch = strings.TrimSpace(runGit(t, appState.RepoPath, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, appState.RepoPath, "checkout", "-q", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "feature.txt"), []byte("feature\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, appState.RepoPath, "add", "feature.txt")
+	runGit(t, appState.RepoPath, "commit", "-m", "Add feature")
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "draft.txt"), []byte("draft\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?stream=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON content type, got %q", got)
+	}
+
+	var lines []diffStreamLine
+	decoder := json.NewDecoder(rec.Body)
+	for decoder.More() {
+		var line diffStreamLine
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("Expected 2 files and a summary, got %d lines: %s", len(lines), rec.Body.String())
+	}
+	if lines[0].File == nil || lines[0].File.Path != "feature.txt" || lines[0].File.StagingStatus != "committed" {
+		t.Errorf("Expected the committed file first, got %+v", lines[0])
+	}
+	if lines[1].File == nil || lines[1].File.Path != "draft.txt" || lines[1].File.StagingStatus != "unstaged" {
+		t.Errorf("Expected the uncommitted file next, got %+v", lines[1])
+	}
+	if summary := lines[2].Summary; summary == nil || summary.Branch != "feature" || summary.BaseCommit == "" {
+		t.Errorf("Expected a summary last, got %+v", lines[2])
+	}
+}
+
+func TestDiffHandlerStagedView(t *testing.T) {
+	appState := setupTestAppState(t)
+
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "README.md"), []byte("# Staged\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, appState.RepoPath, "add", "README.md")
+	if err := os.WriteFile(filepath.Join(appState.RepoPath, "draft.txt"), []byte("draft\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?view=staged", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response DiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.View != ViewStaged {
+		t.Errorf("Expected view %s, got %s", ViewStaged, response.View)
+	}
+	if len(response.Files) != 1 || response.Files[0].Path != "README.md" {
+		t.Fatalf("Expected only staged README.md, got %+v", response.Files)
+	}
+	if response.Files[0].Language != "markdown" {
+		t.Errorf("Expected language markdown, got %q", response.Files[0].Language)
+	}
+	if len(response.UncommittedFiles) != 0 {
+		t.Errorf("Expected no uncommitted files in staged view, got %d", len(response.UncommittedFiles))
+	}
+
+	rec = httptest.NewRecorder()
+	appState.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?view=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown view, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mentionPattern matches @username tokens that don't follow a word character,
+// so email addresses aren't taken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9][A-Za-z0-9_.-]*)`)
+
+// parseMentions returns the distinct usernames @mentioned in text, in the
+// order they first appear
+func parseMentions(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Punctuation ending a sentence isn't part of the name
+		username := strings.TrimRight(match[1], ".-")
+		key := strings.ToLower(username)
+		if username == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, username)
+	}
+
+	return mentions
+}
+
+// GetMentions returns the comments across every branch and commit of a repo
+// that mention username, newest first. The match ignores case and a leading @.
+func (m *Manager) GetMentions(repoPath, username string) []*Comment {
+	username = strings.TrimPrefix(username, "@")
+
+	mentioned := []*Comment{}
+	for _, comment := range m.GetAllComments(repoPath) {
+		for _, mention := range comment.Mentions {
+			if strings.EqualFold(mention, username) {
+				mentioned = append(mentioned, comment)
+				break
+			}
+		}
+	}
+
+	sort.Slice(mentioned, func(i, j int) bool {
+		if mentioned[i].Timestamp != mentioned[j].Timestamp {
+			return mentioned[i].Timestamp > mentioned[j].Timestamp
+		}
+		return mentioned[i].ID > mentioned[j].ID
+	})
+
+	return mentioned
+}
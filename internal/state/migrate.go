@@ -0,0 +1,164 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CurrentVersion is the schema version of the state files this build writes.
+// Files without a version field are version 0.
+const CurrentVersion = 1
+
+// ErrNewerVersion reports a state file written by a newer guck, which this
+// build can't read without losing data
+var ErrNewerVersion = errors.New("state was written by a newer guck; upgrade guck to read it")
+
+// migration upgrades the stored branches of one repository, keyed by branch
+// and commit, from version from to from+1. It works on the decoded JSON so
+// it can reshape fields before they are read into RepoState.
+type migration struct {
+	from        int
+	description string
+	apply       func(branches map[string]interface{}) error
+}
+
+// migrations are applied in order to bring a file up to CurrentVersion. Add
+// one, and bump CurrentVersion, whenever the stored shape changes.
+var migrations = []migration{
+	{
+		from:        0,
+		description: "record the schema version",
+		apply:       func(map[string]interface{}) error { return nil },
+	},
+}
+
+// MigratedFile is a state file Migrate upgraded
+type MigratedFile struct {
+	Path        string `json:"path"`
+	FromVersion int    `json:"from_version"`
+	// Backup is a copy of the file as it was before the upgrade
+	Backup string `json:"backup"`
+}
+
+// migrateData upgrades the contents of a state file to CurrentVersion. It
+// returns the upgraded contents and the version they were stored as, and
+// fails for files written by a newer guck rather than misreading them.
+func migrateData(data []byte) ([]byte, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep timestamps exact
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if raw, ok := doc["version"].(json.Number); ok {
+		v, err := raw.Int64()
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid state version %s", raw)
+		}
+		version = int(v)
+	}
+	if version > CurrentVersion {
+		return nil, version, fmt.Errorf("%w (version %d, supported %d)", ErrNewerVersion, version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, version, nil
+	}
+
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		for _, branches := range storedBranches(doc) {
+			if err := m.apply(branches); err != nil {
+				return nil, version, fmt.Errorf("failed to migrate state from version %d (%s): %w", m.from, m.description, err)
+			}
+		}
+	}
+	doc["version"] = CurrentVersion
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to serialize state: %w", err)
+	}
+	return upgraded, version, nil
+}
+
+// storedBranches returns the branches of every repository in a decoded
+// state file: the "branches" of a per-repo file, or each entry of "repos" in
+// a repo-local or legacy file
+func storedBranches(doc map[string]interface{}) []map[string]interface{} {
+	var all []map[string]interface{}
+	if branches, ok := doc["branches"].(map[string]interface{}); ok {
+		all = append(all, branches)
+	}
+	if repos, ok := doc["repos"].(map[string]interface{}); ok {
+		for _, repo := range repos {
+			if branches, ok := repo.(map[string]interface{}); ok {
+				all = append(all, branches)
+			}
+		}
+	}
+	return all
+}
+
+// Migrate upgrades every state file older than CurrentVersion in place,
+// keeping a copy of each as it was next to it
+func (m *Manager) Migrate() ([]MigratedFile, error) {
+	var paths []string
+	if m.localRepo != "" {
+		paths = []string{m.stateFile}
+	} else {
+		entries, err := os.ReadDir(m.stateDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read state directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				paths = append(paths, filepath.Join(m.stateDir, entry.Name()))
+			}
+		}
+	}
+
+	migrated := []MigratedFile{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		upgraded, version, err := migrateData(data)
+		if err != nil {
+			return migrated, fmt.Errorf("%s: %w", path, err)
+		}
+		if version == CurrentVersion {
+			continue
+		}
+
+		backup := fmt.Sprintf("%s.v%d.bak", path, version)
+		if err := os.WriteFile(backup, data, 0644); err != nil {
+			return migrated, fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, upgraded, "", "  "); err != nil {
+			return migrated, fmt.Errorf("failed to serialize state: %w", err)
+		}
+		if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			return migrated, fmt.Errorf("failed to write state file: %w", err)
+		}
+
+		migrated = append(migrated, MigratedFile{Path: path, FromVersion: version, Backup: backup})
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,117 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unversionedRepoFile is a per-repo state file as written before versioning
+const unversionedRepoFile = `{
+  "repo_path": "/test/repo",
+  "branches": {
+    "main": {
+      "abc123": {
+        "viewed_files": ["main.go"],
+        "comments": [{"id": "1700000000-0", "file_path": "main.go", "text": "Keep me", "timestamp": 1700000000123, "branch": "main", "commit": "abc123", "resolved": false}],
+        "notes": []
+      }
+    }
+  }
+}`
+
+func TestLoadMigratesUnversionedState(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+
+	path := filepath.Join(tempDir, HashRepoPath("/test/repo")+".json")
+	if err := os.WriteFile(path, []byte(unversionedRepoFile), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	comments := manager.GetComments("/test/repo", "main", "abc123", nil)
+	if len(comments) != 1 || comments[0].Text != "Keep me" {
+		t.Fatalf("Expected the unversioned comment to be read, got %v", comments)
+	}
+	if comments[0].Timestamp != 1700000000123 {
+		t.Errorf("Expected the timestamp to be kept exactly, got %d", comments[0].Timestamp)
+	}
+
+	if err := manager.MarkFileViewed("/test/repo", "main", "abc123", "util.go"); err != nil {
+		t.Fatalf("MarkFileViewed failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read state file: %v", err)
+	}
+	if !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("Expected saved state to record the version, got %s", data)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+
+	oldPath := filepath.Join(tempDir, HashRepoPath("/test/repo")+".json")
+	if err := os.WriteFile(oldPath, []byte(unversionedRepoFile), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	if err := manager.MarkFileViewed("/test/current", "main", "abc123", "main.go"); err != nil {
+		t.Fatalf("MarkFileViewed failed: %v", err)
+	}
+
+	migrated, err := manager.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(migrated) != 1 || migrated[0].Path != oldPath || migrated[0].FromVersion != 0 {
+		t.Fatalf("Expected only the unversioned file to be migrated, got %+v", migrated)
+	}
+
+	backup, err := os.ReadFile(migrated[0].Backup)
+	if err != nil || string(backup) != unversionedRepoFile {
+		t.Errorf("Expected the original file to be backed up, got %q (%v)", backup, err)
+	}
+
+	var file repoFile
+	if err := readStateFile(oldPath, &file); err != nil {
+		t.Fatalf("Failed to read migrated file: %v", err)
+	}
+	if file.Version != CurrentVersion || len(file.Branches["main"]["abc123"].Comments) != 1 {
+		t.Errorf("Unexpected migrated file: %+v", file)
+	}
+
+	// Running again finds nothing to do
+	if migrated, err := manager.Migrate(); err != nil || len(migrated) != 0 {
+		t.Errorf("Expected no files to migrate, got %+v (%v)", migrated, err)
+	}
+}
+
+func TestNewerVersionIsNotOverwritten(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+
+	path := filepath.Join(tempDir, HashRepoPath("/test/repo")+".json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "repo_path": "/test/repo", "branches": {}}`), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	if _, err := manager.Migrate(); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected ErrNewerVersion from Migrate, got %v", err)
+	}
+
+	if err := manager.MarkFileViewed("/test/repo", "main", "abc123", "main.go"); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected saving to fail with ErrNewerVersion, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"version": 99`) {
+		t.Errorf("Expected the newer state file to be left alone, got %s", data)
+	}
+
+	localFile := filepath.Join(tempDir, "viewed.json")
+	if err := os.WriteFile(localFile, []byte(`{"version": 99, "repos": {}}`), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	if _, err := loadLocalManager(localFile, "/test/repo"); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected ErrNewerVersion from loadLocalManager, got %v", err)
+	}
+}
@@ -133,6 +133,30 @@ func TestDismissNote(t *testing.T) {
 	}
 }
 
+func TestDeleteNote(t *testing.T) {
+	manager, repoPath := setupTestManager(t)
+
+	branch := "main"
+	commit := "abc123"
+
+	note, err := manager.AddNote(repoPath, branch, commit, "file.go", nil, "Test note", "claude", "explanation", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+
+	if err := manager.DeleteNote(repoPath, branch, commit, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+
+	if notes := manager.GetNotes(repoPath, branch, commit, nil); len(notes) != 0 {
+		t.Errorf("Expected 0 notes after delete, got %d", len(notes))
+	}
+
+	if err := manager.DeleteNote(repoPath, branch, commit, note.ID); err == nil {
+		t.Error("Expected error when deleting a non-existent note")
+	}
+}
+
 func TestNoteMetadata(t *testing.T) {
 	manager, repoPath := setupTestManager(t)
 
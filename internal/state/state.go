@@ -1,24 +1,38 @@
 package state
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/tuist/guck/internal/config"
+	"github.com/tuist/guck/internal/git"
 )
 
 type Comment struct {
-	ID         string `json:"id"`
-	FilePath   string `json:"file_path"`
-	LineNumber *int   `json:"line_number,omitempty"`
-	Text       string `json:"text"`
-	Timestamp  int64  `json:"timestamp"`
-	Branch     string `json:"branch"`
-	Commit     string `json:"commit"`
-	Resolved   bool   `json:"resolved"`
-	ResolvedBy string `json:"resolved_by,omitempty"`
-	ResolvedAt int64  `json:"resolved_at,omitempty"`
+	ID         string            `json:"id"`
+	FilePath   string            `json:"file_path"`
+	LineNumber *int              `json:"line_number,omitempty"`
+	EndLine    *int              `json:"end_line,omitempty"` // Last line of a multi-line comment starting at LineNumber
+	Side       string            `json:"side,omitempty"`     // SideOld or SideNew; set only on line comments
+	Text       string            `json:"text"`
+	Suggestion string            `json:"suggestion,omitempty"` // Replacement content for the commented line(s)
+	Timestamp  int64             `json:"timestamp"`
+	Branch     string            `json:"branch"`
+	Commit     string            `json:"commit"`
+	Resolved   bool              `json:"resolved"`
+	ResolvedBy string            `json:"resolved_by,omitempty"`
+	ResolvedAt int64             `json:"resolved_at,omitempty"`
+	ParentID   string            `json:"parent_id,omitempty"` // ID of the comment this replies to
+	Author     string            `json:"author,omitempty"`    // e.g., "web-ui", "claude", "human:username"
+	Type       string            `json:"type,omitempty"`      // e.g., "issue", "question", "suggestion"
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Mentions   []string          `json:"mentions,omitempty"` // Usernames @mentioned in Text
 }
 
 type Note struct {
@@ -44,51 +58,131 @@ type RepoState struct {
 }
 
 type ViewedState struct {
-	Repos map[string]map[string]map[string]*RepoState `json:"repos"`
+	// Version is the schema version the state was stored as; see CurrentVersion
+	Version int                                         `json:"version"`
+	Repos   map[string]map[string]map[string]*RepoState `json:"repos"`
 }
 
+// Manager stores review state. Globally, each repo has its own file in the
+// state directory, loaded the first time the repo is used; a repo-local
+// manager keeps a single repo in .git/guck/viewed.json.
 type Manager struct {
+	state *ViewedState
+	// stateDir holds one file per repo for the global state
+	stateDir string
+	loaded   map[string]bool
+	// stateFile and localRepo are set for repo-local state, where stateFile
+	// only holds localRepo
 	stateFile string
-	state     *ViewedState
+	localRepo string
+	// unwritable holds, by repo, why a state file that failed to load must
+	// not be overwritten
+	unwritable map[string]error
 }
 
+// ErrParentNotFound reports a reply to a comment that doesn't exist
+var ErrParentNotFound = errors.New("parent comment not found")
+
+// UncommittedCommit is the commit viewed state of uncommitted changes is
+// recorded under, keyed by path and staging status
+const UncommittedCommit = "__uncommitted__"
+
+// localRepoKey is the key a repo-local state file stores its repo under, so
+// the file stays valid if the repo is moved
+const localRepoKey = "."
+
 func NewManager() (*Manager, error) {
 	stateDir, err := getStateDir()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := os.MkdirAll(stateDir, 0755); err != nil {
+	reposDir := filepath.Join(stateDir, reposDirName)
+	if err := os.MkdirAll(reposDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	stateFile := filepath.Join(stateDir, "viewed.json")
+	m := &Manager{
+		state:    &ViewedState{Repos: make(map[string]map[string]map[string]*RepoState)},
+		stateDir: reposDir,
+		loaded:   make(map[string]bool),
+	}
 
-	state := &ViewedState{
-		Repos: make(map[string]map[string]map[string]*RepoState),
+	if err := m.migrateLegacyState(filepath.Join(stateDir, legacyStateFile)); err != nil {
+		return nil, err
 	}
 
-	if _, err := os.Stat(stateFile); err == nil {
-		data, err := os.ReadFile(stateFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read state file: %w", err)
-		}
+	return m, nil
+}
+
+// NewManagerForRepo returns a manager for a repo's review state. When the
+// repo's state-location is "repo" the state lives in .git/guck/viewed.json
+// and is isolated from the global state; otherwise it is NewManager.
+func NewManagerForRepo(repoPath string) (*Manager, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo path: %w", err)
+	}
+
+	cfg, err := config.LoadForRepo(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.StateLocation != config.StateLocationRepo {
+		return NewManager()
+	}
+
+	gitRepo, err := git.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	gitDir, err := gitRepo.GitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	stateDir := filepath.Join(gitDir, "guck")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	return loadLocalManager(filepath.Join(stateDir, legacyStateFile), absPath)
+}
 
-		if err := json.Unmarshal(data, state); err != nil {
-			// If unmarshal fails, start with empty state
-			state = &ViewedState{
-				Repos: make(map[string]map[string]map[string]*RepoState),
+// loadLocalManager reads a repo-local state file, starting empty if it is
+// missing or corrupt, and re-keys its state to localRepo
+func loadLocalManager(stateFile, localRepo string) (*Manager, error) {
+	stored := &ViewedState{}
+	if _, err := os.Stat(stateFile); err == nil {
+		// Older versions are migrated. A corrupt file is moved aside before
+		// starting with empty state; state from a newer guck is never touched.
+		if err := readStateFile(stateFile, stored); err != nil {
+			if errors.Is(err, ErrNewerVersion) {
+				return nil, fmt.Errorf("%s: %w", stateFile, err)
+			}
+			if err := moveAside(stateFile, err); err != nil {
+				return nil, err
 			}
+			stored = &ViewedState{}
 		}
 	}
 
+	state := &ViewedState{Repos: make(map[string]map[string]map[string]*RepoState)}
+	if branches := stored.Repos[localRepoKey]; branches != nil {
+		state.Repos[localRepo] = branches
+	}
+
 	return &Manager{
-		stateFile: stateFile,
 		state:     state,
+		loaded:    map[string]bool{localRepo: true},
+		stateFile: stateFile,
+		localRepo: localRepo,
 	}, nil
 }
 
 func (m *Manager) IsFileViewed(repoPath, branch, commit, filePath string) bool {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -104,6 +198,8 @@ func (m *Manager) IsFileViewed(repoPath, branch, commit, filePath string) bool {
 }
 
 func (m *Manager) MarkFileViewed(repoPath, branch, commit, filePath string) error {
+	m.load(repoPath)
+
 	if m.state.Repos[repoPath] == nil {
 		m.state.Repos[repoPath] = make(map[string]map[string]*RepoState)
 	}
@@ -122,18 +218,20 @@ func (m *Manager) MarkFileViewed(repoPath, branch, commit, filePath string) erro
 
 	repoState := m.state.Repos[repoPath][branch][commit]
 
-	// Check if already viewed
+	// Already viewed files leave the state file untouched
 	for _, viewed := range repoState.ViewedFiles {
 		if viewed == filePath {
-			return m.save()
+			return nil
 		}
 	}
 
 	repoState.ViewedFiles = append(repoState.ViewedFiles, filePath)
-	return m.save()
+	return m.save(repoPath)
 }
 
 func (m *Manager) UnmarkFileViewed(repoPath, branch, commit, filePath string) error {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -143,15 +241,92 @@ func (m *Manager) UnmarkFileViewed(repoPath, branch, commit, filePath string) er
 						filtered = append(filtered, viewed)
 					}
 				}
-				repoState.ViewedFiles = filtered
+				// Only rewrite the state file if the file was viewed
+				if len(filtered) != len(repoState.ViewedFiles) {
+					repoState.ViewedFiles = filtered
+					return m.save(repoPath)
+				}
 			}
 		}
 	}
 
-	return m.save()
+	return nil
+}
+
+// GetViewedFiles returns the files marked as viewed at a branch and commit
+func (m *Manager) GetViewedFiles(repoPath, branch, commit string) []string {
+	m.load(repoPath)
+
+	viewedFiles := []string{}
+	if branches, ok := m.state.Repos[repoPath]; ok {
+		if commits, ok := branches[branch]; ok {
+			if repoState, ok := commits[commit]; ok {
+				viewedFiles = append(viewedFiles, repoState.ViewedFiles...)
+			}
+		}
+	}
+	return viewedFiles
+}
+
+// Sides of a diff a line comment can refer to. SideOld numbers lines of the
+// file before the change, SideNew lines of the file after it.
+const (
+	SideOld = "old"
+	SideNew = "new"
+)
+
+// ValidateSide checks that side names a side of the diff. An empty side
+// defaults to SideNew.
+func ValidateSide(side string) error {
+	switch side {
+	case "", SideOld, SideNew:
+		return nil
+	default:
+		return fmt.Errorf("side must be %q or %q, got %q", SideOld, SideNew, side)
+	}
+}
+
+// ValidateLineRange checks that an end line follows a start line, the way a
+// multi-line comment spans LineNumber through EndLine
+func ValidateLineRange(lineNumber, endLine *int) error {
+	if endLine == nil {
+		return nil
+	}
+	if lineNumber == nil {
+		return fmt.Errorf("end_line requires line_number")
+	}
+	if *endLine < *lineNumber {
+		return fmt.Errorf("end_line %d is before line_number %d", *endLine, *lineNumber)
+	}
+	return nil
 }
 
-func (m *Manager) AddComment(repoPath, branch, commit, filePath string, lineNumber *int, text string) (*Comment, error) {
+// AddComment records a comment on a file, a line, or the lines lineNumber
+// through endLine. An endLine equal to lineNumber makes a single-line comment.
+// Line numbers refer to the given side of the diff, SideNew when side is
+// empty; file comments have no side.
+func (m *Manager) AddComment(repoPath, branch, commit, filePath string, lineNumber, endLine *int, side, text, suggestion, author, commentType, parentID string, metadata map[string]string) (*Comment, error) {
+	m.load(repoPath)
+
+	if err := ValidateLineRange(lineNumber, endLine); err != nil {
+		return nil, err
+	}
+	if endLine != nil && *endLine == *lineNumber {
+		endLine = nil
+	}
+	if err := ValidateSide(side); err != nil {
+		return nil, err
+	}
+	if lineNumber == nil {
+		side = ""
+	} else if side == "" {
+		side = SideNew
+	}
+
+	if parentID != "" && m.findComment(repoPath, parentID) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrParentNotFound, parentID)
+	}
+
 	if m.state.Repos[repoPath] == nil {
 		m.state.Repos[repoPath] = make(map[string]map[string]*RepoState)
 	}
@@ -172,26 +347,54 @@ func (m *Manager) AddComment(repoPath, branch, commit, filePath string, lineNumb
 
 	timestamp := time.Now().Unix()
 	comment := &Comment{
-		ID:         fmt.Sprintf("%d-%d", timestamp, len(repoState.Comments)),
+		ID:         nextID(timestamp, m.commentIDs(repoPath)),
 		FilePath:   filePath,
 		LineNumber: lineNumber,
+		EndLine:    endLine,
+		Side:       side,
 		Text:       text,
+		Suggestion: suggestion,
 		Timestamp:  timestamp,
 		Branch:     branch,
 		Commit:     commit,
 		Resolved:   false,
+		ParentID:   parentID,
+		Author:     author,
+		Type:       commentType,
+		Metadata:   metadata,
+		Mentions:   parseMentions(text),
 	}
 
 	repoState.Comments = append(repoState.Comments, comment)
 
-	if err := m.save(); err != nil {
+	if err := m.save(repoPath); err != nil {
 		return nil, err
 	}
 
 	return comment, nil
 }
 
+// nextID returns an ID for an item created at timestamp, numbering it after
+// the repo's existing IDs from the same second. IDs stay unique when items are
+// deleted or moved between commits, unlike numbering by the bucket's length.
+func nextID(timestamp int64, ids []string) string {
+	prefix := fmt.Sprintf("%d-", timestamp)
+	next := 0
+	for _, id := range ids {
+		suffix, ok := strings.CutPrefix(id, prefix)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(suffix); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return prefix + strconv.Itoa(next)
+}
+
 func (m *Manager) GetComments(repoPath, branch, commit string, filePath *string) []*Comment {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -214,6 +417,8 @@ func (m *Manager) GetComments(repoPath, branch, commit string, filePath *string)
 }
 
 func (m *Manager) ResolveComment(repoPath, branch, commit, commentID, resolvedBy string) error {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -222,7 +427,7 @@ func (m *Manager) ResolveComment(repoPath, branch, commit, commentID, resolvedBy
 						comment.Resolved = true
 						comment.ResolvedBy = resolvedBy
 						comment.ResolvedAt = time.Now().Unix()
-						return m.save()
+						return m.save(repoPath)
 					}
 				}
 			}
@@ -232,7 +437,175 @@ func (m *Manager) ResolveComment(repoPath, branch, commit, commentID, resolvedBy
 	return fmt.Errorf("comment not found")
 }
 
+// UnresolveComment reopens a resolved comment, clearing who resolved it and
+// when. It fails if the comment is not resolved.
+func (m *Manager) UnresolveComment(repoPath, branch, commit, commentID string) error {
+	m.load(repoPath)
+
+	if branches, ok := m.state.Repos[repoPath]; ok {
+		if commits, ok := branches[branch]; ok {
+			if repoState, ok := commits[commit]; ok {
+				for _, comment := range repoState.Comments {
+					if comment.ID == commentID {
+						if !comment.Resolved {
+							return fmt.Errorf("comment is not resolved")
+						}
+						comment.Resolved = false
+						comment.ResolvedBy = ""
+						comment.ResolvedAt = 0
+						return m.save(repoPath)
+					}
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("comment not found")
+}
+
+// DeleteComment deletes a comment along with its replies, which may have been
+// migrated to other commits of the repo
+func (m *Manager) DeleteComment(repoPath, branch, commit, commentID string) error {
+	m.load(repoPath)
+
+	found := false
+	if repoState := m.state.Repos[repoPath][branch][commit]; repoState != nil {
+		for _, comment := range repoState.Comments {
+			if comment.ID == commentID {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("comment not found")
+	}
+
+	// Collect the replies of deleted comments until no more are found
+	deleted := map[string]bool{commentID: true}
+	for grew := true; grew; {
+		grew = false
+		for _, id := range m.commentReplies(repoPath, deleted) {
+			deleted[id] = true
+			grew = true
+		}
+	}
+
+	for _, commits := range m.state.Repos[repoPath] {
+		for _, repoState := range commits {
+			kept := repoState.Comments[:0]
+			for _, comment := range repoState.Comments {
+				if !deleted[comment.ID] {
+					kept = append(kept, comment)
+				}
+			}
+			repoState.Comments = kept
+		}
+	}
+
+	return m.save(repoPath)
+}
+
+// commentReplies returns the IDs of a repo's comments that reply to one of
+// parents without being in it
+func (m *Manager) commentReplies(repoPath string, parents map[string]bool) []string {
+	var ids []string
+	for _, commits := range m.state.Repos[repoPath] {
+		for _, repoState := range commits {
+			for _, comment := range repoState.Comments {
+				if comment.ParentID != "" && parents[comment.ParentID] && !parents[comment.ID] {
+					ids = append(ids, comment.ID)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// MigrateComments moves the unresolved comments recorded at other commits of a
+// branch into the toCommit bucket, returning how many were moved. Resolved
+// comments stay where they were made.
+func (m *Manager) MigrateComments(repoPath, branch, toCommit string) (int, error) {
+	m.load(repoPath)
+
+	commits, ok := m.state.Repos[repoPath][branch]
+	if !ok {
+		return 0, nil
+	}
+
+	moved := []*Comment{}
+	for commit, repoState := range commits {
+		if commit == toCommit || repoState == nil {
+			continue
+		}
+
+		kept := []*Comment{}
+		for _, comment := range repoState.Comments {
+			if comment.Resolved {
+				kept = append(kept, comment)
+				continue
+			}
+			comment.Commit = toCommit
+			moved = append(moved, comment)
+		}
+		repoState.Comments = kept
+	}
+
+	if len(moved) == 0 {
+		return 0, nil
+	}
+
+	if commits[toCommit] == nil {
+		commits[toCommit] = &RepoState{
+			ViewedFiles: []string{},
+			Comments:    []*Comment{},
+			Notes:       []*Note{},
+		}
+	}
+
+	// Keep the migrated comments in the order they were written
+	sort.SliceStable(moved, func(i, j int) bool {
+		return moved[i].Timestamp < moved[j].Timestamp
+	})
+	commits[toCommit].Comments = append(commits[toCommit].Comments, moved...)
+
+	if err := m.save(repoPath); err != nil {
+		return 0, err
+	}
+
+	return len(moved), nil
+}
+
+// findComment looks up a comment by ID across every branch and commit of a repo
+func (m *Manager) findComment(repoPath, commentID string) *Comment {
+	for _, commits := range m.state.Repos[repoPath] {
+		for _, repoState := range commits {
+			for _, comment := range repoState.Comments {
+				if comment.ID == commentID {
+					return comment
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// commentIDs returns the IDs of every comment of a repo
+func (m *Manager) commentIDs(repoPath string) []string {
+	var ids []string
+	for _, commits := range m.state.Repos[repoPath] {
+		for _, repoState := range commits {
+			for _, comment := range repoState.Comments {
+				ids = append(ids, comment.ID)
+			}
+		}
+	}
+	return ids
+}
+
 func (m *Manager) GetAllComments(repoPath string) []*Comment {
+	m.load(repoPath)
+
 	var allComments []*Comment
 
 	if branches, ok := m.state.Repos[repoPath]; ok {
@@ -247,6 +620,8 @@ func (m *Manager) GetAllComments(repoPath string) []*Comment {
 }
 
 func (m *Manager) AddNote(repoPath, branch, commit, filePath string, lineNumber *int, text, author, noteType string, metadata map[string]string) (*Note, error) {
+	m.load(repoPath)
+
 	if m.state.Repos[repoPath] == nil {
 		m.state.Repos[repoPath] = make(map[string]map[string]*RepoState)
 	}
@@ -267,7 +642,7 @@ func (m *Manager) AddNote(repoPath, branch, commit, filePath string, lineNumber
 
 	timestamp := time.Now().Unix()
 	note := &Note{
-		ID:         fmt.Sprintf("%d-%d", timestamp, len(repoState.Notes)),
+		ID:         nextID(timestamp, m.noteIDs(repoPath)),
 		FilePath:   filePath,
 		LineNumber: lineNumber,
 		Text:       text,
@@ -282,7 +657,7 @@ func (m *Manager) AddNote(repoPath, branch, commit, filePath string, lineNumber
 
 	repoState.Notes = append(repoState.Notes, note)
 
-	if err := m.save(); err != nil {
+	if err := m.save(repoPath); err != nil {
 		return nil, err
 	}
 
@@ -290,6 +665,8 @@ func (m *Manager) AddNote(repoPath, branch, commit, filePath string, lineNumber
 }
 
 func (m *Manager) GetNotes(repoPath, branch, commit string, filePath *string) []*Note {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -311,7 +688,22 @@ func (m *Manager) GetNotes(repoPath, branch, commit string, filePath *string) []
 	return []*Note{}
 }
 
+// noteIDs returns the IDs of every note of a repo
+func (m *Manager) noteIDs(repoPath string) []string {
+	var ids []string
+	for _, commits := range m.state.Repos[repoPath] {
+		for _, repoState := range commits {
+			for _, note := range repoState.Notes {
+				ids = append(ids, note.ID)
+			}
+		}
+	}
+	return ids
+}
+
 func (m *Manager) GetAllNotes(repoPath string) []*Note {
+	m.load(repoPath)
+
 	var allNotes []*Note
 
 	if branches, ok := m.state.Repos[repoPath]; ok {
@@ -326,6 +718,8 @@ func (m *Manager) GetAllNotes(repoPath string) []*Note {
 }
 
 func (m *Manager) DismissNote(repoPath, branch, commit, noteID, dismissedBy string) error {
+	m.load(repoPath)
+
 	if branches, ok := m.state.Repos[repoPath]; ok {
 		if commits, ok := branches[branch]; ok {
 			if repoState, ok := commits[commit]; ok {
@@ -334,7 +728,7 @@ func (m *Manager) DismissNote(repoPath, branch, commit, noteID, dismissedBy stri
 						note.Dismissed = true
 						note.DismissedBy = dismissedBy
 						note.DismissedAt = time.Now().Unix()
-						return m.save()
+						return m.save(repoPath)
 					}
 				}
 			}
@@ -344,17 +738,217 @@ func (m *Manager) DismissNote(repoPath, branch, commit, noteID, dismissedBy stri
 	return fmt.Errorf("note not found")
 }
 
-func (m *Manager) save() error {
-	data, err := json.MarshalIndent(m.state, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to serialize state: %w", err)
+// RestoreNote brings a dismissed note back into the active list, clearing who
+// dismissed it and when. It fails if the note is not dismissed.
+func (m *Manager) RestoreNote(repoPath, branch, commit, noteID string) error {
+	m.load(repoPath)
+
+	if branches, ok := m.state.Repos[repoPath]; ok {
+		if commits, ok := branches[branch]; ok {
+			if repoState, ok := commits[commit]; ok {
+				for _, note := range repoState.Notes {
+					if note.ID == noteID {
+						if !note.Dismissed {
+							return fmt.Errorf("note is not dismissed")
+						}
+						note.Dismissed = false
+						note.DismissedBy = ""
+						note.DismissedAt = 0
+						return m.save(repoPath)
+					}
+				}
+			}
+		}
 	}
 
-	if err := os.WriteFile(m.stateFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write state file: %w", err)
+	return fmt.Errorf("note not found")
+}
+
+func (m *Manager) DeleteNote(repoPath, branch, commit, noteID string) error {
+	m.load(repoPath)
+
+	if branches, ok := m.state.Repos[repoPath]; ok {
+		if commits, ok := branches[branch]; ok {
+			if repoState, ok := commits[commit]; ok {
+				for i, note := range repoState.Notes {
+					if note.ID == noteID {
+						repoState.Notes = append(repoState.Notes[:i], repoState.Notes[i+1:]...)
+						return m.save(repoPath)
+					}
+				}
+			}
+		}
 	}
 
-	return nil
+	return fmt.Errorf("note not found")
+}
+
+// RepoPaths returns every repository with stored state, sorted by path
+func (m *Manager) RepoPaths() []string {
+	m.loadAll()
+
+	paths := make([]string, 0, len(m.state.Repos))
+	for repoPath := range m.state.Repos {
+		paths = append(paths, repoPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// RepoBranches returns the stored state for a repository keyed by branch and
+// commit. The returned map is owned by the manager and must not be modified.
+func (m *Manager) RepoBranches(repoPath string) map[string]map[string]*RepoState {
+	m.load(repoPath)
+
+	return m.state.Repos[repoPath]
+}
+
+// ImportRepo merges branch/commit state into a repository, replacing any
+// existing entries for the same branch and commit
+func (m *Manager) ImportRepo(repoPath string, branches map[string]map[string]*RepoState) error {
+	m.load(repoPath)
+
+	if m.state.Repos[repoPath] == nil {
+		m.state.Repos[repoPath] = make(map[string]map[string]*RepoState)
+	}
+
+	for branch, commits := range branches {
+		if m.state.Repos[repoPath][branch] == nil {
+			m.state.Repos[repoPath][branch] = make(map[string]*RepoState)
+		}
+		for commit, repoState := range commits {
+			m.state.Repos[repoPath][branch][commit] = repoState
+		}
+	}
+
+	return m.save(repoPath)
+}
+
+// PruneCommits removes the state of every commit of a repository that keep
+// rejects, drops the branches and repository left empty, and saves the
+// repository. It returns how many commit entries were removed.
+func (m *Manager) PruneCommits(repoPath string, keep func(commit string) bool) (int, error) {
+	m.load(repoPath)
+
+	branches, ok := m.state.Repos[repoPath]
+	if !ok {
+		return 0, nil
+	}
+
+	removed := 0
+	for branch, commits := range branches {
+		for commit := range commits {
+			if !keep(commit) {
+				delete(commits, commit)
+				removed++
+			}
+		}
+		if len(commits) == 0 {
+			delete(branches, branch)
+		}
+	}
+	if len(branches) == 0 {
+		delete(m.state.Repos, repoPath)
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, m.save(repoPath)
+}
+
+// VacuumStats reports what Vacuum removed from the state file
+type VacuumStats struct {
+	EmptyBuckets         int   `json:"empty_buckets"`
+	DuplicateViewedFiles int   `json:"duplicate_viewed_files"`
+	DuplicateComments    int   `json:"duplicate_comments"`
+	DuplicateNotes       int   `json:"duplicate_notes"`
+	BytesBefore          int64 `json:"bytes_before"`
+	BytesAfter           int64 `json:"bytes_after"`
+}
+
+// Vacuum compacts the state by removing empty repo/branch/commit buckets,
+// duplicate viewed files, and comments or notes with duplicate IDs (keeping
+// the first occurrence), then rewrites the state files
+func (m *Manager) Vacuum() (*VacuumStats, error) {
+	stats := &VacuumStats{BytesBefore: m.storedBytes()}
+
+	m.loadAll()
+	repoPaths := make([]string, 0, len(m.state.Repos))
+	for repoPath, branches := range m.state.Repos {
+		repoPaths = append(repoPaths, repoPath)
+		for branch, commits := range branches {
+			for commit, repoState := range commits {
+				if repoState == nil {
+					delete(commits, commit)
+					stats.EmptyBuckets++
+					continue
+				}
+
+				seenFiles := make(map[string]bool)
+				viewedFiles := []string{}
+				for _, filePath := range repoState.ViewedFiles {
+					if seenFiles[filePath] {
+						stats.DuplicateViewedFiles++
+						continue
+					}
+					seenFiles[filePath] = true
+					viewedFiles = append(viewedFiles, filePath)
+				}
+				repoState.ViewedFiles = viewedFiles
+
+				seenComments := make(map[string]bool)
+				comments := []*Comment{}
+				for _, comment := range repoState.Comments {
+					if comment == nil || seenComments[comment.ID] {
+						stats.DuplicateComments++
+						continue
+					}
+					seenComments[comment.ID] = true
+					comments = append(comments, comment)
+				}
+				repoState.Comments = comments
+
+				seenNotes := make(map[string]bool)
+				notes := []*Note{}
+				for _, note := range repoState.Notes {
+					if note == nil || seenNotes[note.ID] {
+						stats.DuplicateNotes++
+						continue
+					}
+					seenNotes[note.ID] = true
+					notes = append(notes, note)
+				}
+				repoState.Notes = notes
+
+				if len(viewedFiles) == 0 && len(comments) == 0 && len(notes) == 0 {
+					delete(commits, commit)
+					stats.EmptyBuckets++
+				}
+			}
+
+			if len(commits) == 0 {
+				delete(branches, branch)
+				stats.EmptyBuckets++
+			}
+		}
+
+		if len(branches) == 0 {
+			delete(m.state.Repos, repoPath)
+			stats.EmptyBuckets++
+		}
+	}
+
+	// Emptied repos are saved too, which removes their files
+	for _, repoPath := range repoPaths {
+		if err := m.save(repoPath); err != nil {
+			return nil, err
+		}
+	}
+
+	stats.BytesAfter = m.storedBytes()
+
+	return stats, nil
 }
 
 func getStateDir() (string, error) {
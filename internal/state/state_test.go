@@ -2,7 +2,9 @@ package state
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -11,15 +13,15 @@ func setupTestManager(t *testing.T) (*Manager, string) {
 
 	// Create a temporary directory for test state
 	tempDir := t.TempDir()
-	stateFile := filepath.Join(tempDir, "test_viewed.json")
 
 	state := &ViewedState{
 		Repos: make(map[string]map[string]map[string]*RepoState),
 	}
 
 	manager := &Manager{
-		stateFile: stateFile,
-		state:     state,
+		state:    state,
+		stateDir: tempDir,
+		loaded:   make(map[string]bool),
 	}
 
 	return manager, tempDir
@@ -50,7 +52,7 @@ func TestMarkFileViewed(t *testing.T) {
 	}
 
 	// Verify state file was created
-	if _, err := os.Stat(manager.stateFile); os.IsNotExist(err) {
+	if _, err := os.Stat(manager.repoStateFile(repoPath)); os.IsNotExist(err) {
 		t.Error("State file should exist after marking file as viewed")
 	}
 }
@@ -81,6 +83,31 @@ func TestMarkFileViewedIdempotent(t *testing.T) {
 	}
 }
 
+func TestViewedNoOpsSkipWrites(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	if err := manager.MarkFileViewed(repoPath, "main", "abc123", "test.go"); err != nil {
+		t.Fatalf("Failed to mark file as viewed: %v", err)
+	}
+
+	stateFile := manager.repoStateFile(repoPath)
+	if err := os.Remove(stateFile); err != nil {
+		t.Fatalf("Failed to remove state file: %v", err)
+	}
+
+	// Neither call changes anything, so neither should write
+	if err := manager.MarkFileViewed(repoPath, "main", "abc123", "test.go"); err != nil {
+		t.Fatalf("Failed to mark file as viewed: %v", err)
+	}
+	if err := manager.UnmarkFileViewed(repoPath, "main", "abc123", "other.go"); err != nil {
+		t.Fatalf("Failed to unmark file: %v", err)
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Error("Expected no-op viewed changes not to rewrite the state file")
+	}
+}
+
 func TestUnmarkFileViewed(t *testing.T) {
 	manager, _ := setupTestManager(t)
 
@@ -122,7 +149,7 @@ func TestAddComment(t *testing.T) {
 	lineNumber := 42
 	text := "This is a test comment"
 
-	comment, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, text)
+	comment, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, nil, "", text, "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -167,17 +194,17 @@ func TestGetComments(t *testing.T) {
 	lineNumber := 42
 
 	// Add comments
-	_, err := manager.AddComment(repoPath, branch, commit, filePath1, &lineNumber, "Comment 1")
+	_, err := manager.AddComment(repoPath, branch, commit, filePath1, &lineNumber, nil, "", "Comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, filePath2, &lineNumber, "Comment 2")
+	_, err = manager.AddComment(repoPath, branch, commit, filePath2, &lineNumber, nil, "", "Comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, filePath1, &lineNumber, "Comment 3")
+	_, err = manager.AddComment(repoPath, branch, commit, filePath1, &lineNumber, nil, "", "Comment 3", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -210,7 +237,7 @@ func TestResolveComment(t *testing.T) {
 	lineNumber := 42
 	resolvedBy := "test-user"
 
-	comment, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, "Test comment")
+	comment, err := manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, nil, "", "Test comment", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -245,6 +272,294 @@ func TestResolveComment(t *testing.T) {
 	}
 }
 
+func TestUnresolveComment(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	comment, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", nil, nil, "", "Test comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	if err := manager.UnresolveComment("/test/repo", "main", "abc123", comment.ID); err == nil {
+		t.Error("Expected an error reopening a comment that is not resolved")
+	}
+
+	if err := manager.ResolveComment("/test/repo", "main", "abc123", comment.ID, "test-user"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if err := manager.UnresolveComment("/test/repo", "main", "abc123", comment.ID); err != nil {
+		t.Fatalf("Failed to reopen comment: %v", err)
+	}
+
+	reopened := manager.GetComments("/test/repo", "main", "abc123", nil)[0]
+	if reopened.Resolved || reopened.ResolvedBy != "" || reopened.ResolvedAt != 0 {
+		t.Errorf("Expected resolution cleared, got %+v", reopened)
+	}
+
+	if err := manager.UnresolveComment("/test/repo", "main", "abc123", "missing"); err == nil {
+		t.Error("Expected an error for an unknown comment")
+	}
+}
+
+func TestAddCommentAttribution(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	metadata := map[string]string{"model": "claude-sonnet-4"}
+	comment, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", nil, nil, "", "Nit", "", "claude", "suggestion", "", metadata)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	comments := manager.GetComments("/test/repo", "main", "abc123", nil)
+	if len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Fatalf("Expected the added comment to be stored, got %v", comments)
+	}
+
+	if comments[0].Author != "claude" || comments[0].Type != "suggestion" || comments[0].Metadata["model"] != "claude-sonnet-4" {
+		t.Errorf("Attribution not persisted: %+v", comments[0])
+	}
+}
+
+func TestAddCommentReply(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	branch := "main"
+	commit := "abc123"
+	lineNumber := 42
+
+	parent, err := manager.AddComment(repoPath, branch, commit, "test.go", &lineNumber, nil, "", "Why this approach?", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	reply, err := manager.AddComment(repoPath, branch, commit, "test.go", &lineNumber, nil, "", "It avoids a lock", "", "", "", parent.ID, nil)
+	if err != nil {
+		t.Fatalf("Failed to add reply: %v", err)
+	}
+
+	if reply.ParentID != parent.ID {
+		t.Errorf("Expected parent ID %s, got %s", parent.ID, reply.ParentID)
+	}
+
+	if parent.ParentID != "" {
+		t.Errorf("Top-level comment should have no parent, got %s", parent.ParentID)
+	}
+
+	// Replying to an unknown comment should fail
+	if _, err := manager.AddComment(repoPath, branch, commit, "test.go", &lineNumber, nil, "", "Orphan", "", "", "", "missing-id", nil); err == nil {
+		t.Error("Expected error when replying to a non-existent comment")
+	}
+}
+
+func TestAddCommentLineRange(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	start, end := 40, 55
+	comment, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", &start, &end, "", "Extract this block", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if comment.EndLine == nil || *comment.EndLine != end {
+		t.Errorf("Expected end line %d, got %v", end, comment.EndLine)
+	}
+
+	// A range that starts and ends on the same line is a single-line comment
+	single, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", &start, &start, "", "Nit", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if single.EndLine != nil {
+		t.Errorf("Expected no end line, got %d", *single.EndLine)
+	}
+
+	before := 30
+	if _, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", &start, &before, "", "Backwards", "", "", "", "", nil); err == nil {
+		t.Error("Expected error when end line is before line number")
+	}
+	if _, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", nil, &end, "", "No start", "", "", "", "", nil); err == nil {
+		t.Error("Expected error when end line is set without line number")
+	}
+}
+
+func TestAddCommentSide(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	lineNumber := 42
+	comment, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", &lineNumber, nil, "", "Defaults to new", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if comment.Side != SideNew {
+		t.Errorf("Expected side %q, got %q", SideNew, comment.Side)
+	}
+
+	comment, err = manager.AddComment("/test/repo", "main", "abc123", "test.go", &lineNumber, nil, SideOld, "On a deleted line", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if comment.Side != SideOld {
+		t.Errorf("Expected side %q, got %q", SideOld, comment.Side)
+	}
+
+	// File comments have no line, so no side
+	comment, err = manager.AddComment("/test/repo", "main", "abc123", "test.go", nil, nil, SideOld, "Whole file", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if comment.Side != "" {
+		t.Errorf("Expected no side on a file comment, got %q", comment.Side)
+	}
+
+	if _, err := manager.AddComment("/test/repo", "main", "abc123", "test.go", &lineNumber, nil, "left", "Bad side", "", "", "", "", nil); err == nil {
+		t.Error("Expected error for an unknown side")
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	branch := "main"
+	commit := "abc123"
+	lineNumber := 42
+
+	first, err := manager.AddComment(repoPath, branch, commit, "test.go", &lineNumber, nil, "", "First comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	second, err := manager.AddComment(repoPath, branch, commit, "test.go", &lineNumber, nil, "", "Second comment", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	if err := manager.DeleteComment(repoPath, branch, commit, first.ID); err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+
+	comments := manager.GetComments(repoPath, branch, commit, nil)
+	if len(comments) != 1 {
+		t.Fatalf("Expected 1 comment after delete, got %d", len(comments))
+	}
+
+	if comments[0].ID != second.ID {
+		t.Errorf("Expected remaining comment %s, got %s", second.ID, comments[0].ID)
+	}
+
+	// Deleting again should report the comment as missing
+	if err := manager.DeleteComment(repoPath, branch, commit, first.ID); err == nil {
+		t.Error("Expected error when deleting a non-existent comment")
+	}
+}
+
+func TestDeleteCommentDeletesReplies(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	branch := "main"
+
+	parent, err := manager.AddComment(repoPath, branch, "abc123", "test.go", nil, nil, "", "Parent", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	reply, err := manager.AddComment(repoPath, branch, "abc123", "test.go", nil, nil, "", "Reply", "", "", "", parent.ID, nil)
+	if err != nil {
+		t.Fatalf("Failed to add reply: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, branch, "abc123", "test.go", nil, nil, "", "Nested reply", "", "", "", reply.ID, nil); err != nil {
+		t.Fatalf("Failed to add reply: %v", err)
+	}
+	other, err := manager.AddComment(repoPath, branch, "abc123", "test.go", nil, nil, "", "Other", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	// A resolved parent stays behind while its replies move to the new commit
+	if err := manager.ResolveComment(repoPath, branch, "abc123", parent.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if _, err := manager.MigrateComments(repoPath, branch, "def456"); err != nil {
+		t.Fatalf("Failed to migrate comments: %v", err)
+	}
+
+	if err := manager.DeleteComment(repoPath, branch, "abc123", parent.ID); err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+
+	comments := manager.GetAllComments(repoPath)
+	if len(comments) != 1 || comments[0].ID != other.ID {
+		t.Errorf("Expected only the unrelated comment to remain, got %v", comments)
+	}
+}
+
+func TestAddAfterDeleteGetsUniqueID(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	branch := "main"
+	commit := "abc123"
+
+	first, err := manager.AddComment(repoPath, branch, commit, "test.go", nil, nil, "", "First", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	second, err := manager.AddComment(repoPath, branch, commit, "test.go", nil, nil, "", "Second", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if err := manager.DeleteComment(repoPath, branch, commit, first.ID); err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+	third, err := manager.AddComment(repoPath, branch, commit, "test.go", nil, nil, "", "Third", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	if third.ID == second.ID {
+		t.Fatalf("Expected a new ID after deleting a comment, got %s twice", third.ID)
+	}
+	if err := manager.ResolveComment(repoPath, branch, commit, third.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if second.Resolved || !third.Resolved {
+		t.Error("Expected only the new comment to be resolved")
+	}
+
+	firstNote, err := manager.AddNote(repoPath, branch, commit, "test.go", nil, "First", "claude", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	secondNote, err := manager.AddNote(repoPath, branch, commit, "test.go", nil, "Second", "claude", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	if err := manager.DeleteNote(repoPath, branch, commit, firstNote.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	thirdNote, err := manager.AddNote(repoPath, branch, commit, "test.go", nil, "Third", "claude", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	if thirdNote.ID == secondNote.ID {
+		t.Errorf("Expected a new ID after deleting a note, got %s twice", thirdNote.ID)
+	}
+
+	// Migrating empties the old bucket without freeing the moved comments' IDs
+	if _, err := manager.MigrateComments(repoPath, branch, "def456"); err != nil {
+		t.Fatalf("Failed to migrate comments: %v", err)
+	}
+	fourth, err := manager.AddComment(repoPath, branch, commit, "test.go", nil, nil, "", "Fourth", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	for _, comment := range manager.GetComments(repoPath, branch, "def456", nil) {
+		if comment.ID == fourth.ID {
+			t.Errorf("Expected a new ID after migrating comments, got %s twice", fourth.ID)
+		}
+	}
+}
+
 func TestGetAllComments(t *testing.T) {
 	manager, _ := setupTestManager(t)
 
@@ -252,17 +567,17 @@ func TestGetAllComments(t *testing.T) {
 	lineNumber := 42
 
 	// Add comments across different branches and commits
-	_, err := manager.AddComment(repoPath, "main", "commit1", "file1.go", &lineNumber, "Comment 1")
+	_, err := manager.AddComment(repoPath, "main", "commit1", "file1.go", &lineNumber, nil, "", "Comment 1", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, "main", "commit2", "file2.go", &lineNumber, "Comment 2")
+	_, err = manager.AddComment(repoPath, "main", "commit2", "file2.go", &lineNumber, nil, "", "Comment 2", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, "feature", "commit3", "file3.go", &lineNumber, "Comment 3")
+	_, err = manager.AddComment(repoPath, "feature", "commit3", "file3.go", &lineNumber, nil, "", "Comment 3", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -330,13 +645,13 @@ func TestPersistence(t *testing.T) {
 		t.Fatalf("Failed to mark file as viewed: %v", err)
 	}
 
-	_, err = manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, "Test comment")
+	_, err = manager.AddComment(repoPath, branch, commit, filePath, &lineNumber, nil, "", "Test comment", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
 
-	// Verify state file exists and has content
-	stateFile := filepath.Join(tempDir, "test_viewed.json")
+	// Verify the repo's state file exists and has content
+	stateFile := filepath.Join(tempDir, HashRepoPath(repoPath)+".json")
 	data, err := os.ReadFile(stateFile)
 	if err != nil {
 		t.Fatalf("Failed to read state file: %v", err)
@@ -360,6 +675,32 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+func TestCorruptStateIsMovedAside(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+
+	// A file cut short by an interrupted write
+	corrupt := `{"repo_path": "/test/repo", "branches": {"main": {"abc123": {"viewed_files": ["main.go"`
+	path := filepath.Join(tempDir, HashRepoPath("/test/repo")+".json")
+	if err := os.WriteFile(path, []byte(corrupt), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	if viewed := manager.GetViewedFiles("/test/repo", "main", "abc123"); len(viewed) != 0 {
+		t.Errorf("Expected empty state, got %v", viewed)
+	}
+	if err := manager.MarkFileViewed("/test/repo", "main", "abc123", "util.go"); err != nil {
+		t.Fatalf("MarkFileViewed failed: %v", err)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != corrupt {
+		t.Errorf("Expected the corrupt file to be kept as a backup, got %q (%v)", backup, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file to be left behind, got %v", err)
+	}
+}
+
 func TestCommentWithoutLineNumber(t *testing.T) {
 	manager, _ := setupTestManager(t)
 
@@ -368,7 +709,7 @@ func TestCommentWithoutLineNumber(t *testing.T) {
 	commit := "abc123"
 	filePath := "test.go"
 
-	comment, err := manager.AddComment(repoPath, branch, commit, filePath, nil, "File-level comment")
+	comment, err := manager.AddComment(repoPath, branch, commit, filePath, nil, nil, "", "File-level comment", "", "", "", "", nil)
 	if err != nil {
 		t.Fatalf("Failed to add comment: %v", err)
 	}
@@ -381,3 +722,431 @@ func TestCommentWithoutLineNumber(t *testing.T) {
 		t.Errorf("Expected 'File-level comment', got %s", comment.Text)
 	}
 }
+
+func TestImportRepo(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	if err := manager.MarkFileViewed(repoPath, "main", "abc123", "kept.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+	if err := manager.MarkFileViewed(repoPath, "main", "def456", "replaced.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+
+	imported := map[string]map[string]*RepoState{
+		"main": {
+			"def456": {ViewedFiles: []string{"imported.go"}, Comments: []*Comment{}, Notes: []*Note{}},
+		},
+		"feature": {
+			"ghi789": {ViewedFiles: []string{"feature.go"}, Comments: []*Comment{}, Notes: []*Note{}},
+		},
+	}
+
+	if err := manager.ImportRepo(repoPath, imported); err != nil {
+		t.Fatalf("ImportRepo failed: %v", err)
+	}
+
+	if !manager.IsFileViewed(repoPath, "main", "abc123", "kept.go") {
+		t.Error("Expected existing commit state to be kept")
+	}
+	if manager.IsFileViewed(repoPath, "main", "def456", "replaced.go") {
+		t.Error("Expected imported commit state to replace the existing entry")
+	}
+	if !manager.IsFileViewed(repoPath, "main", "def456", "imported.go") {
+		t.Error("Expected imported viewed file")
+	}
+	if !manager.IsFileViewed(repoPath, "feature", "ghi789", "feature.go") {
+		t.Error("Expected imported branch")
+	}
+
+	if paths := manager.RepoPaths(); len(paths) != 1 || paths[0] != repoPath {
+		t.Errorf("Expected RepoPaths to return [%s], got %v", repoPath, paths)
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	comment := &Comment{ID: "c1", FilePath: "main.go", Text: "Duplicated"}
+	note := &Note{ID: "n1", FilePath: "main.go", Text: "Duplicated"}
+	manager.state.Repos["/test/repo"] = map[string]map[string]*RepoState{
+		"main": {
+			"abc123": {
+				ViewedFiles: []string{"main.go", "main.go", "util.go"},
+				Comments:    []*Comment{comment, {ID: "c1", FilePath: "main.go", Text: "Copy"}},
+				Notes:       []*Note{note, note},
+			},
+			"def456": {ViewedFiles: []string{}, Comments: []*Comment{}, Notes: []*Note{}},
+		},
+		"stale": {
+			"ghi789": {ViewedFiles: []string{}, Comments: []*Comment{}, Notes: []*Note{}},
+		},
+	}
+	manager.state.Repos["/test/empty"] = map[string]map[string]*RepoState{}
+
+	stats, err := manager.Vacuum()
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+
+	if stats.DuplicateViewedFiles != 1 || stats.DuplicateComments != 1 || stats.DuplicateNotes != 1 {
+		t.Errorf("Unexpected duplicate counts: %+v", stats)
+	}
+	// def456, ghi789, the stale branch and the empty repo
+	if stats.EmptyBuckets != 4 {
+		t.Errorf("Expected 4 empty buckets removed, got %d", stats.EmptyBuckets)
+	}
+
+	if _, ok := manager.state.Repos["/test/empty"]; ok {
+		t.Error("Expected empty repo to be removed")
+	}
+	if _, ok := manager.state.Repos["/test/repo"]["stale"]; ok {
+		t.Error("Expected empty branch to be removed")
+	}
+
+	repoState := manager.state.Repos["/test/repo"]["main"]["abc123"]
+	if len(repoState.ViewedFiles) != 2 || len(repoState.Comments) != 1 || len(repoState.Notes) != 1 {
+		t.Errorf("Unexpected compacted state: %+v", repoState)
+	}
+	if repoState.Comments[0].Text != "Duplicated" {
+		t.Errorf("Expected the first comment to be kept, got %q", repoState.Comments[0].Text)
+	}
+
+	if stats.BytesAfter == 0 {
+		t.Error("Expected the state file to be rewritten")
+	}
+}
+
+func TestPruneCommits(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	manager.state.Repos["/test/repo"] = map[string]map[string]*RepoState{
+		"main": {
+			"abc123":          {ViewedFiles: []string{"main.go"}},
+			"def456":          {Comments: []*Comment{{ID: "c1", FilePath: "main.go"}}},
+			UncommittedCommit: {ViewedFiles: []string{"main.go:staged"}},
+		},
+		"feature": {
+			"def456": {Notes: []*Note{{ID: "n1", FilePath: "main.go"}}},
+		},
+	}
+
+	removed, err := manager.PruneCommits("/test/repo", func(commit string) bool { return commit != "def456" })
+	if err != nil {
+		t.Fatalf("PruneCommits failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 commit entries removed, got %d", removed)
+	}
+
+	branches := manager.state.Repos["/test/repo"]
+	if _, ok := branches["feature"]; ok {
+		t.Error("Expected the emptied branch to be removed")
+	}
+	if len(branches["main"]) != 2 {
+		t.Errorf("Expected abc123 and uncommitted state to be kept, got %v", branches["main"])
+	}
+
+	// Reloading reads what was saved
+	reloaded := &Manager{state: &ViewedState{Repos: make(map[string]map[string]map[string]*RepoState)}, stateDir: manager.stateDir, loaded: make(map[string]bool)}
+	if len(reloaded.GetComments("/test/repo", "main", "def456", nil)) != 0 {
+		t.Error("Expected the pruned comment to be gone after reloading")
+	}
+	if len(reloaded.GetViewedFiles("/test/repo", "main", "abc123")) != 1 {
+		t.Error("Expected the kept viewed file to be saved")
+	}
+
+	removed, err = manager.PruneCommits("/test/repo", func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("PruneCommits failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 commit entries removed, got %d", removed)
+	}
+	if _, ok := manager.state.Repos["/test/repo"]; ok {
+		t.Error("Expected the emptied repo to be removed")
+	}
+	if _, err := os.Stat(manager.repoStateFile("/test/repo")); !os.IsNotExist(err) {
+		t.Errorf("Expected the repo's state file to be removed, got %v", err)
+	}
+}
+
+func TestGetViewedFiles(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	if files := manager.GetViewedFiles(repoPath, "main", "abc123"); len(files) != 0 {
+		t.Errorf("Expected no viewed files, got %v", files)
+	}
+
+	for _, filePath := range []string{"a.go", "b.go"} {
+		if err := manager.MarkFileViewed(repoPath, "main", "abc123", filePath); err != nil {
+			t.Fatalf("Failed to mark file viewed: %v", err)
+		}
+	}
+
+	files := manager.GetViewedFiles(repoPath, "main", "abc123")
+	if len(files) != 2 || files[0] != "a.go" || files[1] != "b.go" {
+		t.Errorf("Expected [a.go b.go], got %v", files)
+	}
+
+	// The returned slice is a copy
+	files[0] = "changed.go"
+	if !manager.IsFileViewed(repoPath, "main", "abc123", "a.go") {
+		t.Error("Expected stored viewed files to be unaffected")
+	}
+}
+
+func TestMigrateComments(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	old, err := manager.AddComment(repoPath, "main", "abc123", "main.go", nil, nil, "", "Still open", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	resolved, err := manager.AddComment(repoPath, "main", "abc123", "util.go", nil, nil, "", "Fixed", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if err := manager.ResolveComment(repoPath, "main", "abc123", resolved.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "main", "def456", "main.go", nil, nil, "", "At HEAD", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "feature", "abc123", "main.go", nil, nil, "", "Other branch", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	moved, err := manager.MigrateComments(repoPath, "main", "def456")
+	if err != nil {
+		t.Fatalf("MigrateComments failed: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected 1 comment moved, got %d", moved)
+	}
+
+	head := manager.GetComments(repoPath, "main", "def456", nil)
+	if len(head) != 2 || head[1].ID != old.ID {
+		t.Fatalf("Expected the open comment to be appended at HEAD, got %v", head)
+	}
+	if head[1].Commit != "def456" {
+		t.Errorf("Expected migrated comment to record the new commit, got %s", head[1].Commit)
+	}
+
+	previous := manager.GetComments(repoPath, "main", "abc123", nil)
+	if len(previous) != 1 || previous[0].ID != resolved.ID {
+		t.Errorf("Expected only the resolved comment to stay behind, got %v", previous)
+	}
+	if len(manager.GetComments(repoPath, "feature", "abc123", nil)) != 1 {
+		t.Error("Expected comments on other branches to be untouched")
+	}
+
+	if moved, err := manager.MigrateComments(repoPath, "main", "def456"); err != nil || moved != 0 {
+		t.Errorf("Expected a second migration to be a no-op, got %d (%v)", moved, err)
+	}
+}
+
+func TestNewManagerForRepo_RepoLocal(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	stateHome := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateHome)
+
+	initRepo := func() string {
+		t.Helper()
+		repoPath := t.TempDir()
+		if output, err := exec.Command("git", "init", repoPath).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v\n%s", err, output)
+		}
+		return repoPath
+	}
+
+	// A repository can't choose where its state is written
+	localRepo := initRepo()
+	if err := os.WriteFile(filepath.Join(localRepo, ".guck.toml"), []byte("state_location = \"repo\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write repo config: %v", err)
+	}
+	ignored, err := NewManagerForRepo(localRepo)
+	if err != nil {
+		t.Fatalf("NewManagerForRepo failed: %v", err)
+	}
+	if ignored.localRepo != "" {
+		t.Error("Expected state_location in .guck.toml to be ignored")
+	}
+
+	if err := os.MkdirAll(filepath.Join(configHome, "guck"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "guck", "config.toml"), []byte("state_location = \"repo\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write global config: %v", err)
+	}
+
+	local, err := NewManagerForRepo(localRepo)
+	if err != nil {
+		t.Fatalf("NewManagerForRepo failed: %v", err)
+	}
+	if _, err := local.AddComment(localRepo, "main", "abc123", "main.go", nil, nil, "", "Local", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	localFile := filepath.Join(localRepo, ".git", "guck", "viewed.json")
+	data, err := os.ReadFile(localFile)
+	if err != nil {
+		t.Fatalf("Expected state in %s: %v", localFile, err)
+	}
+	if strings.Contains(string(data), localRepo) {
+		t.Errorf("Expected repo-local state not to be keyed by path, got %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(stateHome, "guck", "viewed.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the global state file not to be written, got %v", err)
+	}
+
+	globalRepo := initRepo()
+	global, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if len(global.GetAllComments(localRepo)) != 0 {
+		t.Error("Expected repo-local comments to be invisible to the global state")
+	}
+	if _, err := global.AddComment(globalRepo, "main", "def456", "app.go", nil, nil, "", "Global", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	reloaded, err := NewManagerForRepo(localRepo)
+	if err != nil {
+		t.Fatalf("NewManagerForRepo failed: %v", err)
+	}
+	comments := reloaded.GetAllComments(localRepo)
+	if len(comments) != 1 || comments[0].Text != "Local" {
+		t.Errorf("Expected the local comment to be reloaded, got %v", comments)
+	}
+	if len(reloaded.GetAllComments(globalRepo)) != 0 {
+		t.Error("Expected global comments to be invisible to the repo-local state")
+	}
+}
+
+func TestNewManager_MigratesLegacyState(t *testing.T) {
+	stateHome := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateHome)
+
+	legacy := `{"repos": {
+		"/repos/one": {"main": {"abc123": {"viewed_files": ["a.go"], "comments": [{"id": "c1", "file_path": "a.go", "text": "Old"}], "notes": []}}},
+		"/repos/two": {"feature": {"def456": {"viewed_files": ["b.go"], "comments": [], "notes": []}}}
+	}}`
+	legacyPath := filepath.Join(stateHome, "guck", "viewed.json")
+	if err := os.MkdirAll(filepath.Dir(legacyPath), 0755); err != nil {
+		t.Fatalf("Failed to create state dir: %v", err)
+	}
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy state: %v", err)
+	}
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	for _, repoPath := range []string{"/repos/one", "/repos/two"} {
+		if _, err := os.Stat(filepath.Join(stateHome, "guck", "state", HashRepoPath(repoPath)+".json")); err != nil {
+			t.Errorf("Expected a state file for %s: %v", repoPath, err)
+		}
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("Expected viewed.json to be retired")
+	}
+	if _, err := os.Stat(legacyPath + ".migrated"); err != nil {
+		t.Errorf("Expected viewed.json to be kept as a backup: %v", err)
+	}
+
+	// A fresh manager only reads what it is asked for
+	reloaded, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if !reloaded.IsFileViewed("/repos/one", "main", "abc123", "a.go") {
+		t.Error("Expected viewed file to survive migration")
+	}
+	if comments := reloaded.GetAllComments("/repos/one"); len(comments) != 1 || comments[0].Text != "Old" {
+		t.Errorf("Expected comment to survive migration, got %v", comments)
+	}
+	if reloaded.loaded["/repos/two"] {
+		t.Error("Expected /repos/two not to be loaded until used")
+	}
+	if paths := manager.RepoPaths(); len(paths) != 2 {
+		t.Errorf("Expected 2 repos, got %v", paths)
+	}
+}
+
+func TestSaveWritesOnlyTheRepo(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+
+	if err := manager.MarkFileViewed("/repos/one", "main", "abc123", "a.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+	otherFile := filepath.Join(tempDir, HashRepoPath("/repos/one")+".json")
+	before, err := os.Stat(otherFile)
+	if err != nil {
+		t.Fatalf("Expected state file: %v", err)
+	}
+
+	if err := manager.MarkFileViewed("/repos/two", "main", "abc123", "b.go"); err != nil {
+		t.Fatalf("Failed to mark file viewed: %v", err)
+	}
+	after, err := os.Stat(otherFile)
+	if err != nil {
+		t.Fatalf("Expected state file: %v", err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() {
+		t.Error("Expected /repos/one's file not to be rewritten")
+	}
+
+	// Removing a repo's last bucket through vacuum removes its file
+	if err := manager.UnmarkFileViewed("/repos/two", "main", "abc123", "b.go"); err != nil {
+		t.Fatalf("Failed to unmark file viewed: %v", err)
+	}
+	if _, err := manager.Vacuum(); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, HashRepoPath("/repos/two")+".json")); !os.IsNotExist(err) {
+		t.Error("Expected the emptied repo's file to be removed")
+	}
+}
+
+func TestGetMentions(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	repoPath := "/test/repo"
+	lineNumber := 7
+
+	comment, err := manager.AddComment(repoPath, "main", "abc123", "auth.go", &lineNumber, nil, "", "@alice can you check this? cc @bob.", "", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if strings.Join(comment.Mentions, ",") != "alice,bob" {
+		t.Errorf("Expected mentions [alice bob], got %v", comment.Mentions)
+	}
+
+	if _, err := manager.AddComment(repoPath, "feature", "def456", "main.go", nil, nil, "", "Thanks @Alice, ping alice@example.com if it breaks", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	if _, err := manager.AddComment(repoPath, "main", "abc123", "main.go", nil, nil, "", "No mentions here", "", "", "", "", nil); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+
+	if mentions := manager.GetMentions(repoPath, "alice"); len(mentions) != 2 {
+		t.Errorf("Expected 2 comments mentioning alice across branches, got %d", len(mentions))
+	}
+
+	bob := manager.GetMentions(repoPath, "@bob")
+	if len(bob) != 1 || bob[0].FilePath != "auth.go" {
+		t.Errorf("Expected the auth.go comment to mention bob, got %v", bob)
+	}
+
+	if mentions := manager.GetMentions(repoPath, "example.com"); len(mentions) != 0 {
+		t.Errorf("Expected email addresses not to count as mentions, got %d", len(mentions))
+	}
+}
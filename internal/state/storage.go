@@ -0,0 +1,252 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// legacyStateFile is the single file every repo's state used to share
+const legacyStateFile = "viewed.json"
+
+// reposDirName is the directory under the state directory holding one file
+// per repo
+const reposDirName = "state"
+
+// repoFile is the stored state of a single repository in the global state
+// directory. The path is kept so repos can be listed without knowing them.
+type repoFile struct {
+	Version  int                              `json:"version"`
+	RepoPath string                           `json:"repo_path"`
+	Branches map[string]map[string]*RepoState `json:"branches"`
+}
+
+// HashRepoPath derives a stable, filesystem-safe file name for a repository
+func HashRepoPath(repoPath string) string {
+	sum := sha256.Sum256([]byte(repoPath))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// repoStateFile returns the file that stores a repo's state
+func (m *Manager) repoStateFile(repoPath string) string {
+	if m.localRepo != "" {
+		return m.stateFile
+	}
+	return filepath.Join(m.stateDir, HashRepoPath(repoPath)+".json")
+}
+
+// load reads a repo's state from disk the first time it is used. A missing
+// file leaves the repo empty; an unreadable one is set aside first, see
+// setAside.
+func (m *Manager) load(repoPath string) {
+	if m.loaded[repoPath] || m.localRepo != "" {
+		return
+	}
+	m.loaded[repoPath] = true
+
+	path := m.repoStateFile(repoPath)
+	var file repoFile
+	if err := readStateFile(path, &file); err != nil {
+		if !os.IsNotExist(err) {
+			m.setAside(repoPath, path, err)
+		}
+		return
+	}
+	if file.Branches == nil {
+		return
+	}
+	if m.state.Repos[repoPath] == nil {
+		m.state.Repos[repoPath] = file.Branches
+	}
+}
+
+// loadAll reads every stored repo, for operations that span all of them
+func (m *Manager) loadAll() {
+	if m.localRepo != "" {
+		return
+	}
+
+	entries, err := os.ReadDir(m.stateDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		var file repoFile
+		if err := readStateFile(filepath.Join(m.stateDir, entry.Name()), &file); err != nil || file.RepoPath == "" {
+			continue
+		}
+		if m.loaded[file.RepoPath] {
+			continue
+		}
+		m.loaded[file.RepoPath] = true
+		if m.state.Repos[file.RepoPath] == nil && file.Branches != nil {
+			m.state.Repos[file.RepoPath] = file.Branches
+		}
+	}
+}
+
+// setAside keeps a state file that failed to load from being overwritten by
+// the empty state the repo starts with. A corrupt file, such as one cut short
+// by an interrupted write, is moved aside; a file from a newer guck is left in
+// place and saving the repo fails instead.
+func (m *Manager) setAside(repoPath, path string, err error) {
+	if m.unwritable == nil {
+		m.unwritable = make(map[string]error)
+	}
+
+	if errors.Is(err, ErrNewerVersion) {
+		m.unwritable[repoPath] = fmt.Errorf("%s: %w", path, err)
+		fmt.Fprintf(os.Stderr, "Warning: %s was written by a newer guck; its review state is not shown and will not be changed\n", path)
+		return
+	}
+
+	if err := moveAside(path, err); err != nil {
+		m.unwritable[repoPath] = err
+		fmt.Fprintf(os.Stderr, "Warning: %v; its review state will not be changed\n", err)
+	}
+}
+
+// moveAside renames an unreadable state file to <path>.bak and warns that
+// its repo starts over with empty state
+func moveAside(path string, readErr error) error {
+	backup := path + ".bak"
+	if err := os.Rename(path, backup); err != nil {
+		return fmt.Errorf("%s is unreadable (%v) and could not be moved aside: %w", path, readErr, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %s is unreadable (%v); moved it to %s and started with empty review state\n", path, readErr, backup)
+	return nil
+}
+
+// save writes a single repo's state, removing its file once it has none
+func (m *Manager) save(repoPath string) error {
+	if err := m.unwritable[repoPath]; err != nil {
+		return fmt.Errorf("refusing to overwrite review state: %w", err)
+	}
+
+	if m.localRepo != "" {
+		// A repo-local file only ever holds its own repo
+		state := &ViewedState{Version: CurrentVersion, Repos: make(map[string]map[string]map[string]*RepoState)}
+		if branches, ok := m.state.Repos[m.localRepo]; ok {
+			state.Repos[localRepoKey] = branches
+		}
+		return writeStateFile(m.stateFile, state)
+	}
+
+	path := m.repoStateFile(repoPath)
+	branches, ok := m.state.Repos[repoPath]
+	if !ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove state file: %w", err)
+		}
+		return nil
+	}
+
+	return writeStateFile(path, repoFile{Version: CurrentVersion, RepoPath: repoPath, Branches: branches})
+}
+
+// storedBytes is the total size of the state files on disk
+func (m *Manager) storedBytes() int64 {
+	if m.localRepo != "" {
+		if info, err := os.Stat(m.stateFile); err == nil {
+			return info.Size()
+		}
+		return 0
+	}
+
+	var total int64
+	entries, err := os.ReadDir(m.stateDir)
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// migrateLegacyState splits a shared viewed.json into per-repo files. Entries
+// already present in a repo's file win. The old file is kept alongside with a
+// .migrated suffix.
+func (m *Manager) migrateLegacyState(legacyPath string) error {
+	if _, err := os.Stat(legacyPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	var legacy ViewedState
+	if err := readStateFile(legacyPath, &legacy); err == nil {
+		for repoPath, branches := range legacy.Repos {
+			m.load(repoPath)
+			if m.state.Repos[repoPath] == nil {
+				m.state.Repos[repoPath] = make(map[string]map[string]*RepoState)
+			}
+
+			for branch, commits := range branches {
+				if m.state.Repos[repoPath][branch] == nil {
+					m.state.Repos[repoPath][branch] = make(map[string]*RepoState)
+				}
+				for commit, repoState := range commits {
+					if _, ok := m.state.Repos[repoPath][branch][commit]; !ok {
+						m.state.Repos[repoPath][branch][commit] = repoState
+					}
+				}
+			}
+
+			if err := m.save(repoPath); err != nil {
+				return fmt.Errorf("failed to migrate state for %s: %w", repoPath, err)
+			}
+		}
+	}
+
+	if err := os.Rename(legacyPath, legacyPath+".migrated"); err != nil {
+		return fmt.Errorf("failed to retire %s: %w", legacyPath, err)
+	}
+	return nil
+}
+
+// readStateFile decodes a state file, upgrading it from older schema
+// versions first. The file itself is rewritten on the next save.
+func readStateFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	data, _, err = migrateData(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeStateFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize state: %w", err)
+	}
+
+	// Write a temporary file and rename it over the old one, so an
+	// interrupted write never leaves a truncated state file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package version reports which build of guck is running
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version, Commit and Date are set for release builds with -ldflags, e.g.
+// "-X github.com/tuist/guck/internal/version.Version=1.2.3"
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// devVersion is reported when neither ldflags nor build info name a version
+const devVersion = "dev"
+
+// unknown is reported for a commit or build date nothing recorded
+const unknown = "unknown"
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// String returns the release version set at link time, falling back to the
+// module version recorded by go install, then to the VCS revision, then to
+// "dev"
+func String() string {
+	return Get().Version
+}
+
+// Get describes the running build, preferring the values set at link time
+// over those Go records in the binary's build info
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		buildInfo = &debug.BuildInfo{}
+	}
+	if info.Version == "" {
+		if v := buildInfo.Main.Version; v != "" && v != "(devel)" {
+			info.Version = v
+		} else {
+			info.Version = fromSettings(buildInfo.Settings)
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = setting(buildInfo.Settings, "vcs.revision", unknown)
+	}
+	if info.Date == "" {
+		info.Date = setting(buildInfo.Settings, "vcs.time", unknown)
+	}
+
+	return info
+}
+
+// setting returns the value of a build setting, or fallback when unset
+func setting(settings []debug.BuildSetting, key, fallback string) string {
+	for _, s := range settings {
+		if s.Key == key && s.Value != "" {
+			return s.Value
+		}
+	}
+	return fallback
+}
+
+// fromSettings describes a development build by its VCS revision, marking
+// builds from a modified tree
+func fromSettings(settings []debug.BuildSetting) string {
+	revision := setting(settings, "vcs.revision", "")
+	if revision == "" {
+		return devVersion
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+
+	version := devVersion + "-" + revision
+	if setting(settings, "vcs.modified", "") == "true" {
+		version += "-dirty"
+	}
+	return version
+}
@@ -0,0 +1,41 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestGetPrefersLinkedValues(t *testing.T) {
+	version, commit, date := Version, Commit, Date
+	t.Cleanup(func() { Version, Commit, Date = version, commit, date })
+
+	Version, Commit, Date = "1.2.3", "abc123", "2024-05-01T09:00:00Z"
+	info := Get()
+	if info.Version != "1.2.3" || info.Commit != "abc123" || info.Date != "2024-05-01T09:00:00Z" {
+		t.Errorf("Expected the linked values, got %+v", info)
+	}
+	if info.GoVersion == "" {
+		t.Error("Expected the Go version")
+	}
+	if got := String(); got != "1.2.3" {
+		t.Errorf("Expected the linked version, got %q", got)
+	}
+}
+
+func TestFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []debug.BuildSetting
+		want     string
+	}{
+		{"no vcs", nil, "dev"},
+		{"revision", []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}}, "dev-0123456789ab"},
+		{"modified", []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "true"}}, "dev-abc123-dirty"},
+	}
+
+	for _, tt := range tests {
+		if got := fromSettings(tt.settings); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/tuist/guck/internal/cli/commands"
+	"github.com/tuist/guck/internal/cli/formatters"
 	"github.com/tuist/guck/internal/cli/helpers"
 	"github.com/tuist/guck/internal/config"
 	"github.com/tuist/guck/internal/daemon"
@@ -15,6 +22,7 @@ import (
 	"github.com/tuist/guck/internal/mcp"
 	"github.com/tuist/guck/internal/server"
 	"github.com/tuist/guck/internal/state"
+	"github.com/tuist/guck/internal/version"
 	"github.com/urfave/cli/v2"
 )
 
@@ -26,10 +34,52 @@ var (
 	urlColor     = color.New(color.FgBlue, color.Underline)
 )
 
+// daemonStopTimeout is how long restart waits for the old daemon to exit
+const daemonStopTimeout = 10 * time.Second
+
+// daemonProbeTimeout is how long daemon status waits for /api/health
+const daemonProbeTimeout = 2 * time.Second
+
+// daemonReadyTimeout is how long a spawned daemon has to start serving
+const daemonReadyTimeout = 5 * time.Second
+
+// daemonStartupLogLines is how much of the log a failed daemon start shows
+const daemonStartupLogLines = 20
+
+// hostFlag overrides the host config for the server's listening address
+var hostFlag = &cli.StringFlag{
+	Name:  "host",
+	Usage: "Address to listen on, such as 0.0.0.0 for every interface (defaults to the host config, 127.0.0.1)",
+}
+
+// noColorFlag turns off colored output even on a terminal
+var noColorFlag = &cli.BoolFlag{
+	Name:  "no-color",
+	Usage: "Disable colored output (also disabled by NO_COLOR or when stdout is not a terminal)",
+}
+
+// noStartFlag makes open fail instead of starting a daemon when none is running
+var noStartFlag = &cli.BoolFlag{
+	Name:  "no-start",
+	Usage: "Fail instead of starting a daemon when none is running",
+}
+
 func main() {
 	app := &cli.App{
-		Name:  "guck",
-		Usage: "A Git diff review tool with a web interface",
+		Name:    "guck",
+		Usage:   "A Git diff review tool with a web interface",
+		Version: version.String(),
+		Before: func(c *cli.Context) error {
+			// fatih/color already turns itself off for NO_COLOR, TERM=dumb
+			// and output that is not a terminal
+			if c.Bool("no-color") {
+				color.NoColor = true
+			}
+			if cfg, err := config.Load(); err == nil {
+				formatters.IDDisplayLength = cfg.IDDisplayLength
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "start",
@@ -40,10 +90,23 @@ func main() {
 						Aliases: []string{"p"},
 						Usage:   "Port to run the server on (defaults to random available port)",
 					},
+					hostFlag,
 					&cli.StringFlag{
 						Name:    "base",
 						Aliases: []string{"b"},
-						Usage:   "Base branch to compare against",
+						Usage:   "Base branch or revision (e.g. HEAD~3) to compare against",
+					},
+					&cli.BoolFlag{
+						Name:  "fetch",
+						Usage: "Fetch origin before computing diffs so the base branch is current",
+					},
+					&cli.StringFlag{
+						Name:  "diff-mode",
+						Usage: "Compare against the merge base (merge-base, default) or the base itself (direct)",
+					},
+					&cli.BoolFlag{
+						Name:  "staged",
+						Usage: "Review only the changes staged for the next commit",
 					},
 				},
 				Action: startServerForeground,
@@ -53,6 +116,12 @@ func main() {
 				Usage:  "Initialize shell integration (outputs shell script to eval)",
 				Action: printShellIntegration,
 			},
+			{
+				Name:   "open",
+				Usage:  "Open the review in the browser, starting a daemon if none is running",
+				Flags:  []cli.Flag{noStartFlag},
+				Action: openBrowser,
+			},
 			{
 				Name:  "daemon",
 				Usage: "Daemon management commands",
@@ -66,29 +135,122 @@ func main() {
 								Aliases: []string{"b"},
 								Usage:   "Override base branch",
 							},
+							hostFlag,
+							&cli.BoolFlag{
+								Name:  "fetch",
+								Usage: "Fetch origin before computing diffs so the base branch is current",
+							},
+							&cli.StringFlag{
+								Name:  "diff-mode",
+								Usage: "Compare against the merge base (merge-base, default) or the base itself (direct)",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json (default: human-readable)",
+								Value:   "",
+							},
 						},
 						Action: startDaemon,
 					},
 					{
-						Name:   "stop",
-						Usage:  "Stop daemon for current repository",
+						Name:  "stop",
+						Usage: "Stop daemon for current repository",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json (default: human-readable)",
+								Value:   "",
+							},
+						},
 						Action: stopDaemon,
 					},
 					{
-						Name:   "stop-all",
-						Usage:  "Stop all running daemons",
+						Name:  "restart",
+						Usage: "Restart daemon for current repository (starts one if none is running)",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "base",
+								Aliases: []string{"b"},
+								Usage:   "Override base branch (defaults to the running daemon's)",
+							},
+							&cli.StringFlag{
+								Name:  "host",
+								Usage: "Address to listen on (defaults to the running daemon's)",
+							},
+							&cli.BoolFlag{
+								Name:  "fetch",
+								Usage: "Fetch origin before computing diffs so the base branch is current",
+							},
+							&cli.StringFlag{
+								Name:  "diff-mode",
+								Usage: "Override the diff mode: merge-base or direct (defaults to the running daemon's)",
+							},
+						},
+						Action: restartDaemon,
+					},
+					{
+						Name:  "stop-all",
+						Usage: "Stop all running daemons",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json (default: human-readable)",
+								Value:   "",
+							},
+						},
 						Action: stopAllDaemons,
 					},
 					{
-						Name:   "list",
-						Usage:  "List all running daemons",
+						Name:  "list",
+						Usage: "List all running daemons",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json (default: human-readable)",
+								Value:   "",
+							},
+						},
 						Action: listDaemons,
 					},
+					{
+						Name:  "status",
+						Usage: "Show whether the daemon for current repository is running and reachable",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: daemonStatus,
+					},
 					{
 						Name:   "cleanup",
 						Usage:  "Clean up stale daemon entries",
 						Action: cleanupDaemons,
 					},
+					{
+						Name:  "logs",
+						Usage: "Show the daemon log for current repository",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "follow",
+								Aliases: []string{"f"},
+								Usage:   "Keep printing lines as they are appended",
+							},
+							&cli.IntFlag{
+								Name:    "lines",
+								Aliases: []string{"n"},
+								Usage:   "Number of lines to show initially (0 shows the whole file)",
+							},
+						},
+						Action: daemonLogs,
+					},
 				},
 			},
 			{
@@ -112,11 +274,185 @@ func main() {
 						Usage:  "Show all configuration",
 						Action: showConfig,
 					},
+					{
+						Name:   "keys",
+						Usage:  "List valid configuration keys",
+						Action: listConfigKeys,
+					},
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Export the review comments and notes of a repository",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "repo",
+						Aliases: []string{"r"},
+						Usage:   "Repository path (defaults to current directory)",
+						Value:   ".",
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Usage:    "File to write the export to",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"o"},
+						Usage:   "Export format: json, markdown, csv",
+						Value:   "json",
+					},
+				},
+				Action: commands.Export,
+			},
+			{
+				Name:  "state",
+				Usage: "Review state backup and migration",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "export-all",
+						Usage: "Export the review state of every tracked repository",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "Directory to write the export and its manifest to (defaults to the export-path config)",
+							},
+						},
+						Action: commands.ExportAllState,
+					},
+					{
+						Name:  "import-all",
+						Usage: "Restore review state written by export-all",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "input",
+								Aliases:  []string{"i"},
+								Usage:    "Directory containing the export manifest",
+								Required: true,
+							},
+						},
+						Action: commands.ImportAllState,
+					},
+					{
+						Name:   "vacuum",
+						Usage:  "Remove empty entries and duplicates from the state file",
+						Action: commands.VacuumState,
+					},
+					{
+						Name:  "prune",
+						Usage: "Remove the review state of old or unreachable commits",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "unreachable",
+								Usage: "Remove state of commits the repository no longer has",
+							},
+							&cli.StringFlag{
+								Name:  "older-than",
+								Usage: "Remove state of commits made longer ago than this, e.g. 24h or 30d",
+							},
+							&cli.StringFlag{
+								Name:  "repo",
+								Usage: "Prune only this repository; on its own, remove all of its state",
+							},
+						},
+						Action: commands.PruneState,
+					},
+					{
+						Name:  "migrate",
+						Usage: "Upgrade state files to the current schema version, keeping backups",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "repo",
+								Usage: "Migrate this repository's state, including state kept in .git/guck/",
+							},
+						},
+						Action: commands.MigrateState,
+					},
+				},
+			},
+			{
+				Name:  "diff",
+				Usage: "Print the diff the web UI would show, for terminals without a browser",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "repo",
+						Aliases: []string{"r"},
+						Usage:   "Repository path (defaults to current directory)",
+						Value:   ".",
+					},
+					&cli.StringFlag{
+						Name:    "base",
+						Aliases: []string{"b"},
+						Usage:   "Base branch or revision (e.g. HEAD~3) to compare against (defaults to the configured base branch)",
+					},
+					&cli.StringFlag{
+						Name:  "diff-mode",
+						Usage: "Compare against the merge base (merge-base, default) or the base itself (direct)",
+					},
+					&cli.BoolFlag{
+						Name:  "uncommitted",
+						Usage: "Also show staged and unstaged changes",
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"o"},
+						Usage:   "Output format: json (default: colorized unified diff)",
+					},
+				},
+				Action: commands.Diff,
+			},
+			{
+				Name:  "review",
+				Usage: "Review the current branch in the terminal",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "diff-comments",
+						Usage: "Print the diff with comments shown beneath the lines they refer to",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:    "base",
+								Aliases: []string{"b"},
+								Usage:   "Base branch or revision (e.g. HEAD~3) to compare against (defaults to the configured base branch)",
+							},
+							&cli.StringFlag{
+								Name:    "file",
+								Aliases: []string{"f"},
+								Usage:   "Only show this file",
+							},
+						},
+						Action: commands.DiffComments,
+					},
+				},
+			},
+			{
+				Name:  "version",
+				Usage: "Print the version, commit, build date and Go version of guck",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"o"},
+						Usage:   "Output format: json (default: human-readable)",
+					},
 				},
+				Action: printVersion,
 			},
 			{
-				Name:   "mcp",
-				Usage:  "Start MCP (Model Context Protocol) server for LLM integrations",
+				Name:  "mcp",
+				Usage: "Start MCP (Model Context Protocol) server for LLM integrations",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "repo",
+						Aliases: []string{"r"},
+						Usage:   "Repository used by tool calls that omit repo_path",
+					},
+				},
 				Action: mcpStdio,
 			},
 			{
@@ -136,12 +472,97 @@ func main() {
 						},
 						Action: addSampleNotes,
 					},
+					{
+						Name:  "sample-comments",
+						Usage: "Add sample review comments, replies and resolutions for testing/preview",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:    "count",
+								Aliases: []string{"c"},
+								Usage:   "Number of sample comments to generate",
+								Value:   6,
+							},
+						},
+						Action: addSampleComments,
+					},
 				},
 			},
 			{
 				Name:  "comments",
 				Usage: "Code review comments management",
 				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Add a code review comment",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "File path relative to repository root",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:    "line",
+								Aliases: []string{"l"},
+								Usage:   "Line number for inline comments",
+							},
+							&cli.IntFlag{
+								Name:  "end-line",
+								Usage: "Last line of a comment spanning --line through this line",
+							},
+							&cli.StringFlag{
+								Name:  "side",
+								Usage: "Side of the diff the line numbers refer to: old (before the change) or new (after it)",
+								Value: "new",
+							},
+							&cli.StringFlag{
+								Name:    "text",
+								Aliases: []string{"t"},
+								Usage:   "Comment content (markdown supported; optional with --suggest-from-file)",
+							},
+							&cli.StringFlag{
+								Name:  "suggest-from-file",
+								Usage: "Read a suggested replacement for the commented line(s) from this file",
+							},
+							&cli.StringFlag{
+								Name:    "author",
+								Aliases: []string{"a"},
+								Usage:   "Author identifier (e.g., 'claude', 'human:username')",
+								Value:   "cli",
+							},
+							&cli.StringFlag{
+								Name:    "type",
+								Aliases: []string{"T"},
+								Usage:   "Comment type (issue, question, suggestion)",
+							},
+							&cli.StringFlag{
+								Name:  "parent",
+								Usage: "ID of the comment to reply to",
+							},
+							&cli.BoolFlag{
+								Name:  "resolve-existing",
+								Usage: "Resolve your unresolved comments on the same file and line, superseding them",
+							},
+							&cli.StringSliceFlag{
+								Name:    "metadata",
+								Aliases: []string{"m"},
+								Usage:   "Metadata as key=value pairs",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.AddComment,
+					},
 					{
 						Name:  "list",
 						Usage: "List code review comments",
@@ -177,6 +598,110 @@ func main() {
 								Aliases: []string{"U"},
 								Usage:   "Show only unresolved comments",
 							},
+							&cli.StringFlag{
+								Name:  "author-kind",
+								Usage: "Show only comments by human:<name> (human) or agent:<name> (agent) authors",
+							},
+							&cli.StringFlag{
+								Name:    "search",
+								Aliases: []string{"s"},
+								Usage:   "Show only comments whose text contains this string (case-insensitive)",
+							},
+							&cli.StringFlag{
+								Name:  "since",
+								Usage: "Show only comments made since this time: RFC3339 or a duration before now (e.g. 24h, 7d)",
+							},
+							&cli.StringFlag{
+								Name:  "until",
+								Usage: "Show only comments made until this time: RFC3339 or a duration before now (e.g. 24h, 7d)",
+							},
+							&cli.BoolFlag{
+								Name:  "follow-renames",
+								Usage: "With --file, include comments recorded under the file's previous paths",
+							},
+							&cli.IntFlag{
+								Name:  "context-lines",
+								Usage: "Include N lines of code around each comment, read from its commit (max 20)",
+							},
+							&cli.StringFlag{
+								Name:  "sort",
+								Usage: "Order: newest, oldest, or file (by path and line)",
+								Value: "newest",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "Show at most this many comments (0 shows all)",
+							},
+							&cli.IntFlag{
+								Name:  "offset",
+								Usage: "Skip this many matching comments",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, jsonl, toon, tsv, csv, github (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.ListComments,
+					},
+					{
+						Name:      "resolve",
+						Usage:     "Mark a comment as resolved",
+						ArgsUsage: "<comment-id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:     "by",
+								Aliases:  []string{"u"},
+								Usage:    "Who is resolving the comment",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.ResolveComment,
+					},
+					{
+						Name:      "reopen",
+						Usage:     "Reopen a resolved comment",
+						ArgsUsage: "<comment-id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.ReopenComment,
+					},
+					{
+						Name:      "delete",
+						Usage:     "Permanently delete a comment",
+						ArgsUsage: "<comment-id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
 							&cli.StringFlag{
 								Name:    "format",
 								Aliases: []string{"o"},
@@ -184,12 +709,11 @@ func main() {
 								Value:   "",
 							},
 						},
-						Action: commands.ListComments,
+						Action: commands.DeleteComment,
 					},
 					{
-						Name:      "resolve",
-						Usage:     "Mark a comment as resolved",
-						ArgsUsage: "<comment-id>",
+						Name:  "stale",
+						Usage: "List unresolved comments pinned to commits other than HEAD",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:    "repo",
@@ -197,20 +721,18 @@ func main() {
 								Usage:   "Repository path (defaults to current directory)",
 								Value:   ".",
 							},
-							&cli.StringFlag{
-								Name:     "by",
-								Aliases:  []string{"u"},
-								Usage:    "Who is resolving the comment",
-								Required: true,
+							&cli.BoolFlag{
+								Name:  "migrate-to-head",
+								Usage: "Move unresolved comments from earlier commits of the branch onto HEAD",
 							},
 							&cli.StringFlag{
 								Name:    "format",
 								Aliases: []string{"o"},
-								Usage:   "Output format: json, toon (default: human-readable)",
+								Usage:   "Output format: json, jsonl, toon, tsv, csv (default: human-readable)",
 								Value:   "",
 							},
 						},
-						Action: commands.ResolveComment,
+						Action: commands.StaleComments,
 					},
 				},
 			},
@@ -239,6 +761,14 @@ func main() {
 								Aliases: []string{"l"},
 								Usage:   "Line number for inline notes",
 							},
+							&cli.StringFlag{
+								Name:  "line-from-pattern",
+								Usage: "Regular expression locating the line in the file at the current commit",
+							},
+							&cli.BoolFlag{
+								Name:  "first-match",
+								Usage: "With --line-from-pattern, use the first match instead of failing on multiple matches",
+							},
 							&cli.StringFlag{
 								Name:     "text",
 								Aliases:  []string{"t"},
@@ -311,15 +841,78 @@ func main() {
 								Aliases: []string{"A"},
 								Usage:   "Show only active (non-dismissed) notes",
 							},
+							&cli.StringFlag{
+								Name:  "author-kind",
+								Usage: "Show only notes by human:<name> (human) or agent:<name> (agent) authors",
+							},
+							&cli.StringFlag{
+								Name:    "search",
+								Aliases: []string{"s"},
+								Usage:   "Show only notes whose text or metadata values contain this string (case-insensitive)",
+							},
+							&cli.StringFlag{
+								Name:  "since",
+								Usage: "Show only notes made since this time: RFC3339 or a duration before now (e.g. 24h, 7d)",
+							},
+							&cli.StringFlag{
+								Name:  "until",
+								Usage: "Show only notes made until this time: RFC3339 or a duration before now (e.g. 24h, 7d)",
+							},
+							&cli.StringSliceFlag{
+								Name:    "metadata",
+								Aliases: []string{"m"},
+								Usage:   "Show only notes with this key=value metadata pair (repeatable; all must match)",
+							},
+							&cli.BoolFlag{
+								Name:  "follow-renames",
+								Usage: "With --file, include notes recorded under the file's previous paths",
+							},
+							&cli.StringFlag{
+								Name:  "sort",
+								Usage: "Order: newest, oldest, or file (by path and line)",
+								Value: "newest",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "Show at most this many notes (0 shows all)",
+							},
+							&cli.IntFlag{
+								Name:  "offset",
+								Usage: "Skip this many matching notes",
+							},
 							&cli.StringFlag{
 								Name:    "format",
 								Aliases: []string{"o"},
-								Usage:   "Output format: json, toon (default: human-readable)",
+								Usage:   "Output format: json, jsonl, toon, tsv, csv (default: human-readable)",
 								Value:   "",
 							},
 						},
 						Action: commands.ListNotes,
 					},
+					{
+						Name:  "stats",
+						Usage: "Count AI agent notes by author and type",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:    "branch",
+								Aliases: []string{"b"},
+								Usage:   "Only count notes on this branch",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.NotesStats,
+					},
 					{
 						Name:      "dismiss",
 						Usage:     "Dismiss an AI agent note",
@@ -346,9 +939,50 @@ func main() {
 						},
 						Action: commands.DismissNote,
 					},
+					{
+						Name:      "restore",
+						Usage:     "Restore a dismissed note to the active list",
+						ArgsUsage: "<note-id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.RestoreNote,
+					},
+					{
+						Name:      "delete",
+						Usage:     "Permanently delete an AI agent note",
+						ArgsUsage: "<note-id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "repo",
+								Aliases: []string{"r"},
+								Usage:   "Repository path (defaults to current directory)",
+								Value:   ".",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"o"},
+								Usage:   "Output format: json, toon (default: human-readable)",
+								Value:   "",
+							},
+						},
+						Action: commands.DeleteNote,
+					},
 				},
 			},
 		},
+		Flags:  []cli.Flag{noStartFlag, noColorFlag},
 		Action: openBrowser,
 	}
 
@@ -374,14 +1008,21 @@ func startServerForeground(c *cli.Context) error {
 		return err
 	}
 
-	cfg, err := config.Load()
+	cfg, err := config.LoadForRepo(repoPath)
 	if err != nil {
 		return err
 	}
 
-	baseBranch := c.String("base")
-	if baseBranch == "" {
-		baseBranch = cfg.BaseBranch
+	baseBranch := resolveBaseBranch(repoPath, c.String("base"), cfg.BaseBranch)
+
+	diffMode, err := git.ParseDiffMode(c.String("diff-mode"))
+	if err != nil {
+		return err
+	}
+
+	host := c.String("host")
+	if host == "" {
+		host = cfg.Host
 	}
 
 	port := c.Int("port")
@@ -397,6 +1038,9 @@ func startServerForeground(c *cli.Context) error {
 		Port:       port,
 		RepoPath:   repoPath,
 		BaseBranch: baseBranch,
+		DiffMode:   string(diffMode),
+		Host:       host,
+		Fetch:      c.Bool("fetch"),
 	}
 
 	if err := daemonMgr.RegisterDaemon(daemonInfo); err != nil {
@@ -405,10 +1049,42 @@ func startServerForeground(c *cli.Context) error {
 
 	successColor.Printf("✓ Starting guck server for %s\n", repoPath)
 	infoColor.Print("Server running on ")
-	urlColor.Printf("http://localhost:%d\n", port)
+	urlColor.Println(daemonInfo.URL())
+	infoColor.Printf("Comparing against %s\n", baseBranch)
+	warnIfExposed(host)
 	infoColor.Println("Press Ctrl+C to stop")
 
-	return server.Start(port, baseBranch)
+	serveErr := server.Start(host, port, baseBranch, server.Options{AutoFetch: c.Bool("fetch"), Staged: c.Bool("staged"), DiffMode: diffMode})
+	unregisterSelf(daemonMgr, repoPath)
+	return serveErr
+}
+
+// resolveBaseBranch returns the --base flag, falling back to the configured
+// base branch, with "auto" replaced by the detected default branch. If
+// detection fails the base is left as "auto" so the error surfaces with the
+// first diff rather than preventing the server from starting.
+func resolveBaseBranch(repoPath, flag, configured string) string {
+	baseBranch := flag
+	if baseBranch == "" {
+		baseBranch = configured
+	}
+
+	gitRepo, err := git.Open(repoPath)
+	if err != nil {
+		return baseBranch
+	}
+	if detected, err := gitRepo.ResolveBaseBranch(baseBranch); err == nil {
+		return detected
+	}
+	return baseBranch
+}
+
+// unregisterSelf removes this process's daemon entry once its server has
+// stopped, leaving any daemon that has since replaced it registered
+func unregisterSelf(daemonMgr *daemon.Manager, repoPath string) {
+	if info, _ := daemonMgr.GetDaemonForRepo(repoPath); info != nil && info.PID == os.Getpid() {
+		_ = daemonMgr.UnregisterDaemon(repoPath)
+	}
 }
 
 func printShellIntegration(c *cli.Context) error {
@@ -469,7 +1145,237 @@ _guck_auto_manage
 	return nil
 }
 
-func startDaemon(c *cli.Context) error {
+func startDaemon(c *cli.Context) error {
+	gitRepo, err := git.Open(".")
+	if err != nil {
+		return err
+	}
+
+	repoPath, err := gitRepo.RepoPath()
+	if err != nil {
+		return err
+	}
+
+	daemonMgr, err := daemon.NewManager()
+	if err != nil {
+		return err
+	}
+
+	// Check if daemon already running
+	if info, _ := daemonMgr.GetDaemonForRepo(repoPath); info != nil {
+		if daemonMgr.IsDaemonRunning(info.PID) {
+			if c.String("format") == "json" {
+				return formatters.OutputJSON(newDaemonStartResult(info, true))
+			}
+			return nil
+		}
+		_ = daemonMgr.UnregisterDaemon(repoPath)
+	}
+
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	baseBranch := resolveBaseBranch(repoPath, c.String("base"), cfg.BaseBranch)
+
+	diffMode, err := git.ParseDiffMode(c.String("diff-mode"))
+	if err != nil {
+		return err
+	}
+
+	host := c.String("host")
+	if host == "" {
+		host = cfg.Host
+	}
+
+	port, err := daemonMgr.FindAvailablePort()
+	if err != nil {
+		return err
+	}
+
+	// Check if we're the daemon process
+	if os.Getenv("GUCK_DAEMON") == "1" {
+		// Serve on the port the parent reported rather than the one picked above
+		if p, err := strconv.Atoi(os.Getenv("GUCK_DAEMON_PORT")); err == nil && p > 0 {
+			port = p
+		}
+
+		daemonInfo := &daemon.Info{
+			PID:        os.Getpid(),
+			Port:       port,
+			RepoPath:   repoPath,
+			BaseBranch: baseBranch,
+			DiffMode:   string(diffMode),
+			Host:       host,
+			Fetch:      c.Bool("fetch"),
+		}
+
+		if err := daemonMgr.RegisterDaemon(daemonInfo); err != nil {
+			return err
+		}
+
+		serveErr := server.Start(host, port, baseBranch, server.Options{
+			AutoFetch:   c.Bool("fetch"),
+			DiffMode:    diffMode,
+			IdleTimeout: time.Duration(cfg.DaemonIdleTimeout) * time.Minute,
+		})
+
+		// The server returns after a shutdown signal or an idle timeout; drop
+		// our entry so the shell integration starts a fresh daemon on the next cd
+		unregisterSelf(daemonMgr, repoPath)
+		return serveErr
+	}
+
+	info, err := spawnDaemon(daemonMgr, repoPath, baseBranch, diffMode, host, port, c.Bool("fetch"))
+	if err != nil {
+		return err
+	}
+
+	if c.String("format") == "json" {
+		warnIfExposed(info.Host)
+		return formatters.OutputJSON(newDaemonStartResult(info, false))
+	}
+
+	printStartedDaemon(info)
+	return nil
+}
+
+// daemonStartResult is the JSON output of `guck daemon start`
+type daemonStartResult struct {
+	*daemon.Info
+	URL string `json:"url"`
+	// AlreadyRunning is set when a daemon was already serving the repository
+	// and no new one was started
+	AlreadyRunning bool `json:"already_running"`
+}
+
+func newDaemonStartResult(info *daemon.Info, alreadyRunning bool) daemonStartResult {
+	return daemonStartResult{
+		Info:           info,
+		URL:            info.URL(),
+		AlreadyRunning: alreadyRunning,
+	}
+}
+
+// spawnDaemon launches a detached `guck daemon start` process for repoPath,
+// redirecting its output to the repo's daemon log, and waits for its server
+// to answer before returning the details the daemon registered.
+func spawnDaemon(daemonMgr *daemon.Manager, repoPath, baseBranch string, diffMode git.DiffMode, host string, port int, fetch bool) (*daemon.Info, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	logPath := daemonMgr.GetLogPath(repoPath)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	args := []string{"daemon", "start"}
+	if baseBranch != "" {
+		args = append(args, "--base", baseBranch)
+	}
+	if diffMode != "" && diffMode != git.DiffModeMergeBase {
+		args = append(args, "--diff-mode", string(diffMode))
+	}
+	if host != "" {
+		args = append(args, "--host", host)
+	}
+	if fetch {
+		args = append(args, "--fetch")
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), "GUCK_DAEMON=1", fmt.Sprintf("GUCK_DAEMON_PORT=%d", port))
+	cmd.Dir = repoPath
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// Reap the child so an exit during startup is noticed rather than left
+	// as a zombie that still looks alive
+	go func() { _ = cmd.Wait() }()
+
+	info, err := daemonMgr.WaitForReady(repoPath, cmd.Process.Pid, daemonReadyTimeout)
+	if err != nil {
+		// Don't leave a daemon we report as failed running and registered,
+		// where the next start would find it "already running"
+		_ = cmd.Process.Kill()
+		if registered, _ := daemonMgr.GetDaemonForRepo(repoPath); registered != nil && registered.PID == cmd.Process.Pid {
+			_ = daemonMgr.UnregisterDaemon(repoPath)
+		}
+		if content, readErr := os.ReadFile(logPath); readErr == nil && len(content) > 0 {
+			return nil, fmt.Errorf("%w. Last lines of %s:\n%s", err, logPath, lastLines(string(content), daemonStartupLogLines))
+		}
+		return nil, fmt.Errorf("%w. See %s", err, logPath)
+	}
+	return info, nil
+}
+
+func printStartedDaemon(info *daemon.Info) {
+	successColor.Printf("✓ Started daemon for %s\n", info.RepoPath)
+	infoColor.Printf("  Port: %d | PID: %d | Base: %s\n", info.Port, info.PID, info.BaseBranch)
+	warnIfExposed(info.Host)
+}
+
+// warnIfExposed warns when the server listens beyond this machine
+func warnIfExposed(host string) {
+	if host != "" && !server.IsLoopbackHost(host) {
+		warningColor.Fprintf(os.Stderr, "⚠ Listening on %s: anyone who can reach this machine can view the diff and edit review state\n", host)
+	}
+}
+
+func stopDaemon(c *cli.Context) error {
+	gitRepo, err := git.Open(".")
+	if err != nil {
+		return err
+	}
+
+	repoPath, err := gitRepo.RepoPath()
+	if err != nil {
+		return err
+	}
+
+	daemonMgr, err := daemon.NewManager()
+	if err != nil {
+		return err
+	}
+
+	info, err := daemonMgr.GetDaemonForRepo(repoPath)
+	if err != nil || info == nil {
+		if c.String("format") == "json" {
+			return formatters.OutputJSON(map[string]interface{}{
+				"stopped": []daemon.StopResult{},
+				"count":   0,
+			})
+		}
+		warningColor.Println("⚠ No daemon running for this repository")
+		return nil
+	}
+
+	result := daemonMgr.Stop(info)
+	if c.String("format") == "json" {
+		return formatters.OutputJSON(map[string]interface{}{
+			"stopped": []daemon.StopResult{result},
+			"count":   1,
+		})
+	}
+
+	if result.Error != "" {
+		return errors.New(result.Error)
+	}
+
+	successColor.Printf("✓ Stopped daemon for %s\n", repoPath)
+	return nil
+}
+
+func restartDaemon(c *cli.Context) error {
 	gitRepo, err := git.Open(".")
 	if err != nil {
 		return err
@@ -485,79 +1391,136 @@ func startDaemon(c *cli.Context) error {
 		return err
 	}
 
-	// Check if daemon already running
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	baseBranch := cfg.BaseBranch
+	diffMode := git.DiffModeMergeBase
+	host := cfg.Host
+	fetch := false
+
 	if info, _ := daemonMgr.GetDaemonForRepo(repoPath); info != nil {
+		if info.BaseBranch != "" {
+			baseBranch = info.BaseBranch
+		}
+		if info.Host != "" {
+			host = info.Host
+		}
+		fetch = info.Fetch
+		if info.DiffMode != "" {
+			diffMode = git.DiffMode(info.DiffMode)
+		}
+
 		if daemonMgr.IsDaemonRunning(info.PID) {
-			return nil
+			if err := daemonMgr.StopDaemon(info.PID); err != nil {
+				return err
+			}
+			if err := daemonMgr.WaitForExit(info.PID, daemonStopTimeout); err != nil {
+				return err
+			}
+			successColor.Printf("✓ Stopped daemon for %s\n", repoPath)
+		}
+
+		if err := daemonMgr.UnregisterDaemon(repoPath); err != nil {
+			return err
 		}
-		_ = daemonMgr.UnregisterDaemon(repoPath)
 	}
 
-	cfg, err := config.Load()
+	baseBranch = resolveBaseBranch(repoPath, c.String("base"), baseBranch)
+	if c.String("diff-mode") != "" {
+		if diffMode, err = git.ParseDiffMode(c.String("diff-mode")); err != nil {
+			return err
+		}
+	}
+	if c.String("host") != "" {
+		host = c.String("host")
+	}
+	if c.IsSet("fetch") {
+		fetch = c.Bool("fetch")
+	}
+
+	port, err := daemonMgr.FindAvailablePort()
 	if err != nil {
 		return err
 	}
 
-	baseBranch := c.String("base")
-	if baseBranch == "" {
-		baseBranch = cfg.BaseBranch
+	info, err := spawnDaemon(daemonMgr, repoPath, baseBranch, diffMode, host, port, fetch)
+	if err != nil {
+		return err
 	}
 
-	port, err := daemonMgr.FindAvailablePort()
+	printStartedDaemon(info)
+	return nil
+}
+
+func stopAllDaemons(c *cli.Context) error {
+	daemonMgr, err := daemon.NewManager()
 	if err != nil {
 		return err
 	}
 
-	// Check if we're the daemon process
-	if os.Getenv("GUCK_DAEMON") == "1" {
-		daemonInfo := &daemon.Info{
-			PID:        os.Getpid(),
-			Port:       port,
-			RepoPath:   repoPath,
-			BaseBranch: baseBranch,
-		}
+	results, err := daemonMgr.StopAll()
+	if err != nil {
+		return err
+	}
 
-		if err := daemonMgr.RegisterDaemon(daemonInfo); err != nil {
-			return err
-		}
+	if c.String("format") == "json" {
+		return formatters.OutputJSON(map[string]interface{}{
+			"stopped": results,
+			"count":   len(results),
+		})
+	}
 
-		return server.Start(port, baseBranch)
+	for _, result := range results {
+		if result.Error != "" {
+			errorColor.Printf("✗ Failed to stop daemon for %s: %s\n", result.RepoPath, result.Error)
+			continue
+		}
+		successColor.Printf("✓ Stopped daemon for %s\n", result.RepoPath)
 	}
 
-	// Spawn daemon process
-	exe, err := os.Executable()
+	return nil
+}
+
+func listDaemons(c *cli.Context) error {
+	daemonMgr, err := daemon.NewManager()
 	if err != nil {
 		return err
 	}
 
-	logPath := daemonMgr.GetLogPath(repoPath)
-	logFile, err := os.Create(logPath)
-	if err != nil {
+	if err := daemonMgr.CleanupStaleDaemons(); err != nil {
 		return err
 	}
-	defer logFile.Close()
 
-	args := []string{"daemon", "start"}
-	if baseBranch != "" {
-		args = append(args, "--base", baseBranch)
+	daemons, err := daemonMgr.ListDaemons()
+	if err != nil {
+		return err
 	}
+	sort.Slice(daemons, func(i, j int) bool {
+		return daemons[i].RepoPath < daemons[j].RepoPath
+	})
 
-	cmd := exec.Command(exe, args...)
-	cmd.Env = append(os.Environ(), "GUCK_DAEMON=1")
-	cmd.Dir = repoPath
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	if c.String("format") == "json" {
+		if daemons == nil {
+			daemons = []*daemon.Info{}
+		}
+		return formatters.OutputJSON(daemons)
+	}
 
-	if err := cmd.Start(); err != nil {
-		return err
+	if len(daemons) == 0 {
+		warningColor.Println("⚠ No running daemons")
+		return nil
 	}
 
-	successColor.Printf("✓ Started daemon for %s\n", repoPath)
-	infoColor.Printf("  Port: %d | PID: %d\n", port, cmd.Process.Pid)
+	formatters.PrintDaemonList(os.Stdout, daemons)
 	return nil
 }
 
-func stopDaemon(c *cli.Context) error {
+// daemonStatus reports the health of the current repository's daemon and
+// fails unless it is running and answering requests
+func daemonStatus(c *cli.Context) error {
 	gitRepo, err := git.Open(".")
 	if err != nil {
 		return err
@@ -574,87 +1537,117 @@ func stopDaemon(c *cli.Context) error {
 	}
 
 	info, err := daemonMgr.GetDaemonForRepo(repoPath)
-	if err != nil || info == nil {
-		warningColor.Println("⚠ No daemon running for this repository")
-		return nil
-	}
-
-	if err := daemonMgr.StopDaemon(info.PID); err != nil {
+	if err != nil {
 		return err
 	}
+	if info == nil {
+		return fmt.Errorf("no daemon registered for this repository. Run 'guck daemon start' first")
+	}
 
-	if err := daemonMgr.UnregisterDaemon(repoPath); err != nil {
-		return err
+	status := daemonMgr.CheckHealth(info, daemonProbeTimeout)
+	if c.String("format") == "json" {
+		if err := formatters.OutputJSON(status); err != nil {
+			return err
+		}
+	} else {
+		formatters.PrintDaemonStatus(os.Stdout, status)
 	}
 
-	successColor.Printf("✓ Stopped daemon for %s\n", repoPath)
+	if status.Health != daemon.HealthRunning {
+		return fmt.Errorf("daemon is %s", status.Health)
+	}
 	return nil
 }
 
-func stopAllDaemons(c *cli.Context) error {
+func cleanupDaemons(c *cli.Context) error {
 	daemonMgr, err := daemon.NewManager()
 	if err != nil {
 		return err
 	}
 
-	daemons, err := daemonMgr.ListDaemons()
-	if err != nil {
+	if err := daemonMgr.CleanupStaleDaemons(); err != nil {
 		return err
 	}
 
-	for _, info := range daemons {
-		if daemonMgr.IsDaemonRunning(info.PID) {
-			_ = daemonMgr.StopDaemon(info.PID)
-			_ = daemonMgr.UnregisterDaemon(info.RepoPath)
-			successColor.Printf("✓ Stopped daemon for %s\n", info.RepoPath)
-		}
-	}
-
+	successColor.Println("✓ Cleaned up stale daemon entries")
 	return nil
 }
 
-func listDaemons(c *cli.Context) error {
-	daemonMgr, err := daemon.NewManager()
+func daemonLogs(c *cli.Context) error {
+	gitRepo, err := git.Open(".")
 	if err != nil {
 		return err
 	}
 
-	if err := daemonMgr.CleanupStaleDaemons(); err != nil {
+	repoPath, err := gitRepo.RepoPath()
+	if err != nil {
 		return err
 	}
 
-	daemons, err := daemonMgr.ListDaemons()
+	daemonMgr, err := daemon.NewManager()
 	if err != nil {
 		return err
 	}
 
-	if len(daemons) == 0 {
-		warningColor.Println("⚠ No running daemons")
-		return nil
+	logPath := daemonMgr.GetLogPath(repoPath)
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no daemon log found at %s. Run 'guck daemon start' first", logPath)
+		}
+		return err
 	}
 
-	infoColor.Println("Running daemons:")
-	for _, info := range daemons {
-		fmt.Printf("  %s - ", info.RepoPath)
-		urlColor.Printf("http://localhost:%d", info.Port)
-		fmt.Printf(" (PID: %d)\n", info.PID)
+	infoColor.Fprintf(os.Stderr, "==> %s <==\n", logPath)
+	fmt.Print(lastLines(string(content), c.Int("lines")))
+
+	if !c.Bool("follow") {
+		return nil
 	}
 
-	return nil
-}
+	offset := int64(len(content))
+	for {
+		time.Sleep(500 * time.Millisecond)
 
-func cleanupDaemons(c *cli.Context) error {
-	daemonMgr, err := daemon.NewManager()
-	if err != nil {
-		return err
+		info, err := os.Stat(logPath)
+		if err != nil {
+			// The log is recreated when the daemon restarts
+			continue
+		}
+
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(logPath)
+		if err != nil {
+			continue
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err == nil {
+			n, _ := io.Copy(os.Stdout, file)
+			offset += n
+		}
+		file.Close()
 	}
+}
 
-	if err := daemonMgr.CleanupStaleDaemons(); err != nil {
-		return err
+// lastLines returns the last n lines of content, or all of it when n <= 0
+func lastLines(content string, n int) string {
+	if n <= 0 {
+		return content
 	}
 
-	successColor.Println("✓ Cleaned up stale daemon entries")
-	return nil
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
 }
 
 func openBrowser(c *cli.Context) error {
@@ -679,15 +1672,23 @@ func openBrowser(c *cli.Context) error {
 
 	info, err := daemonMgr.GetDaemonForRepo(repoPath)
 	if err != nil || info == nil {
-		return fmt.Errorf("no daemon running for this repository. Run 'guck daemon start' first")
-	}
-
-	if !daemonMgr.IsDaemonRunning(info.PID) {
+		if c.Bool("no-start") {
+			return fmt.Errorf("no daemon running for this repository. Run 'guck daemon start' first")
+		}
+		if info, err = startDaemonForOpen(daemonMgr, repoPath); err != nil {
+			return err
+		}
+	} else if !daemonMgr.IsDaemonRunning(info.PID) {
 		_ = daemonMgr.UnregisterDaemon(repoPath)
-		return fmt.Errorf("daemon is not running. Run 'guck daemon start' first")
+		if c.Bool("no-start") {
+			return fmt.Errorf("daemon is not running. Run 'guck daemon start' first")
+		}
+		if info, err = startDaemonForOpen(daemonMgr, repoPath); err != nil {
+			return err
+		}
 	}
 
-	url := fmt.Sprintf("http://localhost:%d", info.Port)
+	url := info.URL()
 	infoColor.Print("Opening ")
 	urlColor.Print(url)
 	infoColor.Println(" in your browser...")
@@ -705,32 +1706,53 @@ func openBrowser(c *cli.Context) error {
 	return cmd.Start()
 }
 
+// startDaemonForOpen starts a daemon for repoPath with the configured base
+// branch
+func startDaemonForOpen(daemonMgr *daemon.Manager, repoPath string) (*daemon.Info, error) {
+	cfg, err := config.LoadForRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := daemonMgr.FindAvailablePort()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := spawnDaemon(daemonMgr, repoPath, cfg.BaseBranch, git.DiffModeMergeBase, cfg.Host, port, false)
+	if err != nil {
+		return nil, err
+	}
+	printStartedDaemon(info)
+	return info, nil
+}
+
 func setConfig(c *cli.Context) error {
 	if c.NArg() != 2 {
 		return fmt.Errorf("requires exactly 2 arguments: key and value")
 	}
 
-	key := c.Args().Get(0)
-	value := c.Args().Get(1)
+	key, err := config.LookupKey(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
 
-	cfg, err := config.Load()
+	cfg, err := config.LoadForUpdate()
 	if err != nil {
 		return err
 	}
 
-	switch key {
-	case "base-branch":
-		cfg.BaseBranch = value
-		if err := cfg.Save(); err != nil {
-			return err
-		}
-		successColor.Print("✓ Set ")
-		infoColor.Print("base-branch")
-		successColor.Printf(" to '%s'\n", value)
-	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+	if err := key.Set(cfg, c.Args().Get(1)); err != nil {
+		return err
+	}
+
+	if err := cfg.Save(); err != nil {
+		return err
 	}
 
+	successColor.Print("✓ Set ")
+	infoColor.Print(key.Name)
+	successColor.Printf(" to '%s'\n", key.Get(cfg))
 	return nil
 }
 
@@ -739,20 +1761,17 @@ func getConfig(c *cli.Context) error {
 		return fmt.Errorf("requires exactly 1 argument: key")
 	}
 
-	key := c.Args().Get(0)
-
-	cfg, err := config.Load()
+	key, err := config.LookupKey(c.Args().Get(0))
 	if err != nil {
 		return err
 	}
 
-	switch key {
-	case "base-branch":
-		fmt.Println(cfg.BaseBranch)
-	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+	cfg, err := config.Load()
+	if err != nil {
+		return err
 	}
 
+	fmt.Println(key.Get(cfg))
 	return nil
 }
 
@@ -762,32 +1781,77 @@ func showConfig(c *cli.Context) error {
 		return err
 	}
 
-	infoColor.Print("base-branch = ")
-	successColor.Println(cfg.BaseBranch)
+	for _, key := range config.Keys {
+		infoColor.Printf("%s = ", key.Name)
+		successColor.Println(key.Get(cfg))
+	}
+	return nil
+}
+
+func listConfigKeys(c *cli.Context) error {
+	for _, key := range config.Keys {
+		infoColor.Printf("%-21s", key.Name)
+		fmt.Println(key.Description)
+	}
+	return nil
+}
+
+// printVersion reports which build of guck is running, for bug reports
+func printVersion(c *cli.Context) error {
+	info := version.Get()
+	if c.String("format") == "json" {
+		return formatters.OutputJSON(info)
+	}
+
+	fmt.Printf("guck %s\n", info.Version)
+	fmt.Printf("  Commit: %s\n", info.Commit)
+	fmt.Printf("  Built:  %s\n", info.Date)
+	fmt.Printf("  Go:     %s\n", info.GoVersion)
 	return nil
 }
 
 func mcpStdio(c *cli.Context) error {
-	return mcp.StartStdioServer()
+	defaultRepo := c.String("repo")
+	if defaultRepo != "" {
+		gitRepo, err := git.Open(defaultRepo)
+		if err != nil {
+			return err
+		}
+		if defaultRepo, err = gitRepo.RepoPath(); err != nil {
+			return err
+		}
+	}
+	return mcp.StartStdioServer(defaultRepo)
 }
 
-func addSampleNotes(c *cli.Context) error {
+// sampleTarget resolves the repository, branch and commit the dev sample
+// commands add to
+func sampleTarget() (repoPath, branch, commit string, err error) {
 	gitRepo, err := git.Open(".")
 	if err != nil {
-		return err
+		return "", "", "", err
 	}
 
-	repoPath, err := gitRepo.RepoPath()
+	repoPath, err = gitRepo.RepoPath()
 	if err != nil {
-		return err
+		return "", "", "", err
 	}
 
-	branch, err := gitRepo.CurrentBranch()
+	branch, err = gitRepo.CurrentBranch()
 	if err != nil {
-		return err
+		return "", "", "", err
+	}
+
+	commit, err = gitRepo.CurrentCommit()
+	if err != nil {
+		return "", "", "", err
 	}
 
-	commit, err := gitRepo.CurrentCommit()
+	return repoPath, branch, commit, nil
+}
+
+func addSampleNotes(c *cli.Context) error {
+	repoPath, branch, commit, err := sampleTarget()
 	if err != nil {
 		return err
 	}
@@ -797,7 +1861,7 @@ func addSampleNotes(c *cli.Context) error {
 		count = 5
 	}
 
-	mgr, err := state.NewManager()
+	mgr, err := state.NewManagerForRepo(repoPath)
 	if err != nil {
 		return err
 	}
@@ -910,3 +1974,134 @@ func addSampleNotes(c *cli.Context) error {
 
 	return nil
 }
+
+func addSampleComments(c *cli.Context) error {
+	repoPath, branch, commit, err := sampleTarget()
+	if err != nil {
+		return err
+	}
+
+	count := c.Int("count")
+	if count <= 0 {
+		count = 6
+	}
+
+	mgr, err := state.NewManagerForRepo(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// replyTo is the index of the sample a comment replies to, or -1
+	sampleComments := []struct {
+		filePath    string
+		lineNumber  *int
+		endLine     *int
+		text        string
+		suggestion  string
+		author      string
+		commentType string
+		replyTo     int
+		resolved    bool
+	}{
+		{
+			filePath:    "main.go",
+			lineNumber:  helpers.IntPtr(42),
+			text:        "This error is returned without context. Can we wrap it so the log says which repository failed?",
+			author:      "human:alice",
+			commentType: "issue",
+			replyTo:     -1,
+		},
+		{
+			filePath:    "main.go",
+			lineNumber:  helpers.IntPtr(42),
+			text:        "Good catch, I'll wrap it with fmt.Errorf and %w.",
+			author:      "human:bob",
+			commentType: "reply",
+			replyTo:     0,
+		},
+		{
+			filePath:    "internal/server/server.go",
+			lineNumber:  helpers.IntPtr(120),
+			endLine:     helpers.IntPtr(124),
+			text:        "These lines can be simplified.",
+			suggestion:  "if err != nil {\n\treturn err\n}\n",
+			author:      "agent:claude",
+			commentType: "suggestion",
+			replyTo:     -1,
+		},
+		{
+			filePath:    "internal/git/git.go",
+			lineNumber:  helpers.IntPtr(85),
+			text:        "Why does this open the repository again instead of reusing the handle?",
+			author:      "human:carol",
+			commentType: "question",
+			replyTo:     -1,
+			resolved:    true,
+		},
+		{
+			filePath:    "internal/git/git.go",
+			lineNumber:  helpers.IntPtr(85),
+			text:        "The handle isn't safe to share between goroutines, so each request opens its own.",
+			author:      "human:alice",
+			commentType: "reply",
+			replyTo:     3,
+		},
+		{
+			filePath:    "README.md",
+			text:        "The installation section should mention the minimum Go version.",
+			author:      "human:bob",
+			commentType: "issue",
+			replyTo:     -1,
+		},
+	}
+
+	added := 0
+	ids := make([]string, len(sampleComments))
+	for i := 0; i < count && i < len(sampleComments); i++ {
+		sample := sampleComments[i]
+
+		parentID := ""
+		if sample.replyTo >= 0 {
+			parentID = ids[sample.replyTo]
+			if parentID == "" {
+				continue
+			}
+		}
+
+		comment, err := mgr.AddComment(
+			repoPath,
+			branch,
+			commit,
+			sample.filePath,
+			sample.lineNumber,
+			sample.endLine,
+			"",
+			sample.text,
+			sample.suggestion,
+			sample.author,
+			sample.commentType,
+			parentID,
+			nil,
+		)
+		if err != nil {
+			warningColor.Printf("⚠ Failed to add comment: %v\n", err)
+			continue
+		}
+		ids[i] = comment.ID
+		added++
+
+		if sample.resolved {
+			if err := mgr.ResolveComment(repoPath, branch, commit, comment.ID, "human:alice"); err != nil {
+				warningColor.Printf("⚠ Failed to resolve comment: %v\n", err)
+			}
+		}
+	}
+
+	successColor.Printf("✓ Added %d sample review comment(s)\n", added)
+	infoColor.Printf("  Repository: %s\n", repoPath)
+	infoColor.Printf("  Branch: %s\n", branch)
+	infoColor.Printf("  Commit: %s\n", commit[:7])
+	infoColor.Println("\nRefresh your browser to see the comments in the UI")
+
+	return nil
+}